@@ -1,20 +1,35 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/i3ash/fortify/files"
 	"github.com/i3ash/fortify/fortifier"
 	"github.com/spf13/cobra"
 )
 
+var flagDecSecureOutput, flagDecStrict, flagDecFIPS bool
+var flagDecKeychain, flagDecRecoverScheme, flagDecRequireKey, flagDecAAD, flagDecCredential string
+var flagDecKDFInfo string
+var flagDecKeyChain, flagDecOidcService []string
+var flagDecOutFd int
+
 func init() {
 	var o string
 	c := &cobra.Command{
 		Short: "Decrypt the fortified input file",
 		Use:   "decrypt -i <input-file> [flags] <key1> [key2] ...",
-		Args:  cobra.MinimumNArgs(1),
+		Args: func(c *cobra.Command, args []string) error {
+			// a keychain entry or an oidc token stands in for <key1>
+			if flagDecKeychain != "" || os.Getenv(fortifier.OidcTokenEnv) != "" {
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(c, args)
+		},
 		RunE: func(_ *cobra.Command, args []string) error {
 			return decrypt(flagIn, o, args)
 		},
@@ -22,7 +37,9 @@ func init() {
 	c.SetUsageTemplate(fmt.Sprintf(`%s
 Required Arguments:
 Required Arguments:
-  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa'
+  <key1>   Path to the first secret share file or private key file (or a directory of private key files)
+           if cipher key kind of <input-file> is 'rsa', or optional mnemonic phrase file if it is 'mnemonic'
+           (none if it is 'oidc', the token of $FORTIFY_OIDC_TOKEN is presented to the unwrap service)
   [key2]   [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
   ...      Additional paths to secret share files (all files remain unmodified)
 `, c.UsageTemplate()))
@@ -33,6 +50,33 @@ Required Arguments:
 	initFlagIn(c, "[Required] Path of the fortified/encrypted input file")
 	_ = c.MarkFlagRequired("in")
 	c.Flags().StringVarP(&o, "out", "o", "output.data", "Path of the output decrypted file")
+	c.Flags().BoolVarP(&flagDecSecureOutput, "secure-output", "", false,
+		"Refuse to write the output into a directory writable by group or others")
+	c.Flags().IntVarP(&flagDecOutFd, "out-fd", "", -1,
+		"Write the output to the inherited file descriptor, a pipe or socket, instead of a file")
+	c.MarkFlagsMutuallyExclusive("out-fd", "out")
+	c.MarkFlagsMutuallyExclusive("out-fd", "secure-output")
+	c.MarkFlagsMutuallyExclusive("out-fd", "verbose")
+	c.Flags().BoolVarP(&flagDecStrict, "strict", "", false,
+		"Refuse files using legacy or weak parameters, such as rsa keys under 2048 bits")
+	c.Flags().BoolVarP(&flagDecFIPS, "fips", "", false,
+		"Refuse files using suites not approved for FIPS mode, such as sss or rsa-kem")
+	c.Flags().StringVarP(&flagDecRequireKey, "require-key", "", "",
+		"Refuse files not fortified with the cipher key kind, options: [sss|rsa|mnemonic|dpapi|oidc]")
+	c.Flags().StringVarP(&flagDecAAD, "aad", "", "",
+		"Additional data, such as a document ID, the input file was bound to when encrypted")
+	c.Flags().StringVarP(&flagDecRecoverScheme, "recover-scheme", "", "",
+		"Recover the cipher key only from rsa recipients wrapped with the scheme, options: [rsa-oaep|rsa-kem|pgp]")
+	c.Flags().StringVarP(&flagDecKDFInfo, "kdf-info", "", "",
+		"Refuse rsa-kem recipients wrapped with another HKDF info than this one")
+	c.Flags().StringVarP(&flagDecKeychain, "keychain", "", "",
+		"Read the rsa private key from the system keychain as <service>/<account> instead of <key1>")
+	c.Flags().StringVarP(&flagDecCredential, "passphrase-credential", "", "",
+		"Read passphrases from the item of the system credential store, prompting for them if it is missing")
+	c.Flags().StringArrayVarP(&flagDecKeyChain, "key-chain", "", nil,
+		"Fortified private key file recovered with the previous key, <key1> first, the last one decrypting <input-file>")
+	c.Flags().StringArrayVarP(&flagDecOidcService, "oidc-service", "", nil,
+		"URL of an unwrap service trusted to receive the oidc token, instead of the one of $"+fortifier.OidcServiceEnv)
 }
 
 func decrypt(input, output string, args []string) (err error) {
@@ -43,26 +87,104 @@ func decrypt(input, output string, args []string) (err error) {
 		return
 	}
 	defer iCloseFn()
-	layout := &fortifier.FileLayout{}
-	if err = layout.ReadHeadIn(in); err != nil {
+	// an armored input is decoded as read, a binary one read on from the file
+	var layout *fortifier.FileLayout
+	var payload io.Reader
+	if layout, payload, err = fortifier.ReadContainer(in); err != nil {
 		return
 	}
 	if flagVerbose {
 		fmt.Printf("%s\n", layout.String())
 	}
 	meta := layout.Metadata()
+	if flagDecRequireKey != "" && meta.Key != fortifier.CipherKeyKind(flagDecRequireKey) {
+		return fmt.Errorf("%w: file of kind %q, requiring %s", fortifier.ErrKeyKindMismatch, meta.Key, flagDecRequireKey)
+	}
 	var f *fortifier.Fortifier
-	if f, _, err = newFortifier(meta.Key, meta, args); err != nil {
+	if flagDecKeychain != "" {
+		f, err = newKeychainFortifier(meta, flagDecKeychain)
+	} else {
+		f, _, err = newFortifier(meta.Key, meta, args)
+	}
+	if err != nil {
+		return
+	}
+	if len(flagDecKeyChain) > 0 {
+		chain := make([][]byte, len(flagDecKeyChain))
+		for i, path := range flagDecKeyChain {
+			if chain[i], err = os.ReadFile(path); err != nil {
+				return
+			}
+		}
+		f.SetKeyChain(chain...)
+	}
+	if flagDecCredential != "" {
+		var store *fortifier.CredentialStore
+		if store, err = fortifier.OpenCredentialStore(fortifier.DefaultCredentialService); err != nil {
+			return
+		}
+		f.SetPassphraseProvider(store.Provider(flagDecCredential, fortifier.TerminalPassphrase))
+	}
+	if flagDecStrict {
+		f.SetPolicy(fortifier.StrictPolicy)
+	}
+	f.SetFIPSMode(flagDecFIPS)
+	if flagDecAAD != "" {
+		f.SetAAD([]byte(flagDecAAD))
+	}
+	f.RequireKeyKind(fortifier.CipherKeyKind(flagDecRequireKey))
+	f.SetKDFInfo(flagDecKDFInfo)
+	if len(flagDecOidcService) > 0 {
+		if err = f.SetOidcService(flagDecOidcService...); err != nil {
+			return
+		}
+	}
+	if err = f.SetRecoverScheme(fortifier.RsaScheme(flagDecRecoverScheme)); err != nil {
 		return
 	}
+	if err = f.OpenMetadata(layout); err != nil {
+		return
+	}
+	meta = layout.Metadata()
 	var dec fortifier.Decrypter
 	if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
 		err = fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
 		return
 	}
-	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+	if flagDecOutFd >= 0 {
+		out, oCloseFn, err = files.OpenOutputFd(uintptr(flagDecOutFd))
+	} else if flagDecSecureOutput {
+		out, oCloseFn, err = files.OpenSecretOutputFile(output, flagTruncate)
+	} else {
+		out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate)
+	}
+	if err != nil {
 		return
 	}
 	defer oCloseFn()
-	return dec.DecryptFile(in, out, layout)
+	if payload != io.Reader(in) {
+		err = dec.Decrypt(bufio.NewReader(payload), out, layout)
+	} else {
+		err = dec.DecryptFile(in, out, layout)
+	}
+	if err != nil {
+		return
+	}
+	if meta.Nested {
+		_, _ = fmt.Fprintf(os.Stderr, "%s holds a nested fortified file: decrypt it again with the key of the inner layer\n", out.Name())
+	}
+	return
+}
+
+// newKeychainFortifier recovers the cipher key with the private key kept in
+// the system keychain under the item named <service>/<account>.
+func newKeychainFortifier(meta *fortifier.Metadata, item string) (*fortifier.Fortifier, error) {
+	if meta.Key != fortifier.CipherKeyKindRSA {
+		return nil, fmt.Errorf("keychain requires cipher key kind %s, not %s", fortifier.CipherKeyKindRSA, meta.Key)
+	}
+	service, account, ok := strings.Cut(item, "/")
+	if !ok || service == "" || account == "" {
+		return nil, fmt.Errorf("keychain item must be <service>/<account>, not %q", item)
+	}
+	return fortifier.NewFortifierWithRsaKeychain(flagVerbose, meta, fortifier.SystemKeychain, service, account)
 }
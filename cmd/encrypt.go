@@ -1,15 +1,32 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/i3ash/fortify/files"
 	"github.com/i3ash/fortify/fortifier"
 	"github.com/spf13/cobra"
 )
 
-var flagEncOut, flagEncKey, flagEncMode string
+var flagEncOut, flagEncKey, flagEncMode, flagEncDescription, flagEncRsaScheme, flagEncRecipients string
+var flagEncGroupsFile, flagEncMnemonicPath, flagEncAAD, flagEncSeenStore, flagEncDpapiScope string
+var flagEncOidcIssuer, flagEncOidcSubject, flagEncOidcAudience, flagEncOidcService string
+var flagEncTags, flagEncIndex map[string]string
+var flagEncDualWrap, flagEncGroups, flagEncAgeRecipients, flagEncOperators, flagEncWKD, flagEncAgent []string
+var flagEncJoint []string
+var flagEncSeal, flagEncDeterministic, flagEncRedundant, flagEncToSelf, flagEncEmbedPub, flagEncLegacyRsa bool
+var flagEncCompressMeta, flagEncStableMeta, flagEncFIPS, flagEncPassFactor, flagEncKeyHint, flagEncRequireKeyEncipherment bool
+var flagEncSelfTest, flagEncNested, flagEncArmor bool
+var flagEncKDFInfo, flagEncKeyWrap string
+var flagEncSelfTestKeys []string
+var flagEncSegment int64
+var flagEncChunk int
 
 func init() {
 	c := &cobra.Command{
@@ -21,7 +38,9 @@ func init() {
 	}
 	c.SetUsageTemplate(fmt.Sprintf(`%s
 Required Arguments:
-  <key1>   Path to the first secret share file or public key file if -k/--k is 'rsa'
+  <key1>   Path to the first secret share file, public key file if -k/--k is 'rsa',
+           or optional mnemonic phrase file if -k/--k is 'mnemonic' (prompted for if omitted),
+           or public key file of the unwrap service if -k/--k is 'oidc'
   [key2]   [Required if -k/--k is 'sss'] Path to the second secret share file
   ...      Additional paths to secret share files (all files remain unmodified)
 `, c.UsageTemplate()))
@@ -34,17 +53,261 @@ Required Arguments:
 	c.Flags().StringVarP(&flagEncOut, "out", "o", "fortified.data",
 		"Path of the output fortified/encrypted file")
 	c.Flags().StringVarP(&flagEncKey, "key", "k", fortifier.CipherKeyKindSSS.String(),
-		"Cipher key kind name, options: [sss|rsa]")
+		"Cipher key kind name, options: [sss|rsa|mnemonic|dpapi|oidc]")
 	c.Flags().StringVarP(&flagEncMode, "mode", "m", fortifier.CipherModeAes256CTR.String(),
 		"Cipher mode name, options: [aes256-ctr|aes256-ofb|aes256-cfb]")
+	c.Flags().BoolVarP(&flagEncArmor, "armor", "a", false,
+		"Write the output armored as base64 lines between BEGIN FORTIFY and END FORTIFY, for text-only channels")
+	c.Flags().BoolVarP(&flagEncSeal, "seal", "S", false,
+		"Encrypt the metadata so that only key holders can read it")
+	c.Flags().BoolVarP(&flagEncDeterministic, "deterministic", "D", false,
+		"Derive the IV from the input so identical inputs under the same key encrypt identically (aes256-ctr only)")
+	c.Flags().BoolVarP(&flagEncRedundant, "redundant-wrap", "", false,
+		"Wrap the cipher key twice for the rsa recipient so one faulty wrapping stays recoverable")
+	c.Flags().StringVarP(&flagEncRsaScheme, "rsa-scheme", "", fortifier.RsaSchemeOAEP.String(),
+		"Wrapping of the cipher key for rsa recipients, options: [rsa-oaep|rsa-kem|pgp]")
+	c.Flags().StringVarP(&flagEncKDFInfo, "kdf-info", "", "",
+		"HKDF info the rsa-kem scheme derives wrapping keys with, separating the domain of an application")
+	c.Flags().StringVarP(&flagEncKeyWrap, "key-wrap", "", fortifier.KeyWrapGCM.String(),
+		"Wrapping of the cipher key under the rsa-kem wrapping key, options: [aes256-gcm|aes-kw]")
+	c.Flags().StringSliceVarP(&flagEncDualWrap, "dual-wrap", "", nil,
+		"Also wrap the cipher key for every rsa recipient under the other scheme(s), for testing a migration")
+	c.Flags().StringVarP(&flagEncRecipients, "recipients-file", "R", "",
+		"Path of a file listing additional rsa recipients, one public key, public key file or SHA256 fingerprint per line")
+	c.Flags().StringVarP(&flagEncGroupsFile, "groups-file", "", "",
+		"Path of a JSON file mapping recipient group aliases to lists of recipients as in a recipients file")
+	c.Flags().StringSliceVarP(&flagEncGroups, "group", "G", nil,
+		"Alias of a recipient group of the groups file to add as rsa recipients (repeatable)")
+	c.Flags().StringArrayVarP(&flagEncAgeRecipients, "age-recipient", "", nil,
+		"SSH public key, or path of one, to wrap the cipher key for as an age recipient, recoverable with age -i (repeatable)")
+	c.Flags().StringArrayVarP(&flagEncAgent, "agent-recipient", "", nil,
+		"Comment substring or SHA256 fingerprint of an ssh agent identity to add as an rsa recipient (repeatable)")
+	c.Flags().StringArrayVarP(&flagEncWKD, "wkd", "", nil,
+		"Email address whose rsa OpenPGP key to look up in its Web Key Directory and add as an rsa recipient (repeatable)")
+	c.Flags().BoolVarP(&flagEncEmbedPub, "embed-public-key", "", false,
+		"Store the full public key of every rsa recipient in the head, not just its fingerprint")
+	c.Flags().BoolVarP(&flagEncKeyHint, "key-hint", "", false,
+		"Store a salted key ID hint of every rsa recipient instead of its fingerprint, so files are not linkable to keys")
+	c.Flags().BoolVarP(&flagEncLegacyRsa, "legacy-rsa-field", "", false,
+		"Write the first rsa recipient into the legacy rsa field of the head for older readers")
+	c.Flags().BoolVarP(&flagEncCompressMeta, "compress-metadata", "", false,
+		"Store the metadata of the head gzip compressed, which older versions cannot read")
+	c.Flags().BoolVarP(&flagEncStableMeta, "stable-metadata", "", false,
+		"Order rsa recipients by fingerprint so re-encrypting for the same recipients gives minimal diffs")
+	c.Flags().BoolVarP(&flagEncToSelf, "to-self", "", false,
+		"Add your own SSH public key ($SSH_PUBLIC_KEY or ~/.ssh/id_*.pub) as an rsa recipient")
+	c.Flags().BoolVarP(&flagEncPassFactor, "passphrase-factor", "", false,
+		"Require a passphrase besides the rsa private key to decrypt, prompting for it twice")
+	c.Flags().StringVarP(&flagEncMnemonicPath, "mnemonic-path", "", fortifier.DefaultMnemonicPath,
+		"Hardened BIP32 path of the key derived from the mnemonic to wrap the cipher key")
+	c.Flags().StringVarP(&flagEncDpapiScope, "dpapi-scope", "", string(fortifier.DpapiScopeUser),
+		"Scope of the cipher key protected by dpapi on Windows, options: [user|machine]")
+	c.Flags().StringVarP(&flagEncOidcIssuer, "oidc-issuer", "", "",
+		"Issuer of the OIDC identity to fortify the cipher key to if -k/--k is 'oidc'")
+	c.Flags().StringVarP(&flagEncOidcSubject, "oidc-subject", "", "",
+		"Subject of the OIDC identity to fortify the cipher key to if -k/--k is 'oidc'")
+	c.Flags().StringVarP(&flagEncOidcAudience, "oidc-audience", "", "",
+		"Audience of the OIDC tokens accepted by the unwrap service if -k/--k is 'oidc'")
+	c.Flags().StringVarP(&flagEncOidcService, "oidc-service", "", "",
+		"URL of the unwrap service releasing the cipher key to a token of the OIDC identity")
+	c.Flags().StringVarP(&flagEncSeenStore, "seen-store", "", "",
+		"Path of a file recording the digest of every cipher key generated, refusing a key generated before")
+	c.Flags().BoolVarP(&flagEncRequireKeyEncipherment, "require-key-encipherment", "", false,
+		"Refuse recipient certificates whose key usage does not permit key encipherment, such as signing certificates")
+	c.Flags().BoolVarP(&flagEncFIPS, "fips", "", false,
+		"Refuse suites not approved for FIPS mode, allowing only rsa-oaep recipients")
+	c.Flags().BoolVarP(&flagEncNested, "nested", "", false,
+		"Wrap an input that is already fortified again as the payload of an outer layer, instead of refusing it")
+	c.Flags().BoolVarP(&flagEncSelfTest, "self-test", "", false,
+		"Recover the cipher key from the head right after wrapping it, failing before the payload is written")
+	c.Flags().StringArrayVarP(&flagEncSelfTestKeys, "self-test-key", "", nil,
+		"Path of the rsa private key file of a recipient, or of each --joint party, to recover with for --self-test if -k/--k is 'rsa'")
+	c.Flags().Int64VarP(&flagEncSegment, "segment", "", 0,
+		"Number of bytes encrypted under each derived subkey, a multiple of 16 of at least 65536 (0 disables subkeys)")
+	c.Flags().IntVarP(&flagEncChunk, "chunk", "", 0,
+		"Number of bytes the payload is streamed in, a power of two between 4096 and 16777216 (0 uses the default)")
+	c.Flags().StringVarP(&flagEncAAD, "aad", "", "",
+		"Additional data, such as a document ID, to bind the output file to; decrypt requires the same --aad")
+	c.Flags().StringVarP(&flagEncDescription, "description", "", "",
+		"Description of the fortified file, readable in its head")
+	c.Flags().StringToStringVarP(&flagEncTags, "tag", "", nil,
+		"Tag of the fortified file as name=value, readable in its head (repeatable)")
+	c.Flags().StringToStringVarP(&flagEncIndex, "index", "", nil,
+		"Index field of the fortified file as name=value, readable in its head by the operators only (repeatable)")
+	c.Flags().StringArrayVarP(&flagEncOperators, "operator", "", nil,
+		"Path of the rsa public key file of an operator reading the index but not the payload (repeatable)")
+	c.Flags().StringArrayVarP(&flagEncJoint, "joint", "", nil,
+		"Party of a joint recovery as name=path of its rsa public key file, given twice: both private keys are required to decrypt")
 }
 
 func encrypt(input, output, key, mode string, args []string) (err error) {
 	files.SetVerbose(flagVerbose)
+	var recipients [][]byte
+	if flagEncRecipients != "" {
+		if recipients, err = fortifier.ReadRecipientsFile(flagEncRecipients); err != nil {
+			return
+		}
+	}
+	var groups *fortifier.RecipientGroups
+	if len(flagEncGroups) > 0 {
+		if flagEncGroupsFile == "" {
+			return fmt.Errorf("--group requires --groups-file")
+		}
+		if groups, err = fortifier.ReadRecipientGroups(flagEncGroupsFile); err != nil {
+			return
+		}
+	}
 	var f *fortifier.Fortifier
 	if f, _, err = newFortifier(fortifier.CipherKeyKind(key), nil, args); err != nil {
 		return
 	}
+	for _, r := range recipients {
+		f.AddRecipient(r)
+	}
+	for _, alias := range flagEncGroups {
+		var members [][]byte
+		if members, err = groups.Expand(alias); err != nil {
+			return
+		}
+		f.AddRecipientGroup(alias, members)
+	}
+	if len(flagEncIndex) > 0 {
+		operators := make([][]byte, len(flagEncOperators))
+		for i, path := range flagEncOperators {
+			if operators[i], err = readKeyFile([]string{path}); err != nil {
+				return
+			}
+		}
+		if err = f.SetIndex(flagEncIndex, operators...); err != nil {
+			return
+		}
+	}
+	if len(flagEncJoint) > 0 {
+		if len(flagEncJoint) != 2 {
+			return fmt.Errorf("--joint requires exactly two parties, not %d", len(flagEncJoint))
+		}
+		var parties [2]fortifier.JointRecipient
+		for i, p := range flagEncJoint {
+			name, path, ok := strings.Cut(p, "=")
+			if !ok {
+				return fmt.Errorf("--joint %q: expect name=path", p)
+			}
+			parties[i].Name = name
+			if parties[i].PublicKey, err = readKeyFile([]string{path}); err != nil {
+				return
+			}
+		}
+		if err = f.SetJointRecovery(parties[0], parties[1]); err != nil {
+			return
+		}
+	}
+	for _, r := range flagEncAgeRecipients {
+		kb := []byte(r)
+		if isFile(r) {
+			if kb, err = os.ReadFile(r); err != nil {
+				return
+			}
+		}
+		if err = f.AddAgeRecipient(kb); err != nil {
+			return
+		}
+	}
+	if len(flagEncAgent) > 0 {
+		ag, conn, err := fortifier.DialAgent()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = conn.Close() }()
+		for _, query := range flagEncAgent {
+			kb, err := fortifier.ResolveAgentRecipient(ag, query)
+			if err != nil {
+				return err
+			}
+			f.AddRecipient(kb)
+		}
+	}
+	for _, email := range flagEncWKD {
+		if err = f.AddWKDRecipient(context.Background(), email); err != nil {
+			return
+		}
+	}
+	f.SetSealMetadata(flagEncSeal)
+	f.SetDeterministicIv(flagEncDeterministic)
+	f.SetNested(flagEncNested)
+	f.SetRedundantWrap(flagEncRedundant)
+	f.SetEncryptToSelf(flagEncToSelf)
+	f.SetEmbedPublicKey(flagEncEmbedPub)
+	f.SetKeyHint(flagEncKeyHint)
+	if flagEncRequireKeyEncipherment {
+		f.SetPolicy(fortifier.Policy{RequireKeyEncipherment: true})
+	}
+	f.SetLegacyRsaField(flagEncLegacyRsa)
+	f.SetCompressMetadata(flagEncCompressMeta)
+	f.SetStableMetadata(flagEncStableMeta)
+	f.SetFIPSMode(flagEncFIPS)
+	if flagEncSeenStore != "" {
+		f.SetSeenStore(fortifier.NewFileSeenStore(flagEncSeenStore))
+	}
+	if flagEncAAD != "" {
+		f.SetAAD([]byte(flagEncAAD))
+	}
+	if flagEncPassFactor {
+		f.SetPassphraseFactor(true)
+		f.SetPassphraseProvider(fortifier.PassphraseFunc(confirmPassphrase))
+	}
+	if err = f.SetRsaScheme(fortifier.RsaScheme(flagEncRsaScheme)); err != nil {
+		return
+	}
+	f.SetKDFInfo(flagEncKDFInfo)
+	if err = f.SetKeyWrap(fortifier.KeyWrap(flagEncKeyWrap)); err != nil {
+		return
+	}
+	var dual []fortifier.RsaScheme
+	for _, s := range flagEncDualWrap {
+		dual = append(dual, fortifier.RsaScheme(s))
+	}
+	if err = f.SetDualWrap(dual...); err != nil {
+		return
+	}
+	if fortifier.CipherKeyKind(key) == fortifier.CipherKeyKindMnemonic {
+		if err = f.SetMnemonicPath(flagEncMnemonicPath); err != nil {
+			return
+		}
+	}
+	if fortifier.CipherKeyKind(key) == fortifier.CipherKeyKindDPAPI {
+		if err = f.SetDpapiScope(fortifier.DpapiScope(flagEncDpapiScope)); err != nil {
+			return
+		}
+	}
+	if fortifier.CipherKeyKind(key) == fortifier.CipherKeyKindOIDC {
+		if err = f.SetOidcIdentity(fortifier.OidcIdentity{Issuer: flagEncOidcIssuer, Subject: flagEncOidcSubject,
+			Audience: flagEncOidcAudience, Service: flagEncOidcService}); err != nil {
+			return
+		}
+	}
+	if err = f.SetSegmentSize(flagEncSegment); err != nil {
+		return
+	}
+	if err = f.SetChunkSize(flagEncChunk); err != nil {
+		return
+	}
+	if flagEncSelfTest {
+		kbs := make([][]byte, len(flagEncSelfTestKeys))
+		for i, path := range flagEncSelfTestKeys {
+			if kbs[i], err = os.ReadFile(path); err != nil {
+				return
+			}
+		}
+		if err = f.SetSelfTest(true, kbs...); err != nil {
+			return
+		}
+	}
+	if err = f.SetDescription(flagEncDescription); err != nil {
+		return
+	}
+	if err = f.SetTags(flagEncTags); err != nil {
+		return
+	}
 	var enc fortifier.Encrypter
 	if enc = fortifier.NewEncrypter(fortifier.CipherModeName(mode), f); enc == nil {
 		err = fmt.Errorf("unknown cipher mode name: %s", mode)
@@ -56,9 +319,43 @@ func encrypt(input, output, key, mode string, args []string) (err error) {
 		return
 	}
 	defer iCloseFn()
+	if flagEncArmor {
+		return encryptArmored(enc, in, output)
+	}
 	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
 		return
 	}
 	defer oCloseFn()
 	return enc.EncryptFile(in, out)
 }
+
+// encryptArmored encrypts into a temporary file next to the output, which the
+// head is written back into, and armors it into the output.
+func encryptArmored(enc fortifier.Encrypter, in *os.File, output string) (err error) {
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(output), ".fortify-*"); err != nil {
+		return
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if err = enc.EncryptFile(in, tmp); err != nil {
+		return
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	var out *os.File
+	var oCloseFn func()
+	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+		return
+	}
+	defer oCloseFn()
+	w := bufio.NewWriter(out)
+	if err = fortifier.Convert(fortifier.ContainerReader{Data: bufio.NewReader(tmp)}, fortifier.ContainerWriter{Data: w},
+		fortifier.ContainerArmor); err != nil {
+		return
+	}
+	return w.Flush()
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -57,8 +58,9 @@ func execute(input string, args []string) (err error) {
 		return
 	}
 	defer iCloseFn()
-	layout := &fortifier.FileLayout{}
-	if err = layout.ReadHeadIn(in); err != nil {
+	var layout *fortifier.FileLayout
+	var payload io.Reader
+	if layout, payload, err = fortifier.ReadContainer(in); err != nil {
 		return
 	}
 	docker := dockerYes()
@@ -75,6 +77,10 @@ func execute(input string, args []string) (err error) {
 	if f, rest, err = newFortifier(meta.Key, meta, merge); err != nil {
 		return
 	}
+	if err = f.OpenMetadata(layout); err != nil {
+		return
+	}
+	meta = layout.Metadata()
 	var dec fortifier.Decrypter
 	if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
 		err = fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
@@ -95,7 +101,7 @@ func execute(input string, args []string) (err error) {
 		_, _ = fmt.Fprintf(os.Stderr, "Failed to create file: %v\n", err)
 		return nil
 	}
-	r := bufio.NewReaderSize(in, 128*1024)
+	r := bufio.NewReaderSize(payload, 128*1024)
 	err = dec.Decrypt(r, out, layout)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Failed to decrypt program: %v\n", err)
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	c := &cobra.Command{
+		Short: "Print the fingerprint of the whole fortified input file, stable across metadata re-serialization",
+		Use:   "fingerprint -i <input-file> [flags]",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return fingerprint(flagIn)
+		},
+	}
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagVerbose(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted input file")
+	_ = c.MarkFlagRequired("in")
+}
+
+func fingerprint(input string) (err error) {
+	files.SetVerbose(flagVerbose)
+	var in *os.File
+	var iCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+		return
+	}
+	defer iCloseFn()
+	var layout *fortifier.FileLayout
+	var payload io.Reader
+	if layout, payload, err = fortifier.ReadContainer(in); err != nil {
+		return
+	}
+	var fp string
+	if fp, err = fortifier.FileFingerprint(layout, bufio.NewReader(payload)); err != nil {
+		return
+	}
+	fmt.Printf("%s  %s\n", fp, input)
+	return
+}
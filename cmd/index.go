@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	c := &cobra.Command{
+		Short: "Print the index of the fortified input file with an operator private key",
+		Use:   "index -i <input-file> [flags] <operator-key>",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return index(flagIn, args)
+		},
+	}
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagVerbose(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted input file")
+	_ = c.MarkFlagRequired("in")
+}
+
+func index(input string, args []string) (err error) {
+	files.SetVerbose(flagVerbose)
+	var in *os.File
+	var iCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+		return
+	}
+	defer iCloseFn()
+	var layout *fortifier.FileLayout
+	if layout, _, err = fortifier.ReadContainer(in); err != nil {
+		return
+	}
+	meta := layout.Metadata()
+	if meta.Index == nil {
+		return fmt.Errorf("%s: no index", input)
+	}
+	var kb []byte
+	if kb, err = readKeyFile(args); err != nil {
+		return
+	}
+	var fields map[string]string
+	if fields, err = fortifier.NewFortifierWithRsa(flagVerbose, meta, kb).OpenIndex(meta); err != nil {
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fields)
+}
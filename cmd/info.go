@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+var flagInfoJSON bool
+
+func init() {
+	c := &cobra.Command{
+		Short: "Print what the head of the fortified input file tells, without any key",
+		Use:   "info -i <input-file> [flags]",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return info(flagIn)
+		},
+	}
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagVerbose(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted input file")
+	_ = c.MarkFlagRequired("in")
+	c.Flags().BoolVarP(&flagInfoJSON, "json", "", false, "Print the information as JSON")
+}
+
+func info(input string) (err error) {
+	files.SetVerbose(flagVerbose)
+	var in *os.File
+	var iCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+		return
+	}
+	defer iCloseFn()
+	var fi *fortifier.FileInfo
+	if fi, err = fortifier.Inspect(in); err != nil {
+		return
+	}
+	if flagInfoJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(fi)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	version := fi.Version
+	if fi.CompressedMetadata {
+		version += " (compressed metadata)"
+	}
+	_, _ = fmt.Fprintf(w, "Version:\t%s\n", version)
+	_, _ = fmt.Fprintf(w, "Suite:\t%s\n", fi.Suite)
+	_, _ = fmt.Fprintf(w, "Key:\t%s\n", fi.Key)
+	if fi.Mode != "" {
+		_, _ = fmt.Fprintf(w, "Mode:\t%s\n", fi.Mode)
+	}
+	if !fi.Timestamp.IsZero() {
+		_, _ = fmt.Fprintf(w, "Timestamp:\t%s\n", fi.Timestamp.Format(time.RFC3339))
+	}
+	_, _ = fmt.Fprintf(w, "Head Size:\t%d\n", fi.HeadSize)
+	_, _ = fmt.Fprintf(w, "Payload Size:\t%d\n", fi.PayloadSize)
+	if fi.Sealed {
+		_, _ = fmt.Fprintf(w, "Sealed:\tyes\n")
+	}
+	if fi.Deterministic {
+		_, _ = fmt.Fprintf(w, "Deterministic:\tyes\n")
+	}
+	if fi.Nested {
+		_, _ = fmt.Fprintf(w, "Nested:\tyes\n")
+	}
+	if fi.Producer != "" {
+		_, _ = fmt.Fprintf(w, "Producer:\t%s\n", fi.Producer)
+	}
+	if fi.Description != "" {
+		_, _ = fmt.Fprintf(w, "Description:\t%s\n", fi.Description)
+	}
+	for _, k := range slices.Sorted(maps.Keys(fi.Tags)) {
+		_, _ = fmt.Fprintf(w, "Tag:\t%s=%s\n", k, fi.Tags[k])
+	}
+	for i, r := range fi.Recipients {
+		_, _ = fmt.Fprintf(w, "Recipient %d:\t%s\t%s\t%s\t%s\n", i+1, r.Kind, r.Fingerprint, r.Label,
+			r.Timestamp.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+var flagPasswdOut, flagPasswdCipher, flagPasswdKdf string
+
+func init() {
+	c := &cobra.Command{
+		Short: "Change the passphrase of an encrypted PKCS #8 private key file",
+		Use:   "passwd -i <key-file> -o <output-file> [flags]",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return passwd(flagIn, flagPasswdOut, flagPasswdCipher, flagPasswdKdf)
+		},
+	}
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagTruncate(c)
+	initFlagVerbose(c)
+	initFlagIn(c, "[Required] Path of the encrypted private key file")
+	_ = c.MarkFlagRequired("in")
+	c.Flags().StringVarP(&flagPasswdOut, "out", "o", "",
+		"[Required] Path of the output private key file encrypted with the new passphrase")
+	_ = c.MarkFlagRequired("out")
+	c.Flags().StringVarP(&flagPasswdCipher, "cipher", "c", "aes256-cbc",
+		"Cipher name, options: [aes128-cbc|aes192-cbc|aes256-cbc|aes128-gcm|aes256-gcm]")
+	c.Flags().StringVarP(&flagPasswdKdf, "kdf", "", "pbkdf2",
+		"Key derivation function name, options: [pbkdf2|scrypt]")
+}
+
+func passwd(input, output, cipher, kdf string) (err error) {
+	files.SetVerbose(flagVerbose)
+	opts, err := fortifier.NewPkcs8Opts(cipher, kdf)
+	if err != nil {
+		return
+	}
+	var kb []byte
+	if kb, err = readKeyFile([]string{input}); err != nil {
+		return
+	}
+	var encrypted []byte
+	if encrypted, err = fortifier.ChangePassphrase(kb, fortifier.TerminalPassphrase,
+		fortifier.PassphraseFunc(confirmPassphrase), opts); err != nil {
+		return
+	}
+	var out *os.File
+	var oCloseFn func()
+	if out, oCloseFn, err = files.OpenSecretOutputFile(output, flagTruncate); err != nil {
+		return
+	}
+	defer oCloseFn()
+	_, err = out.Write(encrypted)
+	return
+}
+
+func confirmPassphrase(prompt string) ([]byte, error) {
+	passphrase, err := fortifier.TerminalPassphrase.Passphrase(prompt)
+	if err != nil {
+		return nil, err
+	}
+	var confirmed []byte
+	if confirmed, err = fortifier.TerminalPassphrase.Passphrase("Confirm new passphrase: "); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, confirmed) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/i3ash/fortify/files"
 	"github.com/i3ash/fortify/fortifier"
@@ -29,10 +30,36 @@ func newFortifier(kind fortifier.CipherKeyKind, meta *fortifier.Metadata, args [
 			return fortifier.NewFortifierWithSss(flagVerbose, flagTruncate, parts), args[len(parts):], nil
 		}
 	case fortifier.CipherKeyKindRSA:
+		if meta != nil && len(args) > 0 && isDir(args[0]) {
+			if keys, err := readKeyDir(args[0]); err != nil {
+				return nil, args, err
+			} else {
+				return fortifier.NewFortifierWithRsaKeyring(flagVerbose, meta, keys), args[1:], nil
+			}
+		}
+		if kb, err := readKeyFile(args); err != nil {
+			return nil, args, err
+		} else {
+			return fortifier.NewFortifierWithRsa(flagVerbose, meta, kb), args[min(1, len(args)):], nil
+		}
+	case fortifier.CipherKeyKindMnemonic:
+		// without a file of the mnemonic, it is prompted for
 		if kb, err := readKeyFile(args); err != nil {
 			return nil, args, err
 		} else {
-			return fortifier.NewFortifierWithRsa(flagVerbose, meta, kb), args[1:], nil
+			return fortifier.NewFortifierWithMnemonic(flagVerbose, meta, kb), args[min(1, len(args)):], nil
+		}
+	case fortifier.CipherKeyKindDPAPI:
+		return fortifier.NewFortifierWithDpapi(flagVerbose, meta), args, nil
+	case fortifier.CipherKeyKindOIDC:
+		// the unwrap service recovers the cipher key for the token of $FORTIFY_OIDC_TOKEN
+		if meta != nil {
+			return fortifier.NewFortifierWithOidc(flagVerbose, meta, nil), args, nil
+		}
+		if kb, err := readKeyFile(args); err != nil {
+			return nil, args, err
+		} else {
+			return fortifier.NewFortifierWithOidc(flagVerbose, meta, kb), args[min(1, len(args)):], nil
 		}
 	default:
 		return nil, args, fmt.Errorf("unknown cipher key kind: %s", kind)
@@ -53,6 +80,50 @@ func readKeyFile(args []string) (kb []byte, err error) {
 	if kb, err = io.ReadAll(kf); err != nil {
 		return
 	}
+	if len(kb) == 0 {
+		err = fmt.Errorf("%s: %w", args[0], fortifier.ErrEmptyKey)
+	}
+	return
+}
+
+func isDir(name string) bool {
+	stat, err := os.Stat(name)
+	return err == nil && stat.IsDir()
+}
+
+func isFile(name string) bool {
+	stat, err := os.Stat(name)
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// readKeyDir reads the regular files of the directory as a keyring of private
+// keys, skipping empty ones.
+func readKeyDir(dir string) (keys [][]byte, err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(dir); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := filepath.Join(dir, entry.Name())
+		var info os.FileInfo
+		if info, err = entry.Info(); err != nil {
+			return
+		}
+		if info.Size() == 0 {
+			if flagVerbose {
+				fmt.Printf("Skip empty key file: %s\n", name)
+			}
+			continue
+		}
+		var kb []byte
+		if kb, err = readKeyFile([]string{name}); err != nil {
+			return
+		}
+		keys = append(keys, kb)
+	}
 	return
 }
 
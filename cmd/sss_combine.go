@@ -11,6 +11,7 @@ import (
 )
 
 var flagSssCombineOut string
+var flagSssCombineSecureOutput bool
 
 func init() {
 	c := &cobra.Command{
@@ -30,6 +31,8 @@ Required Arguments:
 	initFlagVerbose(c)
 	c.Flags().StringVarP(&flagSssCombineOut, "out", "o", "",
 		"[Required] Specify the output file for the recovered original data")
+	c.Flags().BoolVarP(&flagSssCombineSecureOutput, "secure-output", "", false,
+		"Refuse to write the output into a directory writable by group or others")
 	ssss.AddCommand(c)
 }
 
@@ -39,5 +42,8 @@ func sssCombineRunE(_ *cobra.Command, args []string) error {
 	if len(file) == 0 {
 		return errors.New("empty path of the output file")
 	}
+	if flagSssCombineSecureOutput {
+		return sss.CombinePartFilesSecure(args, file, flagTruncate, flagVerbose)
+	}
 	return sss.CombinePartFiles(args, file, flagTruncate, flagVerbose)
 }
@@ -0,0 +1,32 @@
+package files
+
+import (
+	"fmt"
+	"os"
+)
+
+// OpenOutputFd opens the inherited file descriptor fd for writing secret
+// output which must never hit the disk, such as the write end of a pipe to a
+// child process. The descriptor must be open for writing and be a pipe or a
+// socket: regular files, directories and terminals are refused with
+// ErrInsecureOutputPermissions, and so are named pipes accessible by group or
+// others. closeFn closes the descriptor, which is closed on failure too.
+func OpenOutputFd(fd uintptr) (file *os.File, closeFn func(), err error) {
+	if file = os.NewFile(fd, fmt.Sprintf("fd %d", fd)); file == nil {
+		return nil, nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	if err = checkSecretFd(file); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "%s <-- open\n", file.Name())
+	}
+	closeFn = func() {
+		_ = file.Close()
+		if verbose {
+			fmt.Fprintf(os.Stderr, "%s <-- close\n", file.Name())
+		}
+	}
+	return
+}
@@ -0,0 +1,67 @@
+//go:build unix && !windows
+
+package files
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOpenOutputFd(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	fd, err := syscall.Dup(int(w.Fd()))
+	_ = w.Close()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, closeFn, err := OpenOutputFd(uintptr(fd))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	go func() {
+		defer closeFn()
+		_, _ = out.Write([]byte("recovered plaintext"))
+	}()
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(plaintext) != "recovered plaintext" {
+		t.Fatalf("bad: %q", plaintext)
+	}
+
+	// the read end is not open for writing
+	if fd, err = syscall.Dup(int(r.Fd())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, err = OpenOutputFd(uintptr(fd)); err == nil || errors.Is(err, ErrInsecureOutputPermissions) {
+		t.Fatalf("expect not open for writing, got %v", err)
+	}
+	// the refused descriptor is closed
+	if err = syscall.Close(fd); !errors.Is(err, syscall.EBADF) {
+		t.Fatalf("expect EBADF, got %v", err)
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "plain"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	if fd, err = syscall.Dup(int(f.Fd())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, err = OpenOutputFd(uintptr(fd)); !errors.Is(err, ErrInsecureOutputPermissions) {
+		t.Fatalf("expect ErrInsecureOutputPermissions, got %v", err)
+	}
+	if err = syscall.Close(fd); !errors.Is(err, syscall.EBADF) {
+		t.Fatalf("expect EBADF, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build unix && !windows
+
+package files
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func checkSecretFd(file *os.File) error {
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	switch mode := stat.Mode(); {
+	case mode&os.ModeNamedPipe != 0:
+		if mode.Perm()&0077 != 0 {
+			return fmt.Errorf("%w: %s is a pipe accessible by group or others", ErrInsecureOutputPermissions, file.Name())
+		}
+	case mode&os.ModeSocket != 0:
+	default:
+		return fmt.Errorf("%w: %s is %s, not a pipe or socket", ErrInsecureOutputPermissions, file.Name(), fileKind(mode))
+	}
+	flags, err := unix.FcntlInt(file.Fd(), unix.F_GETFL, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Name(), err)
+	}
+	if flags&(unix.O_WRONLY|unix.O_RDWR) == 0 {
+		return fmt.Errorf("%s is not open for writing", file.Name())
+	}
+	return nil
+}
+
+func fileKind(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "a regular file"
+	case mode.IsDir():
+		return "a directory"
+	case mode&os.ModeCharDevice != 0:
+		return "a character device"
+	default:
+		return "of type " + mode.Type().String()
+	}
+}
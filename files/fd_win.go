@@ -0,0 +1,23 @@
+//go:build windows && !unix
+
+package files
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// checkSecretFd requires a pipe on Windows, where the access to the handle is
+// governed by its ACL rather than permission bits.
+func checkSecretFd(file *os.File) error {
+	kind, err := windows.GetFileType(windows.Handle(file.Fd()))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Name(), err)
+	}
+	if kind != windows.FILE_TYPE_PIPE {
+		return fmt.Errorf("%w: %s is not a pipe", ErrInsecureOutputPermissions, file.Name())
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,8 @@ import (
 	"sync"
 )
 
+var ErrInsecureOutputPermissions = errors.New("insecure output permissions")
+
 var verbose bool
 var verboseSetOnce sync.Once
 
@@ -65,6 +68,19 @@ func OpenOutputFile(name string, truncate bool, flags ...int) (file *os.File, cl
 	return
 }
 
+// OpenSecretOutputFile opens the output file like OpenOutputFile, refusing
+// directories writable by group or others and existing files accessible by them.
+func OpenSecretOutputFile(name string, truncate bool, flags ...int) (file *os.File, closeFn func(), err error) {
+	var path string
+	if path, err = filepath.Abs(strings.TrimSpace(name)); err != nil {
+		return
+	}
+	if err = checkSecretOutput(path); err != nil {
+		return
+	}
+	return OpenOutputFile(name, truncate, flags...)
+}
+
 func openForRead(name string) (*os.File, error) {
 	var (
 		err  error
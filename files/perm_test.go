@@ -0,0 +1,47 @@
+//go:build unix && !windows
+
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSecretOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, closeFn, err := OpenSecretOutputFile(filepath.Join(dir, "secret"), false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	closeFn()
+	stat, err := os.Stat(filepath.Join(dir, "secret"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0600 {
+		t.Fatalf("bad perm: %o", perm)
+	}
+
+	loose := filepath.Join(dir, "loose")
+	if err = os.WriteFile(loose, nil, 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = os.Chmod(loose, 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, err = OpenSecretOutputFile(loose, true); !errors.Is(err, ErrInsecureOutputPermissions) {
+		t.Fatalf("expect ErrInsecureOutputPermissions, got %v", err)
+	}
+
+	if err = os.Chmod(dir, 0777); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, err = OpenSecretOutputFile(filepath.Join(dir, "other"), false); !errors.Is(err, ErrInsecureOutputPermissions) {
+		t.Fatalf("expect ErrInsecureOutputPermissions, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build unix && !windows
+
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func checkSecretOutput(path string) error {
+	dir := filepath.Dir(path)
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if stat.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%w: %s is group or world writable", ErrInsecureOutputPermissions, dir)
+	}
+	if stat, err = os.Stat(path); err == nil && stat.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%w: %s is accessible by group or others", ErrInsecureOutputPermissions, path)
+	}
+	return nil
+}
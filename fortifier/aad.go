@@ -0,0 +1,45 @@
+package fortifier
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const aadLabel = "fortify additional data"
+
+// ErrAADMismatch means the additional data presented on recovery differs from
+// the additional data the file was bound to, or only one side has any.
+var ErrAADMismatch = errors.New("additional data mismatch")
+
+// SetAAD binds the fortified file to caller supplied additional data, such as a
+// document ID, which must be presented identically with SetAAD on recovery.
+// Only a digest keyed by the cipher key is recorded in the metadata, which the
+// head checksum authenticates. A nil aad binds nothing.
+func (f *Fortifier) SetAAD(aad []byte) {
+	f.aad = aad
+}
+
+func (f *Fortifier) aadDigest(aad []byte) string {
+	h := f.key.NewSha256()
+	h.Write([]byte(aadLabel))
+	h.Write(aad)
+	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// checkAAD checks the additional data of SetAAD against the digest of the
+// authenticated metadata.
+func (f *Fortifier) checkAAD(meta *Metadata) error {
+	switch {
+	case f.aadChecked || meta.AAD == "" && f.aad == nil:
+		return nil
+	case meta.AAD == "":
+		return fmt.Errorf("%w: file bound to no additional data", ErrAADMismatch)
+	case f.aad == nil:
+		return fmt.Errorf("%w: file bound to additional data, none presented", ErrAADMismatch)
+	case !hmac.Equal([]byte(meta.AAD), []byte(f.aadDigest(f.aad))):
+		return ErrAADMismatch
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func testDecryptAADFile(t testing.TB, path string, pri, aad []byte) ([]byte, error) {
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetAAD(aad)
+	var out bytes.Buffer
+	if err := NewDecrypter(layout.Metadata().Mode, f).Decrypt(in, &out, layout); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func TestAAD(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	dir := t.TempDir()
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetAAD([]byte("document-42"))
+	plaintext := []byte("bound to its document")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, dir, plaintext)
+	head, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Contains(head, []byte("document-42")) {
+		t.Fatalf("additional data stored in the head")
+	}
+
+	if actual, err := testDecryptAADFile(t, encrypted, pri, []byte("document-42")); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	for _, aad := range [][]byte{[]byte("document-43"), nil} {
+		if _, err = testDecryptAADFile(t, encrypted, pri, aad); !errors.Is(err, ErrAADMismatch) {
+			t.Fatalf("expect ErrAADMismatch for %q, got %v", aad, err)
+		}
+	}
+
+	in, layout := testReadLayout(t, encrypted)
+	defer func() { _ = in.Close() }()
+	f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetAAD([]byte("document-42"))
+	raw, err := f.RecoverRaw(layout)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	if err = DecryptPayload(raw, layout, in, &out); err != nil || !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q, err: %v", out.Bytes(), err)
+	}
+
+	unbound := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	if _, err = testDecryptAADFile(t, unbound, pri, []byte("document-42")); !errors.Is(err, ErrAADMismatch) {
+		t.Fatalf("expect ErrAADMismatch, got %v", err)
+	}
+}
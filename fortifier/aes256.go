@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"time"
@@ -15,6 +17,16 @@ import (
 
 const defaultReaderBufferSize = 128 * 1024
 const defaultWriterBufferSize = 256 * 1024
+const deterministicIvLabel = "fortify deterministic iv"
+
+// ErrDecryptFailed means the cipher key was not recovered: the key does not
+// unwrap it, or the recovered key does not authenticate the file head.
+var ErrDecryptFailed = errors.New("key unwrap failed")
+
+// ErrPayloadAuthFailed means the cipher key was recovered and authenticates the
+// file head, but the payload fails its integrity check: it is truncated,
+// corrupted or does not belong to the head.
+var ErrPayloadAuthFailed = errors.New("key unwrap ok, payload integrity failed")
 
 type Aes256StreamEncrypter struct {
 	*Fortifier
@@ -32,7 +44,10 @@ func (f *Aes256StreamEncrypter) EncryptFile(in, out *os.File, mode CipherMode) (
 		fmt.Printf("%s O-->* %s %d bytes [%s %s]\n", in.Name(), out.Name(), stat.Size(), f.meta.Key, f.meta.Mode)
 	}
 	started := time.Now()
-	layout := &FileLayout{metadata: f.meta}
+	var layout *FileLayout
+	if layout, err = f.newLayout(); err != nil {
+		return
+	}
 	if err = f.Encrypt(in, out, layout, mode); err != nil {
 		return
 	}
@@ -42,13 +57,51 @@ func (f *Aes256StreamEncrypter) EncryptFile(in, out *os.File, mode CipherMode) (
 	return
 }
 
+// newLayout makes the layout of the head of a file fortified with the metadata,
+// sealing the metadata if enabled.
+func (f *Fortifier) newLayout() (layout *FileLayout, err error) {
+	if f.meta.Producer == "" {
+		f.meta.Producer = DefaultProducer
+	}
+	f.meta.Suite = Suite{Key: f.meta.Key, Scheme: f.rsaScheme, Mode: f.meta.Mode}.String()
+	f.meta.AAD = ""
+	if f.aad != nil {
+		f.meta.AAD = f.aadDigest(f.aad)
+	}
+	f.meta.Index = nil
+	if f.index != nil {
+		if f.meta.Index, err = f.sealIndex(); err != nil {
+			return nil, err
+		}
+	}
+	layout = &FileLayout{metadata: f.meta, compress: f.compressMeta}
+	if f.sealMeta {
+		if layout.metadata, err = f.sealMetadata(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
 func (f *Aes256StreamEncrypter) Encrypt(
 	in io.Reader, out io.WriteSeeker, layout *FileLayout, mode CipherMode) (err error) {
+	if err = checkChunkSize(f.meta.Chunk); err != nil {
+		return
+	}
+	readerSize, writerSize := chunkSizes(f.meta.Chunk)
 	iv := make([]byte, f.block.BlockSize())
-	if _, err = rand.Read(iv); err != nil {
+	if f.meta.Deterministic {
+		if iv, err = f.deriveIv(in, mode); err != nil {
+			return
+		}
+	} else if _, err = rand.Read(iv); err != nil {
+		return
+	}
+	ir := bufio.NewReaderSize(in, readerSize)
+	if err = f.checkNesting(ir); err != nil {
 		return
 	}
-	ow := bufio.NewWriterSize(out, defaultWriterBufferSize)
+	ow := bufio.NewWriterSize(out, writerSize)
 	if err = layout.WriteHeadOut(ow); err != nil {
 		return
 	}
@@ -57,20 +110,21 @@ func (f *Aes256StreamEncrypter) Encrypt(
 	}
 	check := f.key.NewSha256()
 	check.Write(iv)
-	stream := mode.SteamMaker(f.block, iv)
-	writer := io.MultiWriter(check, cipher.StreamWriter{S: stream, W: ow})
-	ir := bufio.NewReaderSize(in, defaultReaderBufferSize)
+	var stream cipher.Stream
+	if stream, err = f.newStream(mode, iv, f.meta.Segment); err != nil {
+		return
+	}
+	// enciphered in place as read, unlike cipher.StreamWriter copying every write
+	reader := cipher.StreamReader{S: stream, R: io.TeeReader(ir, check)}
 	var cnt int64
-	if cnt, err = io.Copy(writer, ir); err != nil {
+	if cnt, err = io.Copy(ow, reader); err != nil {
 		return
 	}
 	if err = ow.Flush(); err != nil {
 		return
 	}
-	if file, ok := out.(*os.File); ok {
-		if err = file.Sync(); err != nil {
-			return
-		}
+	if err = syncFile(out); err != nil {
+		return
 	}
 	if err = layout.WriteHeadPlaceHolders(out, f.key, check, cnt); err != nil {
 		return
@@ -78,11 +132,67 @@ func (f *Aes256StreamEncrypter) Encrypt(
 	return
 }
 
+// SetDeterministicIv enables deriving the IV from an HMAC of the plaintext under
+// the cipher key instead of generating it randomly. Encrypting identical data with
+// the same key then yields identical ciphertext, which reveals their equality.
+func (f *Fortifier) SetDeterministicIv(b bool) {
+	f.meta.Deterministic = b
+}
+
+// deriveIv computes the IV over the whole remaining input and rewinds it. Only
+// CTR mode is accepted, as a plain SIV construction over a counter stream.
+func (f *Aes256StreamEncrypter) deriveIv(in io.Reader, mode CipherMode) ([]byte, error) {
+	if mode.Name != CipherModeAes256CTR {
+		return nil, fmt.Errorf("deterministic iv requires cipher mode %s, not %s", CipherModeAes256CTR, mode.Name)
+	}
+	rs, ok := in.(io.ReadSeeker)
+	if !ok {
+		return nil, errors.New("deterministic iv requires a seekable input")
+	}
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	derive := f.key.NewSha256()
+	derive.Write([]byte(deterministicIvLabel))
+	if _, err = io.Copy(derive, bufio.NewReaderSize(rs, defaultReaderBufferSize)); err != nil {
+		return nil, err
+	}
+	if _, err = rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return derive.Sum(nil)[:f.block.BlockSize()], nil
+}
+
+// AuthenticateMetadata recovers the cipher key and checks it against the head
+// checksum, which covers the whole metadata, timestamps included, then opens
+// sealed metadata and checks the additional data of SetAAD. Once it succeeds
+// the metadata of the layout can be trusted, e.g. to enforce a maximum age,
+// before the payload is decrypted.
+func (f *Fortifier) AuthenticateMetadata(layout *FileLayout) error {
+	if err := f.checkKeyKind(layout.Metadata()); err != nil {
+		return err
+	}
+	if err := f.SetupKey(); err != nil {
+		return fmt.Errorf("%w: %w", ErrDecryptFailed, err)
+	}
+	if !bytes.Equal(layout.headChecksum, layout.makeChecksumHead(f.key)) {
+		return fmt.Errorf("%w: invalid checksum of meta", ErrDecryptFailed)
+	}
+	if err := f.OpenMetadata(layout); err != nil {
+		return err
+	}
+	return f.checkAAD(layout.Metadata())
+}
+
 type Aes256StreamDecrypter struct {
 	*Fortifier
 }
 
 func (f *Aes256StreamDecrypter) DecryptFile(in, out *os.File, layout *FileLayout, mode CipherMode) (err error) {
+	if err = f.checkKeyKind(layout.Metadata()); err != nil {
+		return
+	}
 	if err = f.SetupKey(); err != nil {
 		return
 	}
@@ -105,38 +215,56 @@ func (f *Aes256StreamDecrypter) DecryptFile(in, out *os.File, layout *FileLayout
 }
 
 func (f *Aes256StreamDecrypter) Decrypt(in io.Reader, w io.Writer, layout *FileLayout, mode CipherMode) (err error) {
-	if err = f.SetupKey(); err != nil {
+	if err = checkChunkSize(layout.Metadata().Chunk); err != nil {
 		return
 	}
-	expect := layout.headChecksum
-	actual := layout.makeChecksumHead(f.key)
-	if !bytes.Equal(expect, actual) {
-		return errors.New("invalid checksum of meta")
+	if err = f.AuthenticateMetadata(layout); err != nil {
+		return
 	}
 	meta := layout.Metadata()
 	if meta.Mode != mode.Name {
 		return fmt.Errorf("requires cipher mode: %s", meta.Mode)
 	}
-	if f.meta.Sss != nil && meta.Sss.Digest != f.meta.Sss.Digest {
-		return errors.New("mismatched key digest")
+	if f.meta.Sss != nil && (meta.Sss == nil || meta.Sss.Digest != f.meta.Sss.Digest) {
+		return fmt.Errorf("%w: mismatched key digest", ErrDecryptFailed)
+	}
+	if err = meta.checkLabels(); err != nil {
+		return
 	}
 	f.meta.Mode = meta.Mode
 	f.meta.Timestamp = meta.Timestamp
+	f.meta.Description, f.meta.Tags = meta.Description, meta.Tags
+	f.meta.Producer = meta.Producer
+	if err = checkChunkSize(meta.Chunk); err != nil {
+		return
+	}
+	readerSize, writerSize := chunkSizes(meta.Chunk)
 	iv := make([]byte, f.block.BlockSize())
-	ir := bufio.NewReaderSize(in, defaultReaderBufferSize)
+	ir := bufio.NewReaderSize(in, readerSize)
 	if err = binary.Read(ir, layoutByteOrder, iv); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: missing iv", ErrPayloadAuthFailed)
+		}
 		return
 	}
 	check := f.key.NewSha256()
-	stream := mode.SteamMaker(f.block, iv)
-	var writer io.Writer
+	var stream cipher.Stream
+	if stream, err = f.newStream(mode, iv, meta.Segment); err != nil {
+		return
+	}
+	writers := []io.Writer{check}
 	var ow *bufio.Writer
 	if w != nil {
-		ow = bufio.NewWriterSize(w, defaultWriterBufferSize)
-		writer = io.MultiWriter(ow, check)
-	} else {
-		writer = check
+		ow = bufio.NewWriterSize(w, writerSize)
+		writers = append(writers, ow)
+	}
+	var derive hash.Hash
+	if meta.Deterministic {
+		derive = f.key.NewSha256()
+		derive.Write([]byte(deterministicIvLabel))
+		writers = append(writers, derive)
 	}
+	writer := io.MultiWriter(writers...)
 	reader := cipher.StreamReader{S: stream, R: ir}
 	check.Write(iv)
 	var cnt int64
@@ -144,22 +272,33 @@ func (f *Aes256StreamDecrypter) Decrypt(in io.Reader, w io.Writer, layout *FileL
 		return
 	}
 	if uint64(cnt) != layout.dataLength {
-		return fmt.Errorf("expect data length is %d, not %d\n", layout.dataLength, cnt)
+		return fmt.Errorf("%w: expect data length is %d, not %d", ErrPayloadAuthFailed, layout.dataLength, cnt)
 	}
 	check.Write(layout.headChecksum)
 	sum := check.Sum(nil)
 	if !bytes.Equal(layout.checksum, sum) {
-		return errors.New("invalid checksum of file")
+		return fmt.Errorf("%w: invalid checksum of file", ErrPayloadAuthFailed)
+	}
+	if derive != nil && !hmac.Equal(iv, derive.Sum(nil)[:len(iv)]) {
+		return fmt.Errorf("%w: invalid deterministic iv", ErrPayloadAuthFailed)
 	}
 	if ow != nil {
 		if err = ow.Flush(); err != nil {
 			return
 		}
 	}
-	if file, ok := w.(*os.File); ok {
-		if err = file.Sync(); err != nil {
-			return
-		}
+	return syncFile(w)
+}
+
+// syncFile commits the output to stable storage if it is a regular file, not
+// a pipe or socket, which cannot be synced.
+func syncFile(w io.Writer) error {
+	file, ok := w.(*os.File)
+	if !ok {
+		return nil
 	}
-	return
+	if stat, err := file.Stat(); err != nil || !stat.Mode().IsRegular() {
+		return err
+	}
+	return file.Sync()
 }
@@ -0,0 +1,225 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+// RsaSchemeAgeSSH wraps the cipher key into an age file for the ssh-rsa or
+// ssh-ed25519 recipients of AddAgeRecipient, which `age -d -i ~/.ssh/id_ed25519`
+// decrypts. The ciphertext of each recipient entry is its stanza of the age
+// header, the rest of the age file is shared in Metadata.Age, see AgeHeader.
+const RsaSchemeAgeSSH RsaScheme = "age-ssh"
+
+// ageRecipient is an SSH public key used as an age recipient.
+type ageRecipient struct {
+	age.Recipient
+	pub     crypto.PublicKey
+	comment string
+}
+
+// AddAgeRecipient adds an SSH public key in the authorized_keys format, such as
+// the line of ~/.ssh/id_ed25519.pub, as an age recipient: the cipher key is
+// wrapped with age's ssh-rsa or ssh-ed25519 recipient, so that the file is
+// recoverable with the SSH private key by this package as well as by age.
+// It requires cipher key kind RSA, whose other recipients it joins.
+func (f *Fortifier) AddAgeRecipient(sshKey []byte) error {
+	line := strings.TrimSpace(string(sshKey))
+	r, err := agessh.ParseRecipient(line)
+	if err != nil {
+		return fmt.Errorf("%s: age recipient: %w", rsaFortifier, err)
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return fmt.Errorf("%s: age recipient: %w", rsaFortifier, err)
+	}
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		return fmt.Errorf("%s: age recipient: unsupported key type %s", rsaFortifier, pub.Type())
+	}
+	f.ageRecipients = append(f.ageRecipients, ageRecipient{Recipient: r, pub: cpk.CryptoPublicKey(), comment: comment})
+	return nil
+}
+
+// MetadataAge holds what the age-ssh recipients share of the single age file
+// wrapping the cipher key for all of them, whose header AgeHeader rebuilds from
+// the stanzas of the recipients.
+type MetadataAge struct {
+	// MAC is the header MAC, as written on the last line of the header
+	MAC string `json:"mac"`
+	// Payload is the URL base64 age payload: the cipher key encrypted under the file key
+	Payload string `json:"payload"`
+}
+
+const ageIntro = "age-encryption.org/v1\n"
+
+// newAgeEntries makes the recipient entries of the age recipients, which
+// wrapAgeSSH fills in with their stanzas.
+func (f *Fortifier) newAgeEntries(raw []byte) []*MetadataRsa {
+	entries := make([]*MetadataRsa, len(f.ageRecipients))
+	for i, r := range f.ageRecipients {
+		entries[i] = &MetadataRsa{Timestamp: time.Now(), Digest: utils.ComputeDigest(raw), Scheme: RsaSchemeAgeSSH}
+		f.identify(entries[i], r.pub)
+		if !f.keyHint {
+			entries[i].Label = r.comment
+		}
+	}
+	return entries
+}
+
+// wrapAgeSSH wraps the cipher key into one age file for the age recipients of
+// the entries, in the order the entries take among the recipients, and stores
+// the stanza of each recipient as the ciphertext of its entry.
+func (f *Fortifier) wrapAgeSSH(recipients, entries []*MetadataRsa, raw []byte) (*MetadataAge, error) {
+	var ordered []*MetadataRsa
+	var ageRecipients []age.Recipient
+	for _, m := range recipients {
+		if i := slices.Index(entries, m); i >= 0 {
+			ordered, ageRecipients = append(ordered, m), append(ageRecipients, f.ageRecipients[i].Recipient)
+		}
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, ageRecipients...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: age recipient: %w", rsaFortifier, err)
+	}
+	if _, err = w.Write(raw); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	stanzas, shared, err := splitAgeFile(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(stanzas) != len(ordered) {
+		return nil, fmt.Errorf("%s: %d age recipients wrapped into %d stanzas", rsaFortifier, len(ordered), len(stanzas))
+	}
+	for i, m := range ordered {
+		m.Ciphertext = base64.URLEncoding.EncodeToString(stanzas[i])
+		m.CiphertextDigest = utils.ComputeDigest(stanzas[i])
+	}
+	return shared, nil
+}
+
+// splitAgeFile splits an age file into the stanzas of its header, and the
+// header MAC and payload.
+func splitAgeFile(file []byte) (stanzas [][]byte, shared *MetadataAge, err error) {
+	rest, ok := bytes.CutPrefix(file, []byte(ageIntro))
+	if !ok {
+		return nil, nil, errors.New("not an age file")
+	}
+	for {
+		line, after, ok := bytes.Cut(rest, []byte("\n"))
+		if !ok {
+			return nil, nil, errors.New("truncated age header")
+		}
+		line = append(line[:len(line):len(line)], '\n')
+		switch {
+		case bytes.HasPrefix(line, []byte("-> ")):
+			stanzas = append(stanzas, line)
+		case bytes.HasPrefix(line, []byte("--- ")):
+			mac := strings.TrimSuffix(string(line[len("--- "):]), "\n")
+			return stanzas, &MetadataAge{MAC: mac, Payload: base64.URLEncoding.EncodeToString(after)}, nil
+		case len(stanzas) > 0:
+			stanzas[len(stanzas)-1] = append(stanzas[len(stanzas)-1], line...)
+		default:
+			return nil, nil, errors.New("age header body without a stanza")
+		}
+		rest = after
+	}
+}
+
+// AgeHeader returns the age header of the age-ssh recipients of the metadata,
+// byte for byte as age wrote it: the version line, the stanza of every age-ssh
+// recipient in turn and the header MAC. Followed by the decoded payload of
+// Metadata.Age, it makes an age file of the cipher key which any age
+// implementation decrypts with the SSH private key of one of the recipients.
+// It returns nil if the metadata has no age-ssh recipients.
+func AgeHeader(meta *Metadata) ([]byte, error) {
+	if meta.Age == nil {
+		return nil, nil
+	}
+	header := []byte(ageIntro)
+	for _, m := range meta.rsaRecipients() {
+		if m.Scheme != RsaSchemeAgeSSH {
+			continue
+		}
+		stanza, err := base64.URLEncoding.DecodeString(m.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("%w: age stanza: %v", ErrCorruptedRecipient, err)
+		}
+		header = append(header, stanza...)
+	}
+	return append(header, "--- "+meta.Age.MAC+"\n"...), nil
+}
+
+// ageFile returns the age file the stanza of an age-ssh recipient belongs to,
+// or the stanza itself for the whole age file of a single recipient, written
+// before the age-ssh recipients shared Metadata.Age.
+func (m *Metadata) ageFile(stanza []byte) ([]byte, error) {
+	if m.Age == nil {
+		return stanza, nil
+	}
+	header, err := AgeHeader(m)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := base64.URLEncoding.DecodeString(m.Age.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: age payload: %v", ErrCorruptedRecipient, err)
+	}
+	return append(header, payload...), nil
+}
+
+// unwrapAgeSSH decrypts the age file of an age-ssh recipient entry with the identity.
+func unwrapAgeSSH(identity age.Identity, ciphertext []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// unwrapAgeSSHRsa decrypts the age file of an ssh-rsa age recipient.
+func unwrapAgeSSHRsa(pri *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	identity, err := agessh.NewRSAIdentity(pri)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapAgeSSH(identity, ciphertext)
+}
+
+// setupEd25519PrivateKey recovers the cipher key from the ssh-ed25519 age
+// recipients, the only recipients an Ed25519 key can be.
+func (f *Fortifier) setupEd25519PrivateKey(pri ed25519.PrivateKey) error {
+	if err := f.policy.checkDigests(f.meta.rsaRecipients()); err != nil {
+		return fmt.Errorf("%s: %w", rsaFortifier, err)
+	}
+	identity, err := agessh.NewEd25519Identity(pri)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rsaFortifier, err)
+	}
+	return f.recoverFromRecipients(PublicKeyFingerprint(pri.Public()), func(m *MetadataRsa, ciphertext []byte) ([]byte, error) {
+		if m.Scheme != RsaSchemeAgeSSH {
+			return nil, errors.New("ed25519 private key cannot unwrap rsa recipients")
+		}
+		return unwrapAgeSSH(identity, ciphertext)
+	})
+}
@@ -0,0 +1,187 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+func testEd25519Ssh(t testing.TB) (authorized, pri []byte, key ed25519.PrivateKey) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return ssh.MarshalAuthorizedKey(testSshPublicKey(t, pub)), pem.EncodeToMemory(block), key
+}
+
+// testAgeDecrypt decrypts the age file of the metadata as `age -d -i` does.
+func testAgeDecrypt(t testing.TB, meta *Metadata, identity age.Identity) []byte {
+	header, err := AgeHeader(meta)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	payload, err := base64.URLEncoding.DecodeString(meta.Age.Payload)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(append(header, payload...)), identity)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return raw
+}
+
+func TestAgeRecipient(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	authorized, edPri, edKey := testEd25519Ssh(t)
+	rsaAuthorized := ssh.MarshalAuthorizedKey(testSshPublicKey(t, &testRsaPrivateKey(t).PublicKey))
+
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.AddAgeRecipient(authorized); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.AddAgeRecipient(rsaAuthorized); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("readable by age")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, encrypted)
+	_ = in.Close()
+	recipients := layout.Metadata().Recipients
+	if len(recipients) != 3 || recipients[1].Scheme != RsaSchemeAgeSSH || recipients[2].Scheme != RsaSchemeAgeSSH {
+		t.Fatalf("unexpected recipients: %+v", recipients)
+	}
+	if recipients[1].Fingerprint != PublicKeyFingerprint(edKey.Public()) {
+		t.Fatalf("unexpected fingerprint: %s", recipients[1].Fingerprint)
+	}
+
+	edIdentity, err := agessh.NewEd25519Identity(edKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rsaIdentity, err := agessh.NewRSAIdentity(testRsaPrivateKey(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, identity := range []age.Identity{edIdentity, rsaIdentity} {
+		if raw := testAgeDecrypt(t, layout.Metadata(), identity); utils.ComputeDigest(raw) != recipients[0].Digest {
+			t.Fatalf("age recovered another key with identity %d", i)
+		}
+	}
+
+	for _, key := range [][]byte{edPri, pri} {
+		actual, err := testDecryptRsaFile(t, encrypted, key)
+		if err != nil || !bytes.Equal(actual, plaintext) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+	}
+	f = NewFortifierWithRsa(false, layout.Metadata(), edPri)
+	if err := f.SetRecoverScheme(RsaSchemeOAEP); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestAgeHeader(t *testing.T) {
+	authorized, _, _ := testEd25519Ssh(t)
+	edRecipient, err := agessh.ParseRecipient(string(authorized))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rsaRecipient, err := agessh.NewRSARecipient(testSshPublicKey(t, &testRsaPrivateKey(t).PublicKey))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var file bytes.Buffer
+	w, err := age.Encrypt(&file, edRecipient, rsaRecipient)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, _ = w.Write([]byte("cipher key"))
+	if err = w.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stanzas, shared, err := splitAgeFile(file.Bytes())
+	if err != nil || len(stanzas) != 2 {
+		t.Fatalf("bad: %d stanzas, err: %v", len(stanzas), err)
+	}
+	meta := &Metadata{Age: shared, Recipients: []*MetadataRsa{
+		{Scheme: RsaSchemeAgeSSH, Ciphertext: base64.URLEncoding.EncodeToString(stanzas[0])},
+		{Scheme: RsaSchemeOAEP, Ciphertext: "not a stanza"},
+		{Scheme: RsaSchemeAgeSSH, Ciphertext: base64.URLEncoding.EncodeToString(stanzas[1])},
+	}}
+	header, err := AgeHeader(meta)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	payload, _ := base64.URLEncoding.DecodeString(shared.Payload)
+	if expected := file.Bytes()[:file.Len()-len(payload)]; !bytes.Equal(header, expected) {
+		t.Fatalf("header %q, age wrote %q", header, expected)
+	}
+	if header, err = AgeHeader(&Metadata{}); err != nil || header != nil {
+		t.Fatalf("bad: %q, err: %v", header, err)
+	}
+	if _, _, err = splitAgeFile([]byte("-> X25519 stanza\n")); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestAgeRecipientOnly(t *testing.T) {
+	authorized, edPri, _ := testEd25519Ssh(t)
+	f := NewFortifierWithRsa(false, nil, nil)
+	if err := f.AddAgeRecipient(authorized); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("only age")
+	encrypted := testEncryptFile(t, f, CipherModeAes256OFB, t.TempDir(), plaintext)
+	actual, err := testDecryptRsaFile(t, encrypted, edPri)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	_, other, _ := testEd25519Ssh(t)
+	if _, err = testDecryptRsaFile(t, encrypted, other); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestAgeRecipientInvalid(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.AddAgeRecipient(pub); err == nil {
+		t.Fatalf("expect error")
+	}
+	authorized, _, _ := testEd25519Ssh(t)
+	f = NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+	if err := f.AddAgeRecipient(authorized); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+	f = NewFortifierWithRsa(false, nil, pub)
+	f.SetFIPSMode(true)
+	_ = f.AddAgeRecipient(authorized)
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,167 @@
+package fortifier
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ContainerArmor is the binary format in base64 lines between the armor header
+// and footer, for channels carrying text only, see NewArmorWriter
+const ContainerArmor ContainerFormat = "armor"
+
+const (
+	armorBegin     = "-----BEGIN FORTIFY-----"
+	armorEnd       = "-----END FORTIFY-----"
+	armorLineWidth = 64
+)
+
+// ErrInvalidArmor means the input starts with the armor header but is not a
+// well-formed armored fortified file.
+var ErrInvalidArmor = errors.New("invalid armor")
+
+// ReadContainer reads the head of a fortified file in the binary or armored
+// format, telling them apart by the armor header, and returns the reader of the
+// payload following it. For the binary format, the payload reader is r itself,
+// positioned right after the head.
+func ReadContainer(r io.Reader) (*FileLayout, io.Reader, error) {
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = ErrNotFortified
+		}
+		return nil, nil, err
+	}
+	payload := r
+	if bytes.Equal(prefix, []byte(armorBegin[:len(prefix)])) {
+		var err error
+		if payload, err = dearmor(bufio.NewReader(r)); err != nil {
+			return nil, nil, err
+		}
+		prefix = nil
+	}
+	layout := &FileLayout{}
+	if err := layout.ReadHeadIn(io.MultiReader(bytes.NewReader(prefix), payload)); err != nil {
+		return nil, nil, err
+	}
+	return layout, payload, nil
+}
+
+// dearmor checks the rest of the armor header line, whose first bytes were
+// read, and decodes the base64 lines up to the footer.
+func dearmor(br *bufio.Reader) (io.Reader, error) {
+	line, err := br.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if armorBegin[4:] != string(bytes.TrimRight([]byte(line), "\r\n")) {
+		return nil, fmt.Errorf("%w: bad header", ErrInvalidArmor)
+	}
+	return base64.NewDecoder(base64.StdEncoding, &armorBody{r: br}), nil
+}
+
+// armorBody reads the base64 lines of the armor, stripped of line endings and
+// whitespace, up to the footer line.
+type armorBody struct {
+	r    *bufio.Reader
+	line []byte
+	done bool
+}
+
+func (a *armorBody) Read(p []byte) (int, error) {
+	for len(a.line) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		line, err := a.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		trimmed := bytes.TrimSpace(line)
+		if string(trimmed) == armorEnd {
+			a.done = true
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("%w: missing footer", ErrInvalidArmor)
+		}
+		a.line = trimmed
+	}
+	n := copy(p, a.line)
+	a.line = a.line[n:]
+	return n, nil
+}
+
+// NewArmorWriter returns a writer armoring what is written to it into w: the
+// armor header, the base64 lines of 64 characters, and on Close, which does not
+// close w, the footer. ReadContainer reads the armored file back.
+func NewArmorWriter(w io.Writer) io.WriteCloser {
+	lines := &armorLines{w: w}
+	return &armorWriter{w: w, lines: lines, enc: base64.NewEncoder(base64.StdEncoding, lines)}
+}
+
+type armorWriter struct {
+	w       io.Writer
+	lines   *armorLines
+	enc     io.WriteCloser
+	started bool
+}
+
+func (a *armorWriter) start() error {
+	if a.started {
+		return nil
+	}
+	a.started = true
+	_, err := io.WriteString(a.w, armorBegin+"\n")
+	return err
+}
+
+func (a *armorWriter) Write(p []byte) (int, error) {
+	if err := a.start(); err != nil {
+		return 0, err
+	}
+	return a.enc.Write(p)
+}
+
+func (a *armorWriter) Close() error {
+	if err := a.start(); err != nil {
+		return err
+	}
+	if err := a.enc.Close(); err != nil {
+		return err
+	}
+	if a.lines.column > 0 {
+		if _, err := io.WriteString(a.w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(a.w, armorEnd+"\n")
+	return err
+}
+
+// armorLines breaks the base64 written to it into lines of armorLineWidth.
+type armorLines struct {
+	w      io.Writer
+	column int
+}
+
+func (l *armorLines) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(armorLineWidth-l.column, len(p))
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written, l.column, p = written+n, l.column+n, p[n:]
+		if l.column == armorLineWidth {
+			if _, err := io.WriteString(l.w, "\n"); err != nil {
+				return written, err
+			}
+			l.column = 0
+		}
+	}
+	return written, nil
+}
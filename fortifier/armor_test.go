@@ -0,0 +1,80 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestArmorRoundTrip(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := bytes.Repeat([]byte("armored "), 100)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var armored bytes.Buffer
+	if err = Convert(ContainerReader{Data: bytes.NewReader(original)}, ContainerWriter{Data: &armored}, ContainerArmor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(armored.String(), "\n"), "\n")
+	if lines[0] != armorBegin || lines[len(lines)-1] != armorEnd || len(lines[1]) != armorLineWidth {
+		t.Fatalf("bad: %q", armored.String())
+	}
+
+	decrypt := func(data []byte) ([]byte, error) {
+		layout, payload, err := ReadContainer(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		err = NewDecrypter(layout.Metadata().Mode, NewFortifierWithRsa(false, layout.Metadata(), pri)).Decrypt(payload, &out, layout)
+		return out.Bytes(), err
+	}
+	for name, data := range map[string][]byte{
+		"binary":  original,
+		"armored": armored.Bytes(),
+		"crlf":    bytes.ReplaceAll(armored.Bytes(), []byte("\n"), []byte("\r\n")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if actual, err := decrypt(data); err != nil || !bytes.Equal(actual, plaintext) {
+				t.Fatalf("bad: %q, err: %v", actual, err)
+			}
+		})
+	}
+
+	var binary bytes.Buffer
+	if err = Convert(ContainerReader{Data: &armored}, ContainerWriter{Data: &binary}, ContainerBinary); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(binary.Bytes(), original) {
+		t.Fatalf("expect the original file back")
+	}
+}
+
+func TestArmorInvalid(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	original, err := os.ReadFile(testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data")))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var armored bytes.Buffer
+	if err = Convert(ContainerReader{Data: bytes.NewReader(original)}, ContainerWriter{Data: &armored}, ContainerArmor); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for name, data := range map[string]string{
+		"header": strings.Replace(armored.String(), armorBegin, "-----BEGIN PGP MESSAGE-----", 1),
+		"footer": strings.TrimSuffix(armored.String(), armorEnd+"\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Convert(ContainerReader{Data: strings.NewReader(data)}, ContainerWriter{Data: &out}, ContainerBinary)
+			if !errors.Is(err, ErrInvalidArmor) {
+				t.Fatalf("expect ErrInvalidArmor, got %v", err)
+			}
+		})
+	}
+}
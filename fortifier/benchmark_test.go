@@ -0,0 +1,153 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func benchmarkRsaKeys(b *testing.B) map[int]*rsa.PrivateKey {
+	keys := map[int]*rsa.PrivateKey{2048: testRsaPrivateKey(b)}
+	for _, bits := range []int{3072, 4096} {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			b.Fatalf("err: %v", err)
+		}
+		keys[bits] = key
+	}
+	return keys
+}
+
+func BenchmarkRsaWrap(b *testing.B) {
+	keys := benchmarkRsaKeys(b)
+	raw := make([]byte, 32)
+	for _, scheme := range []RsaScheme{RsaSchemeOAEP, RsaSchemeKEM} {
+		for _, bits := range []int{2048, 3072, 4096} {
+			pri := keys[bits]
+			ciphertext, err := scheme.wrap(&pri.PublicKey, raw, rand.Reader)
+			if err != nil {
+				b.Fatalf("err: %v", err)
+			}
+			b.Run(fmt.Sprintf("%s/%d/wrap", scheme, bits), func(b *testing.B) {
+				for range b.N {
+					if _, err := scheme.wrap(&pri.PublicKey, raw, rand.Reader); err != nil {
+						b.Fatalf("err: %v", err)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%s/%d/unwrap", scheme, bits), func(b *testing.B) {
+				for range b.N {
+					if _, err := scheme.unwrap(pri, ciphertext); err != nil {
+						b.Fatalf("err: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRsaRecipients(b *testing.B) {
+	keys := benchmarkRsaKeys(b)
+	var pems [][]byte
+	for range 8 {
+		for _, bits := range []int{2048, 3072, 4096} {
+			der, err := x509.MarshalPKIXPublicKey(&keys[bits].PublicKey)
+			if err != nil {
+				b.Fatalf("err: %v", err)
+			}
+			pems = append(pems, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		}
+	}
+	for _, n := range []int{1, 4, 0} {
+		b.Run(fmt.Sprintf("concurrency=%d", n), func(b *testing.B) {
+			SetConcurrency(n)
+			defer SetConcurrency(0)
+			for range b.N {
+				f := NewFortifierWithRsa(false, nil, nil)
+				for _, kb := range pems {
+					f.AddRecipient(kb)
+				}
+				if err := f.SetupKey(); err != nil {
+					b.Fatalf("err: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSeal(b *testing.B) {
+	plaintext := make([]byte, 1024*1024)
+	for _, mode := range []CipherMode{
+		{Name: CipherModeAes256CTR, SteamMaker: cipher.NewCTR},
+		{Name: CipherModeAes256OFB, SteamMaker: cipher.NewOFB},
+		{Name: CipherModeAes256CFB, SteamMaker: cipher.NewCFBEncrypter},
+	} {
+		b.Run(string(mode.Name), func(b *testing.B) {
+			f := NewFortifierWithSss(false, false, testSssParts(b))
+			f.meta.Mode = mode.Name
+			if err := f.SetupKey(); err != nil {
+				b.Fatalf("err: %v", err)
+			}
+			enc := &Aes256StreamEncrypter{f}
+			b.SetBytes(int64(len(plaintext)))
+			for range b.N {
+				var out benchmarkSeeker
+				layout := &FileLayout{metadata: f.meta}
+				if err := enc.Encrypt(bytes.NewReader(plaintext), &out, layout, mode); err != nil {
+					b.Fatalf("err: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkSeeker discards writes, tracking only the position for the head placeholders.
+type benchmarkSeeker struct {
+	pos, size int64
+}
+
+func (s *benchmarkSeeker) Write(p []byte) (int, error) {
+	s.pos += int64(len(p))
+	s.size = max(s.size, s.pos)
+	return len(p), nil
+}
+
+func (s *benchmarkSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		s.pos = offset
+	case io.SeekCurrent:
+		s.pos += offset
+	case io.SeekEnd:
+		s.pos = s.size + offset
+	}
+	return s.pos, nil
+}
+
+func BenchmarkParsePublicKey(b *testing.B) {
+	pub, _ := testRsaPem(b)
+	b.Run("uncached", func(b *testing.B) {
+		for range b.N {
+			if _, _, err := ParsePublicKey(pub); err != nil {
+				b.Fatalf("err: %v", err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := NewKeyCache(defaultKeyCacheSize)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, _, err := c.ParsePublicKey(pub); err != nil {
+					b.Errorf("err: %v", err)
+					return
+				}
+			}
+		})
+	})
+}
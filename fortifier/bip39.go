@@ -0,0 +1,62 @@
+package fortifier
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// bip39English is the English wordlist of BIP39, whose CRC-32 is c1dbd296.
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+// bip39Words maps each word of the wordlist to its index.
+var bip39Words = sync.OnceValue(func() map[string]int {
+	words := strings.Fields(bip39English)
+	m := make(map[string]int, len(words))
+	for i, w := range words {
+		m[w] = i
+	}
+	return m
+})
+
+// bip39Seed derives the 64-byte BIP39 seed of the English mnemonic phrase and
+// the passphrase, after checking the phrase has 12 to 24 words of the wordlist
+// in multiples of three and a matching checksum.
+func bip39Seed(mnemonic, passphrase string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if n := len(words); n < 12 || n > 24 || n%3 != 0 {
+		return nil, fmt.Errorf("mnemonic of %d words, requiring 12, 15, 18, 21 or 24", n)
+	}
+	// every word carries 11 bits: the entropy, then one checksum bit for each
+	// 32 bits of entropy
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, w := range words {
+		index, ok := bip39Words()[w]
+		if !ok {
+			return nil, fmt.Errorf("word %d is not in the bip39 wordlist", i+1)
+		}
+		for b := 0; b < 11; b++ {
+			if index&(1<<(10-b)) != 0 {
+				pos := i*11 + b
+				bits[pos/8] |= 0x80 >> (pos % 8)
+			}
+		}
+	}
+	checksumBits := len(words) * 11 / 33
+	entropy := bits[:checksumBits*4]
+	defer clear(bits)
+	sum := sha256.Sum256(entropy)
+	mask := byte(0xFF) << (8 - checksumBits)
+	if (bits[len(entropy)]^sum[0])&mask != 0 {
+		return nil, errors.New("mnemonic checksum incorrect")
+	}
+	return pbkdf2.Key([]byte(strings.Join(words, " ")), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
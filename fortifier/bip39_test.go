@@ -0,0 +1,31 @@
+package fortifier
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// test vectors of BIP39, with the passphrase TREZOR
+func TestBip39Seed(t *testing.T) {
+	for _, c := range []struct{ mnemonic, seed string }{
+		{testMnemonic, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+		{"legal winner thank year wave sausage worth useful legal winner thank yellow", "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+		{strings.Repeat("zoo ", 23) + "vote", "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad"},
+	} {
+		seed, err := bip39Seed(c.mnemonic, "TREZOR")
+		if err != nil || hex.EncodeToString(seed) != c.seed {
+			t.Fatalf("bad: %x, err: %v", seed, err)
+		}
+	}
+	for _, mnemonic := range []string{
+		strings.Repeat("abandon ", 12),
+		strings.Repeat("abandon ", 11) + "fortify",
+		strings.Repeat("abandon ", 10) + "about",
+		strings.Repeat("zoo ", 24),
+	} {
+		if _, err := bip39Seed(mnemonic, ""); err == nil {
+			t.Fatalf("expect error for %q", mnemonic)
+		}
+	}
+}
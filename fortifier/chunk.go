@@ -0,0 +1,46 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	minChunkSize = 4 << 10
+	maxChunkSize = 16 << 20
+)
+
+// ErrChunkSize means a chunk size is not a power of two between 4 KiB and 16 MiB.
+var ErrChunkSize = errors.New("invalid chunk size")
+
+// SetChunkSize sets the number of bytes the payload is streamed in, which
+// trades the memory of the read and write buffers for the overhead of more
+// reads and writes. The size must be a power of two between 4 KiB and 16 MiB;
+// it is recorded in the metadata so that decryption streams in the same
+// chunks. Zero restores the default buffers.
+func (f *Fortifier) SetChunkSize(size int) error {
+	if err := checkChunkSize(size); err != nil {
+		return err
+	}
+	f.meta.Chunk = size
+	return nil
+}
+
+// checkChunkSize rejects a chunk size out of bounds, such as one recorded by a
+// crafted file to exhaust memory before its checksum is verified.
+func checkChunkSize(size int) error {
+	if size != 0 && (size < minChunkSize || size > maxChunkSize || bits.OnesCount(uint(size)) != 1) {
+		return fmt.Errorf("%w: %d is not a power of two between %d and %d", ErrChunkSize, size, minChunkSize, maxChunkSize)
+	}
+	return nil
+}
+
+// chunkSizes returns the sizes of the read and write buffers streaming the
+// payload in chunks of the recorded size.
+func chunkSizes(chunk int) (reader, writer int) {
+	if chunk == 0 {
+		return defaultReaderBufferSize, defaultWriterBufferSize
+	}
+	return chunk, chunk
+}
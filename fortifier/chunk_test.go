@@ -0,0 +1,48 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestChunkSize(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := make([]byte, 3*minChunkSize+100)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetChunkSize(minChunkSize); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if layout.Metadata().Chunk != minChunkSize {
+		t.Fatalf("bad chunk: %d", layout.Metadata().Chunk)
+	}
+	actual, err := testDecryptRsaFile(t, path, pri)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: plaintext mismatch, err: %v", err)
+	}
+
+	for _, size := range []int{-1, 1, minChunkSize / 2, minChunkSize + 16, maxChunkSize * 2} {
+		if err = f.SetChunkSize(size); !errors.Is(err, ErrChunkSize) {
+			t.Fatalf("expect chunk size error for %d, got %v", size, err)
+		}
+	}
+}
+
+func TestChunkSizeRecordedAbsurd(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	layout.Metadata().Chunk = 1 << 40
+	dec := NewDecrypter(layout.Metadata().Mode, NewFortifierWithRsa(false, layout.Metadata(), pri))
+	if err := dec.Decrypt(in, &bytes.Buffer{}, layout); !errors.Is(err, ErrChunkSize) {
+		t.Fatalf("expect chunk size error, got %v", err)
+	}
+}
@@ -21,6 +21,15 @@ func (s CipherKeyKind) String() string {
 const (
 	CipherKeyKindSSS CipherKeyKind = "sss"
 	CipherKeyKindRSA CipherKeyKind = "rsa"
+	// CipherKeyKindMnemonic wraps the cipher key under a key derived from a
+	// BIP39 mnemonic, see NewFortifierWithMnemonic
+	CipherKeyKindMnemonic CipherKeyKind = "mnemonic"
+	// CipherKeyKindDPAPI protects the cipher key with the Windows Data
+	// Protection API, see NewFortifierWithDpapi
+	CipherKeyKindDPAPI CipherKeyKind = "dpapi"
+	// CipherKeyKindOIDC wraps the cipher key for an unwrap service releasing it
+	// to an OIDC identity, see NewFortifierWithOidc
+	CipherKeyKindOIDC CipherKeyKind = "oidc"
 )
 
 type CipherKey interface {
@@ -30,9 +39,9 @@ type CipherKey interface {
 
 type CipherKeyData struct {
 	kind  CipherKeyKind
-	raw   []byte
+	raw   secretBytes
 	parts []sss.Part
-	bytes []byte
+	bytes secretBytes
 }
 
 func (k *CipherKeyData) NewSha256() hash.Hash {
@@ -60,13 +69,12 @@ const (
 	CipherModeAes256CFB CipherModeName = "aes256-cfb"
 )
 
-func enterPassphrase() []byte {
-	fmt.Print("Enter passphrase: ")
-	if passphrase, err := term.ReadPassword(int(os.Stdin.Fd())); err != nil {
-		fmt.Printf("\nError reading passphrase: %v\n", err)
-		return nil
-	} else {
-		fmt.Println()
-		return passphrase
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return nil, fmt.Errorf("error reading passphrase: %v", err)
 	}
+	return passphrase, nil
 }
@@ -0,0 +1,114 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type ContainerFormat string
+
+const (
+	// ContainerBinary is a single file of the head followed by the payload
+	ContainerBinary ContainerFormat = "binary"
+	// ContainerSplit is a sidecar of the head and a payload file, see SplitFile
+	ContainerSplit ContainerFormat = "split"
+)
+
+var ErrUnsupportedContainer = errors.New("unsupported container format")
+
+// ContainerReader holds the input streams of a fortified file: the whole file in
+// Data for the binary format, or the payload in Data and the sidecar in Sidecar
+// for the split format.
+type ContainerReader struct {
+	Data    io.Reader
+	Sidecar io.Reader
+}
+
+// Format reports the format of the container, split when it has a sidecar.
+func (c ContainerReader) Format() ContainerFormat {
+	if c.Sidecar != nil {
+		return ContainerSplit
+	}
+	return ContainerBinary
+}
+
+// ContainerWriter holds the output streams of a fortified file, as ContainerReader.
+type ContainerWriter struct {
+	Data    io.Writer
+	Sidecar io.Writer
+}
+
+// Convert repackages the fortified file read from in into the target format.
+// A binary input may be armored, see ReadContainer.
+// The metadata and ciphertext are copied unchanged, so no key is needed. The
+// head is checked with Verify, the payload against the head length and the
+// sidecar digest, and the head is read back before it is written out. On error
+// the output is incomplete and must be discarded.
+func Convert(in ContainerReader, out ContainerWriter, target ContainerFormat) (err error) {
+	switch target {
+	case ContainerBinary:
+	case ContainerArmor:
+		armor := NewArmorWriter(out.Data)
+		defer func() {
+			if err == nil {
+				err = armor.Close()
+			}
+		}()
+		out.Data = armor
+	case ContainerSplit:
+		if out.Sidecar == nil {
+			return fmt.Errorf("%s container requires a sidecar output", target)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedContainer, target)
+	}
+	var layout *FileLayout
+	var sidecar *Sidecar
+	data := in.Data
+	if in.Format() == ContainerSplit {
+		if layout, sidecar, err = ReadSidecar(in.Sidecar); err != nil {
+			return
+		}
+	} else if layout, data, err = ReadContainer(in.Data); err != nil {
+		return
+	}
+	if err = layout.Verify(); err != nil {
+		return
+	}
+	var head []byte
+	if head, err = layout.MarshalBinary(); err != nil {
+		return
+	}
+	if err = (&FileLayout{}).UnmarshalBinary(head); err != nil {
+		return fmt.Errorf("invalid converted head -- %w", err)
+	}
+	if target != ContainerSplit {
+		if _, err = out.Data.Write(head); err != nil {
+			return
+		}
+	}
+	sha := sha512.New()
+	var size int64
+	if size, err = io.Copy(io.MultiWriter(out.Data, sha), data); err != nil {
+		return
+	}
+	if expect := aes.BlockSize + int64(layout.DataLength()); size != expect {
+		return fmt.Errorf("%w: expect payload size %d, not %d", ErrPayloadAuthFailed, expect, size)
+	}
+	digest := base64.URLEncoding.EncodeToString(sha.Sum(nil))
+	if sidecar != nil && digest != sidecar.PayloadDigest {
+		return fmt.Errorf("%w: payload digest", ErrSplitMismatch)
+	}
+	if target == ContainerSplit {
+		return json.NewEncoder(out.Sidecar).Encode(&Sidecar{
+			Head:          base64.StdEncoding.EncodeToString(head),
+			PayloadDigest: digest,
+		})
+	}
+	return
+}
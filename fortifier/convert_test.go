@@ -0,0 +1,73 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := bytes.Repeat([]byte("repackaged "), 100)
+	dir := t.TempDir()
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, dir, plaintext)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var sidecar, payload bytes.Buffer
+	err = Convert(ContainerReader{Data: bytes.NewReader(original)},
+		ContainerWriter{Data: &payload, Sidecar: &sidecar}, ContainerSplit)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expectSidecar, expectPayload := testSplitFile(t, path)
+	if !bytes.Equal(sidecar.Bytes(), expectSidecar) || !bytes.Equal(payload.Bytes(), expectPayload) {
+		t.Fatalf("expect the same split as SplitFile")
+	}
+
+	var binary bytes.Buffer
+	err = Convert(ContainerReader{Data: bytes.NewReader(payload.Bytes()), Sidecar: bytes.NewReader(sidecar.Bytes())},
+		ContainerWriter{Data: &binary}, ContainerBinary)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(binary.Bytes(), original) {
+		t.Fatalf("expect the original file back")
+	}
+	converted := testWriteFile(t, dir, "converted", binary.Bytes())
+	if actual, err := testDecryptRsaFile(t, converted, pri); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	dir := t.TempDir()
+	original, err := os.ReadFile(testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, dir, []byte("data")))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	err = Convert(ContainerReader{Data: bytes.NewReader(original)}, ContainerWriter{Data: &out}, "zip")
+	if !errors.Is(err, ErrUnsupportedContainer) {
+		t.Fatalf("expect ErrUnsupportedContainer, got %v", err)
+	}
+	err = Convert(ContainerReader{Data: bytes.NewReader(original[:len(original)-1])}, ContainerWriter{Data: &out}, ContainerBinary)
+	if !errors.Is(err, ErrPayloadAuthFailed) {
+		t.Fatalf("expect ErrPayloadAuthFailed, got %v", err)
+	}
+
+	var sidecar, payload bytes.Buffer
+	if err = SplitFile(bytes.NewReader(original), &sidecar, &payload); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tampered := bytes.Clone(payload.Bytes())
+	tampered[len(tampered)-1] ^= 1
+	err = Convert(ContainerReader{Data: bytes.NewReader(tampered), Sidecar: &sidecar}, ContainerWriter{Data: &out}, ContainerBinary)
+	if !errors.Is(err, ErrSplitMismatch) {
+		t.Fatalf("expect ErrSplitMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/99designs/keyring"
+)
+
+// DefaultCredentialService is the service name the passphrases of fortify are
+// stored under in the credential store.
+const DefaultCredentialService = "fortify"
+
+// CredentialStore keeps passphrases in a credential store through
+// github.com/99designs/keyring, which abstracts the macOS Keychain, the Windows
+// Credential Manager and the Secret Service on Linux.
+type CredentialStore struct {
+	ring keyring.Keyring
+}
+
+// OpenCredentialStore opens the credential store of the operating system for
+// the service: the login keychain on macOS, the Credential Manager on Windows
+// or the Secret Service on other systems.
+func OpenCredentialStore(service string) (*CredentialStore, error) {
+	ring, err := keyring.Open(keyring.Config{
+		ServiceName: service,
+		AllowedBackends: []keyring.BackendType{
+			keyring.KeychainBackend, keyring.WinCredBackend, keyring.SecretServiceBackend},
+		KeychainTrustApplication: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("credential store %s: %w", service, err)
+	}
+	return &CredentialStore{ring: ring}, nil
+}
+
+// NewCredentialStore keeps the passphrases in the keyring, such as the
+// in-memory keyring.NewArrayKeyring of tests.
+func NewCredentialStore(ring keyring.Keyring) *CredentialStore {
+	return &CredentialStore{ring: ring}
+}
+
+// Passphrase retrieves the passphrase stored under the key, failing with
+// ErrKeychainItemNotFound if there is none.
+func (s *CredentialStore) Passphrase(key string) ([]byte, error) {
+	item, err := s.ring.Get(key)
+	if errors.Is(err, keyring.ErrKeyNotFound) {
+		return nil, fmt.Errorf("credential %s: %w", key, ErrKeychainItemNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("credential %s: %w", key, err)
+	}
+	return item.Data, nil
+}
+
+// SetPassphrase stores the passphrase under the key, replacing the one stored before.
+func (s *CredentialStore) SetPassphrase(key string, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("empty passphrase")
+	}
+	if err := s.ring.Set(keyring.Item{Key: key, Data: passphrase, Label: "fortify passphrase " + key}); err != nil {
+		return fmt.Errorf("credential %s: %w", key, err)
+	}
+	return nil
+}
+
+// RemovePassphrase removes the passphrase stored under the key.
+func (s *CredentialStore) RemovePassphrase(key string) error {
+	if err := s.ring.Remove(key); errors.Is(err, keyring.ErrKeyNotFound) {
+		return fmt.Errorf("credential %s: %w", key, ErrKeychainItemNotFound)
+	} else if err != nil {
+		return fmt.Errorf("credential %s: %w", key, err)
+	}
+	return nil
+}
+
+// Provider returns a PassphraseProvider answering every prompt with the
+// passphrase stored under the key, for SetPassphraseProvider. When none is
+// stored, it defers to next, such as TerminalPassphrase, unless next is nil.
+func (s *CredentialStore) Provider(key string, next PassphraseProvider) PassphraseProvider {
+	return PassphraseFunc(func(prompt string) ([]byte, error) {
+		passphrase, err := s.Passphrase(key)
+		if errors.Is(err, ErrKeychainItemNotFound) && next != nil {
+			return next.Passphrase(prompt)
+		}
+		return passphrase, err
+	})
+}
@@ -0,0 +1,49 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/99designs/keyring"
+)
+
+func TestCredentialStore(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	store := NewCredentialStore(keyring.NewArrayKeyring(nil))
+	if _, err := store.Passphrase("backup"); !errors.Is(err, ErrKeychainItemNotFound) {
+		t.Fatalf("expect item not found, got %v", err)
+	}
+	if err := store.SetPassphrase("backup", []byte("stored secret")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if passphrase, err := store.Passphrase("backup"); err != nil || !bytes.Equal(passphrase, []byte("stored secret")) {
+		t.Fatalf("bad: %q, err: %v", passphrase, err)
+	}
+
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	f := NewFortifierWithRsa(false, layout.Metadata(), testEncryptedRsaPem(t, "stored secret"))
+	f.SetPassphraseProvider(store.Provider("backup", nil))
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := store.RemovePassphrase("backup"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f = NewFortifierWithRsa(false, layout.Metadata(), testEncryptedRsaPem(t, "typed secret"))
+	f.SetPassphraseProvider(store.Provider("backup", nil))
+	if err := f.SetupKey(); !errors.Is(err, ErrKeychainItemNotFound) {
+		t.Fatalf("expect item not found, got %v", err)
+	}
+	f = NewFortifierWithRsa(false, layout.Metadata(), testEncryptedRsaPem(t, "typed secret"))
+	f.SetPassphraseProvider(store.Provider("backup", testPassphrase("typed secret")))
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := store.SetPassphrase("backup", nil); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,109 @@
+package fortifier
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+const dpapiFortifier = "dpapi_fortifier"
+
+// ErrDpapiUnsupported means the Windows Data Protection API is not available on
+// this platform.
+var ErrDpapiUnsupported = errors.New("dpapi requires windows")
+
+// DpapiScope binds a cipher key protected by DPAPI to the user or the machine.
+type DpapiScope string
+
+const (
+	// DpapiScopeUser lets only the same user, on the same machine or roaming
+	// profile, recover the cipher key
+	DpapiScopeUser DpapiScope = "user"
+	// DpapiScopeMachine lets any user of the same machine recover the cipher key
+	DpapiScopeMachine DpapiScope = "machine"
+)
+
+// MetadataDpapi holds the cipher key protected by the Windows Data Protection
+// API, with the random entropy bound to the protected blob.
+type MetadataDpapi struct {
+	Timestamp time.Time  `json:"timestamp"`
+	Digest    string     `json:"digest"`
+	Scope     DpapiScope `json:"scope"`
+	Blob      string     `json:"blob"`
+	Entropy   string     `json:"entropy"`
+}
+
+// NewFortifierWithDpapi makes a Fortifier protecting the cipher key with DPAPI
+// under the user scope, see SetDpapiScope, or recovering it in the same context
+// when meta records a protected key. meta may be nil.
+func NewFortifierWithDpapi(verbose bool, meta *Metadata) *Fortifier {
+	var m *MetadataDpapi
+	if meta != nil {
+		m = meta.Dpapi
+	}
+	return &Fortifier{
+		meta:    &Metadata{Dpapi: m},
+		key:     &CipherKeyData{kind: CipherKeyKindDPAPI},
+		verbose: verbose,
+	}
+}
+
+// SetDpapiScope sets the scope of the cipher key protected by DPAPI when
+// encrypting, options: [user|machine].
+func (f *Fortifier) SetDpapiScope(scope DpapiScope) error {
+	switch scope {
+	case DpapiScopeUser, DpapiScopeMachine:
+		f.dpapiScope = scope
+		return nil
+	default:
+		return fmt.Errorf("unknown dpapi scope: %s", scope)
+	}
+}
+
+func (f *Fortifier) setupDpapiKey() (err error) {
+	if m := f.meta.Dpapi; m != nil {
+		var blob, entropy, raw []byte
+		if blob, err = base64.StdEncoding.DecodeString(m.Blob); err != nil {
+			return fmt.Errorf("%s: invalid blob: %v", dpapiFortifier, err)
+		}
+		if entropy, err = base64.StdEncoding.DecodeString(m.Entropy); err != nil {
+			return fmt.Errorf("%s: invalid entropy: %v", dpapiFortifier, err)
+		}
+		if raw, err = dpapiUnprotect(blob, entropy); err != nil {
+			return fmt.Errorf("%s: %w", dpapiFortifier, err)
+		}
+		if subtle.ConstantTimeCompare([]byte(utils.ComputeDigest(raw)), []byte(m.Digest)) != 1 {
+			clear(raw)
+			return fmt.Errorf("%s: digest mismatch", dpapiFortifier)
+		}
+		f.key.raw = raw
+		return
+	}
+	scope := f.dpapiScope
+	if scope == "" {
+		scope = DpapiScopeUser
+	}
+	var raw []byte
+	if raw, err = f.newRaw(); err != nil {
+		return
+	}
+	entropy := make([]byte, 16)
+	if _, err = rand.Read(entropy); err != nil {
+		return
+	}
+	var blob []byte
+	if blob, err = dpapiProtect(raw, entropy, scope); err != nil {
+		return fmt.Errorf("%s: %w", dpapiFortifier, err)
+	}
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindDPAPI
+	f.meta.Timestamp = time.Now()
+	f.meta.Dpapi = &MetadataDpapi{Timestamp: f.meta.Timestamp, Digest: utils.ComputeDigest(raw), Scope: scope,
+		Blob: base64.StdEncoding.EncodeToString(blob), Entropy: base64.StdEncoding.EncodeToString(entropy)}
+	return f.checkSelfTest()
+}
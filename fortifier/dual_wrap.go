@@ -0,0 +1,57 @@
+package fortifier
+
+import (
+	"crypto/rsa"
+	"slices"
+)
+
+// SetDualWrap enables wrapping the cipher key for every RSA recipient under each
+// of the schemes too, besides the scheme of SetRsaScheme, as separate recipient
+// entries recording their scheme. A single file then exercises the recovery of
+// every scheme, see SetRecoverScheme, e.g. to test a migration between schemes.
+func (f *Fortifier) SetDualWrap(schemes ...RsaScheme) error {
+	var dual []RsaScheme
+	for _, s := range schemes {
+		normalized, err := s.normalize()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(dual, normalized) {
+			dual = append(dual, normalized)
+		}
+	}
+	f.rsaDual = dual
+	return nil
+}
+
+// SetRecoverScheme restricts the recovery of the cipher key to the RSA recipient
+// entries wrapped with the scheme, or lifts the restriction if empty.
+func (f *Fortifier) SetRecoverScheme(scheme RsaScheme) error {
+	if scheme == "" {
+		f.rsaRecover = ""
+		return nil
+	}
+	normalized, err := scheme.normalize()
+	if err != nil {
+		return err
+	}
+	f.rsaRecover = normalized.String()
+	return nil
+}
+
+// wrapRsaSchemes wraps the cipher key for the public key with the scheme of
+// SetRsaScheme, then with each other scheme of SetDualWrap.
+func (f *Fortifier) wrapRsaSchemes(pub *rsa.PublicKey, raw []byte) ([]*MetadataRsa, error) {
+	var recipients []*MetadataRsa
+	for _, scheme := range append([]RsaScheme{f.rsaScheme}, f.rsaDual...) {
+		if len(recipients) > 0 && scheme == f.rsaScheme {
+			continue
+		}
+		m, err := f.wrapRsa(pub, raw, scheme)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, m)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,57 @@
+package fortifier
+
+import "testing"
+
+func TestDualWrap(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetDualWrap(RsaSchemeKEM, RsaSchemeOAEP); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("both ways"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	recipients := layout.Metadata().Recipients
+	if len(recipients) != 2 || recipients[0].Scheme != "" || recipients[1].Scheme != RsaSchemeKEM {
+		t.Fatalf("unexpected recipients: %+v", recipients)
+	}
+
+	for _, scheme := range []RsaScheme{RsaSchemeOAEP, RsaSchemeKEM} {
+		in, layout = testReadLayout(t, path)
+		_ = in.Close()
+		// damage the entries of the other scheme, so recovery must use this one
+		for _, m := range layout.Metadata().Recipients {
+			if m.Scheme.String() != scheme.String() {
+				m.Ciphertext = testFlip(m.Ciphertext, 10)
+			}
+		}
+		f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+		if err := f.SetRecoverScheme(scheme); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := f.AuthenticateMetadata(layout); err != nil {
+			t.Fatalf("%s err: %v", scheme, err)
+		}
+		f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+		if err := f.SetRecoverScheme(map[RsaScheme]RsaScheme{RsaSchemeOAEP: RsaSchemeKEM, RsaSchemeKEM: RsaSchemeOAEP}[scheme]); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := f.SetupKey(); err == nil {
+			t.Fatalf("%s: expect error recovering with the damaged scheme", scheme)
+		}
+	}
+
+	path = testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("one way"))
+	in, layout = testReadLayout(t, path)
+	_ = in.Close()
+	f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	if err := f.SetRecoverScheme(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := f.SetDualWrap("rsa-pkcs1"); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,55 @@
+package fortifier
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// SetEmbedPublicKey enables storing the full public key of every RSA recipient
+// in the metadata, next to its fingerprint, so that offline tools can display
+// and match recipients without a key directory. It enlarges the file head.
+func (f *Fortifier) SetEmbedPublicKey(b bool) {
+	f.embedPub = b
+}
+
+// embedPublicKey stores pub as the base64 of its DER SubjectPublicKeyInfo.
+func (m *MetadataRsa) embedPublicKey(pub crypto.PublicKey) error {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	m.PublicKey = base64.StdEncoding.EncodeToString(der)
+	return nil
+}
+
+// EmbeddedPublicKey returns the public key stored by SetEmbedPublicKey, or nil
+// if there is none. The key must match the recorded fingerprint.
+func (m *MetadataRsa) EmbeddedPublicKey() (crypto.PublicKey, error) {
+	if m.PublicKey == "" {
+		return nil, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(m.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key: %v", ErrCorruptedRecipient, err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key: %v", ErrCorruptedRecipient, err)
+	}
+	if m.Fingerprint != "" && m.Fingerprint != PublicKeyFingerprint(pub) {
+		return nil, fmt.Errorf("%w: public key mismatches fingerprint %s", ErrCorruptedRecipient, m.Fingerprint)
+	}
+	return pub, nil
+}
+
+// checkPublicKeys checks the public keys embedded for the RSA recipients.
+func (m *Metadata) checkPublicKeys() error {
+	for i, r := range m.rsaRecipients() {
+		if _, err := r.EmbeddedPublicKey(); err != nil {
+			return fmt.Errorf("rsa recipient %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEmbedPublicKey(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	key := testRsaPrivateKey(t)
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		f.SetEmbedPublicKey(true)
+		plaintext := []byte("embedded")
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		embedded, err := layout.Metadata().Recipients[0].EmbeddedPublicKey()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !key.PublicKey.Equal(embedded) {
+			t.Fatalf("seal %v: embedded public key mismatch", seal)
+		}
+		if actual, err := testDecryptRsaFile(t, path, pri); err != nil || !bytes.Equal(actual, plaintext) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+	}
+
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if layout.Metadata().Recipients[0].PublicKey != "" {
+		t.Fatalf("expect no embedded public key by default")
+	}
+}
+
+func TestEmbedPublicKeyMismatch(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetEmbedPublicKey(true)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	fingerprint := layout.Metadata().Recipients[0].Fingerprint
+	other := fingerprint[:len(fingerprint)-1] + string(fingerprint[len(fingerprint)-1]^1)
+
+	layout.Metadata().Recipients[0].Fingerprint = other
+	if err := layout.Verify(); !errors.Is(err, ErrCorruptedRecipient) {
+		t.Fatalf("expect ErrCorruptedRecipient, got %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	content = bytes.Replace(content, []byte(fingerprint), []byte(other), 1)
+	if err = os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	if err = (&FileLayout{}).ReadHeadIn(file); !errors.Is(err, ErrCorruptedRecipient) {
+		t.Fatalf("expect ErrCorruptedRecipient, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+// EstimateOptions describe the fortified file sized by EstimateSize.
+type EstimateOptions struct {
+	// Mode is the cipher mode, CipherModeAes256CTR if empty
+	Mode CipherModeName
+	// Format is the container format, ContainerBinary if empty
+	Format ContainerFormat
+}
+
+// SizeEstimate is the expected size of a fortified file, see EstimateSize.
+type SizeEstimate struct {
+	// Head is the size of the head, or of the sidecar in the split format
+	Head int64
+	// HeadMax bounds Head when the metadata is stored gzip compressed, whose
+	// size is only known once compressed; it equals Head otherwise
+	HeadMax int64
+	// Payload is the size of the IV and the ciphertext, whatever the format
+	Payload int64
+}
+
+// Total returns the expected size of the whole fortified file.
+func (e SizeEstimate) Total() int64 {
+	return e.Head + e.Payload
+}
+
+// Max returns the size the whole fortified file cannot exceed.
+func (e SizeEstimate) Max() int64 {
+	return e.HeadMax + e.Payload
+}
+
+// EstimateSize estimates the size of the file fortified from a payload of
+// payloadLen bytes with the options, for storage planning and progress
+// reporting. It sets up the cipher key as SetupKey does, so that the head
+// covers the wrapped keys of every recipient, the sealing and the labels just
+// as they are written when the Fortifier then encrypts. The cipher modes are
+// stream modes without padding or tags: the payload is the IV followed by as
+// many bytes as the plaintext, segments included. The estimate is exact unless
+// the metadata is compressed, see SetCompressMetadata.
+func (f *Fortifier) EstimateSize(payloadLen int64, opts EstimateOptions) (e SizeEstimate, err error) {
+	if payloadLen < 0 {
+		return e, fmt.Errorf("invalid payload length: %d", payloadLen)
+	}
+	if opts.Mode == "" {
+		opts.Mode = CipherModeAes256CTR
+	}
+	if _, ok := encrypterModes[opts.Mode]; !ok {
+		return e, fmt.Errorf("unknown cipher mode name: %s", opts.Mode)
+	}
+	switch opts.Format {
+	case "", ContainerBinary, ContainerSplit:
+	default:
+		return e, fmt.Errorf("%w: %s", ErrUnsupportedContainer, opts.Format)
+	}
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	f.meta.Mode = opts.Mode
+	var layout *FileLayout
+	if layout, err = f.newLayout(); err != nil {
+		return
+	}
+	var head bytes.Buffer
+	if err = layout.WriteHeadOut(&head); err != nil {
+		return
+	}
+	headLen, maxLen := head.Len(), head.Len()
+	if layout.compress {
+		// deflate stores incompressible input in blocks of 5 bytes overhead, and
+		// gzip adds a header and a trailer of 18 bytes
+		n := len(layout.metadataPlain)
+		maxLen += n - len(layout.metadataRaw) + 18 + 5*(n/0xFFFF+1)
+	}
+	e = SizeEstimate{Head: int64(headLen), HeadMax: int64(maxLen), Payload: aes.BlockSize + payloadLen}
+	if opts.Format == ContainerSplit {
+		e.Head, e.HeadMax = sidecarSize(headLen), sidecarSize(maxLen)
+	}
+	return
+}
+
+// sidecarSize returns the size of the sidecar written by SplitFile for a head
+// of headLen bytes.
+func sidecarSize(headLen int) int64 {
+	b, _ := json.Marshal(&Sidecar{
+		Head:          strings.Repeat("A", base64.StdEncoding.EncodedLen(headLen)),
+		PayloadDigest: utils.ComputeDigest(nil),
+	})
+	// and the newline of json.Encoder
+	return int64(len(b)) + 1
+}
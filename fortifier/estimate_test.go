@@ -0,0 +1,109 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	plaintext := bytes.Repeat([]byte("planned "), 1000)
+	tests := []struct {
+		name string
+		make func() *Fortifier
+		opts EstimateOptions
+	}{
+		{"rsa", func() *Fortifier {
+			return NewFortifierWithRsa(false, nil, pub)
+		}, EstimateOptions{}},
+		{"rsa recipients sealed", func() *Fortifier {
+			f := NewFortifierWithRsa(false, nil, pub)
+			f.AddRecipient(testPublicPem(t, testOtherRsaPem(t)))
+			f.SetEmbedPublicKey(true)
+			f.SetSealMetadata(true)
+			_ = f.SetTags(map[string]string{"team": "storage"})
+			return f
+		}, EstimateOptions{Mode: CipherModeAes256OFB}},
+		{"mnemonic segments", func() *Fortifier {
+			f := NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+			_ = f.SetSegmentSize(minSegmentSize)
+			return f
+		}, EstimateOptions{Mode: CipherModeAes256CFB}},
+		{"rsa split", func() *Fortifier {
+			return NewFortifierWithRsa(false, nil, pub)
+		}, EstimateOptions{Format: ContainerSplit}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.make()
+			e, err := f.EstimateSize(int64(len(plaintext)), tt.opts)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			mode := tt.opts.Mode
+			if mode == "" {
+				mode = CipherModeAes256CTR
+			}
+			encrypted := testEncryptFile(t, f, mode, t.TempDir(), plaintext)
+			var head, payload int64
+			if tt.opts.Format == ContainerSplit {
+				sidecar, p := testSplitFile(t, encrypted)
+				head, payload = int64(len(sidecar)), int64(len(p))
+			} else {
+				stat, err := os.Stat(encrypted)
+				if err != nil {
+					t.Fatalf("err: %v", err)
+				}
+				payload = int64(len(plaintext)) + 16
+				head = stat.Size() - payload
+			}
+			if e.Head != head || e.HeadMax != head || e.Payload != payload || e.Total() != head+payload {
+				t.Fatalf("estimate %+v, actual head %d payload %d", e, head, payload)
+			}
+		})
+	}
+}
+
+func TestEstimateSizeCompressed(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetEmbedPublicKey(true)
+	f.SetCompressMetadata(true)
+	e, err := f.EstimateSize(0, EstimateOptions{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stat, err := os.Stat(testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), nil))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if head := stat.Size() - 16; head > e.HeadMax || head < e.Head-16 || head > e.Head+16 {
+		t.Fatalf("estimate %+v, actual head %d", e, head)
+	}
+
+	uncompressed := NewFortifierWithRsa(false, nil, pub)
+	uncompressed.SetEmbedPublicKey(true)
+	u, err := uncompressed.EstimateSize(0, EstimateOptions{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if e.Head >= u.Head || e.HeadMax <= u.Head {
+		t.Fatalf("compressed %+v, uncompressed %+v", e, u)
+	}
+}
+
+func TestEstimateSizeInvalid(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if _, err := f.EstimateSize(-1, EstimateOptions{}); err == nil {
+		t.Fatalf("expect error")
+	}
+	if _, err := f.EstimateSize(0, EstimateOptions{Mode: "aes128-gcm"}); err == nil {
+		t.Fatalf("expect error")
+	}
+	if _, err := f.EstimateSize(0, EstimateOptions{Format: "zip"}); !errors.Is(err, ErrUnsupportedContainer) {
+		t.Fatalf("expect unsupported container, got %v", err)
+	}
+}
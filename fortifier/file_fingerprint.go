@@ -0,0 +1,57 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const fileFingerprintLabel = "fortify file fingerprint"
+
+// FileFingerprint returns "SHA256:" followed by the unpadded base64 of the
+// SHA-256 digest of the canonical metadata of the layout and the payload, the
+// IV and ciphertext following the head of the same file, for inventories
+// tracking whether fortified files changed. The metadata is canonicalized as
+// JSON objects with sorted keys and no insignificant white space, so the
+// fingerprint holds across equivalent serializations, compressed or not, and
+// needs no key. It changes with any change of the metadata or the payload, but
+// not with the checksums of the head, which change with them.
+func FileFingerprint(layout *FileLayout, payload io.Reader) (string, error) {
+	if layout.metadataPlain == nil {
+		return "", fmt.Errorf("%w: layout without metadata", ErrNotFortified)
+	}
+	canonical, err := canonicalJSON(layout.metadataPlain)
+	if err != nil {
+		return "", fmt.Errorf("canonical metadata: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(fileFingerprintLabel))
+	_ = binary.Write(h, layoutByteOrder, uint64(len(canonical)))
+	h.Write(canonical)
+	if _, err = io.Copy(h, payload); err != nil {
+		return "", err
+	}
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalJSON re-serializes JSON with the keys of every object sorted, as
+// encoding/json writes maps, keeping numbers as written.
+func canonicalJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
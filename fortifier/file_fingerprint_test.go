@@ -0,0 +1,68 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testReorderJSON writes the keys of the top-level object in reverse order, indented.
+func testReorderJSON(t testing.TB, b []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var keys []string
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	slices.Reverse(keys)
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(",\n")
+		}
+		sb.WriteString("  \"" + k + "\": " + string(fields[k]))
+	}
+	sb.WriteString("\n}")
+	return []byte(sb.String())
+}
+
+func TestFileFingerprint(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	_ = f.SetTags(map[string]string{"team": "storage", "env": "prod"})
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("inventoried"))
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	payload, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	fingerprint, err := FileFingerprint(layout, bytes.NewReader(payload))
+	if err != nil || !strings.HasPrefix(fingerprint, "SHA256:") {
+		t.Fatalf("bad: %s, err: %v", fingerprint, err)
+	}
+
+	reordered := *layout
+	reordered.metadataPlain = testReorderJSON(t, layout.metadataPlain)
+	if bytes.Equal(reordered.metadataPlain, layout.metadataPlain) {
+		t.Fatalf("metadata not reordered")
+	}
+	if actual, err := FileFingerprint(&reordered, bytes.NewReader(payload)); err != nil || actual != fingerprint {
+		t.Fatalf("fingerprint changed with the metadata order: %s, err: %v", actual, err)
+	}
+
+	payload[len(payload)-1] ^= 1
+	if actual, err := FileFingerprint(layout, bytes.NewReader(payload)); err != nil || actual == fingerprint {
+		t.Fatalf("fingerprint unchanged with the ciphertext: %s, err: %v", actual, err)
+	}
+	if _, err = FileFingerprint(&FileLayout{}, bytes.NewReader(payload)); err == nil {
+		t.Fatalf("expect error")
+	}
+}
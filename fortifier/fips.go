@@ -0,0 +1,52 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrNonFIPSAlgorithm = errors.New("non-FIPS algorithm")
+
+// fipsSuiteKeys are the key wrappings approved in FIPS mode: RSA-OAEP with
+// SHA-256 only. RSA-KEM wraps under AES-GCM with a fixed nonce rather than an
+// SP 800-38F key wrap, and Shamir's secret sharing is no approved key
+// establishment. The AES-256 CTR, OFB and CFB modes are all SP 800-38A modes.
+var fipsSuiteKeys = map[suiteKey]bool{
+	{CipherKeyKindRSA, ""}: true,
+}
+
+// SetFIPSMode restricts encryption and the recovery of the cipher key to the
+// suites of FIPSSuites, failing with ErrNonFIPSAlgorithm otherwise. It restricts
+// the algorithms used; a validated module still requires a FIPS 140 build.
+func (f *Fortifier) SetFIPSMode(b bool) {
+	f.fips = b
+}
+
+// FIPSSuites lists the names of the suites permitted in FIPS mode.
+func FIPSSuites() []string {
+	var names []string
+	for name, s := range suites {
+		if fipsSuiteKeys[suiteKey{s.Key, s.Scheme}] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// checkFIPS checks the key wrapping of the cipher key kind and the schemes against FIPS mode.
+func (f *Fortifier) checkFIPS(kind CipherKeyKind, schemes ...RsaScheme) error {
+	if !f.fips {
+		return nil
+	}
+	if kind != CipherKeyKindRSA {
+		schemes = []RsaScheme{""}
+	}
+	for _, scheme := range schemes {
+		if !fipsSuiteKeys[suiteKey{kind, scheme}] {
+			return fmt.Errorf("%w: %s", ErrNonFIPSAlgorithm, suiteKeyNames[suiteKey{kind, scheme}])
+		}
+	}
+	return nil
+}
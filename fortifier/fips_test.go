@@ -0,0 +1,73 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFIPSSuites(t *testing.T) {
+	expect := []string{
+		"RSA-OAEP-SHA256+AES256-CFB-HMAC-SHA256",
+		"RSA-OAEP-SHA256+AES256-CTR-HMAC-SHA256",
+		"RSA-OAEP-SHA256+AES256-OFB-HMAC-SHA256",
+	}
+	if actual := FIPSSuites(); !slices.Equal(actual, expect) {
+		t.Fatalf("unexpected suites: %v", actual)
+	}
+}
+
+func TestFIPSMode(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("approved only")
+
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetFIPSMode(true)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetFIPSMode(true)
+	if err := f.AuthenticateMetadata(layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	f = NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetRsaScheme(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.SetFIPSMode(true)
+	if err := f.SetupKey(); !errors.Is(err, ErrNonFIPSAlgorithm) {
+		t.Fatalf("expect ErrNonFIPSAlgorithm, got %v", err)
+	}
+	f = NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetDualWrap(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.SetFIPSMode(true)
+	if err := f.SetupKey(); !errors.Is(err, ErrNonFIPSAlgorithm) {
+		t.Fatalf("expect ErrNonFIPSAlgorithm, got %v", err)
+	}
+	f = NewFortifierWithSss(false, false, testSssParts(t))
+	f.SetFIPSMode(true)
+	if err := f.SetupKey(); !errors.Is(err, ErrNonFIPSAlgorithm) {
+		t.Fatalf("expect ErrNonFIPSAlgorithm, got %v", err)
+	}
+
+	f = NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetRsaScheme(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path = testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout = testReadLayout(t, path)
+	_ = in.Close()
+	f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetFIPSMode(true)
+	if err := f.SetupKey(); !errors.Is(err, ErrNonFIPSAlgorithm) {
+		t.Fatalf("expect ErrNonFIPSAlgorithm, got %v", err)
+	}
+	if actual, err := testDecryptRsaFile(t, path, pri); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+}
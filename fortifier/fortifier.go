@@ -1,11 +1,17 @@
 package fortifier
 
 import (
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 type Encrypter interface {
@@ -22,15 +28,165 @@ type Metadata struct {
 	Key       CipherKeyKind  `json:"key"`
 	Mode      CipherModeName `json:"mode"`
 	Sss       *MetadataSss   `json:"sss"`
-	Rsa       *MetadataRsa   `json:"rsa"`
+	// Rsa is the legacy field of the first RSA recipient, migrated into
+	// Recipients on load, see SetLegacyRsaField.
+	Rsa    *MetadataRsa `json:"rsa"`
+	Sealed string       `json:"sealed,omitempty"`
+	// Suite names the key wrapping and payload cipher at a glance, see Suite.
+	Suite string `json:"suite,omitempty"`
+	// Deterministic reports the IV is derived from the key and plaintext, so
+	// identical plaintexts under the same key produce identical ciphertexts.
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Segment is the number of stream bytes enciphered under each derived subkey.
+	Segment int64 `json:"segment,omitempty"`
+	// Chunk is the number of bytes the payload is streamed in, see SetChunkSize.
+	Chunk int `json:"chunk,omitempty"`
+	// Nested reports the payload is a fortified file itself, see SetNested.
+	Nested bool `json:"nested,omitempty"`
+	// Recipients are the RSA recipients of the cipher key, see AddRecipient.
+	Recipients []*MetadataRsa `json:"recipients,omitempty"`
+	// Description and Tags label the file for inventory tooling, see SetTags.
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	// Producer names the tool and version which wrote the file, see SetProducer.
+	Producer string `json:"producer,omitempty"`
+	// Passphrase derives the passphrase share of the cipher key, see SetPassphraseFactor.
+	Passphrase *MetadataPassphrase `json:"passphrase,omitempty"`
+	// Mnemonic wraps the cipher key under a key derived from a mnemonic, see NewFortifierWithMnemonic.
+	Mnemonic *MetadataMnemonic `json:"mnemonic,omitempty"`
+	// Dpapi protects the cipher key with DPAPI, see NewFortifierWithDpapi.
+	Dpapi *MetadataDpapi `json:"dpapi,omitempty"`
+	// Oidc wraps the cipher key for the unwrap service of an OIDC identity, see NewFortifierWithOidc.
+	Oidc *MetadataOidc `json:"oidc,omitempty"`
+	// AAD is the keyed digest of the additional data the file is bound to, see SetAAD.
+	AAD string `json:"aad,omitempty"`
+	// KeyHintSalt keys the key ID hints of the RSA recipients, see SetKeyHint.
+	KeyHintSalt string `json:"key_hint_salt,omitempty"`
+	// Age is the rest of the age file the stanzas of the age-ssh recipients belong to, see AgeHeader.
+	Age *MetadataAge `json:"age,omitempty"`
+	// Index is the searchable index sealed for the operators, see SetIndex.
+	Index *MetadataIndex `json:"index,omitempty"`
+	// Joint holds the shares of the cipher key of two parties recovering it together, see SetJointRecovery.
+	Joint *MetadataJoint `json:"joint,omitempty"`
+	// Parts index the named parts of a multi-part container, see EncryptParts.
+	Parts []*MetadataPart `json:"parts,omitempty"`
 }
 
 type Fortifier struct {
-	meta     *Metadata
-	key      *CipherKeyData
+	meta *Metadata
+	key  *CipherKeyData
+	// keyring holds candidate private keys tried in turn, see NewFortifierWithRsaKeyring
+	keyring [][]byte
+	// keyChain are fortified private keys recovered in turn from the key bytes, see SetKeyChain
+	keyChain [][]byte
 	verbose  bool
 	truncate bool
-	block    cipher.Block
+	sealMeta bool
+	// recipients and toSelf add RSA recipients, see AddRecipient and SetEncryptToSelf
+	recipients [][]byte
+	toSelf     bool
+	// ageRecipients are SSH public keys used as age recipients, see AddAgeRecipient
+	ageRecipients []ageRecipient
+	// recipientGroups names the group of each of recipients, see AddRecipientGroup
+	recipientGroups []string
+	// recipientLabels overrides the label of each of recipients, see AddLabeledRecipient
+	recipientLabels []string
+	// rsaRedundant wraps the cipher key twice for the RSA recipient, see SetRedundantWrap
+	rsaRedundant bool
+	// rsaScheme wraps the cipher key for the RSA recipients, see SetRsaScheme
+	rsaScheme RsaScheme
+	// kdfInfo derives the rsa-kem wrapping keys, or is pinned on recovery, see SetKDFInfo
+	kdfInfo string
+	// keyWrap wraps the cipher key under the rsa-kem wrapping keys, see SetKeyWrap
+	keyWrap KeyWrap
+	// rsaDual and rsaRecover wrap under and recover with other schemes, see SetDualWrap
+	rsaDual    []RsaScheme
+	rsaRecover string
+	// passFactor splits the cipher key with a passphrase share, see SetPassphraseFactor
+	passFactor bool
+	// joint are the two parties the cipher key is split between, see SetJointRecovery
+	joint []JointRecipient
+	// mnemonicPath derives the wrapping key from the mnemonic, see SetMnemonicPath
+	mnemonicPath string
+	// dpapiScope binds the cipher key protected by DPAPI, see SetDpapiScope
+	dpapiScope DpapiScope
+	// oidcIdentity, oidcToken and httpClient fortify to an OIDC identity, see SetOidcIdentity
+	oidcIdentity OidcIdentity
+	oidcToken    TokenSource
+	httpClient   *http.Client
+	// oidcServices are the unwrap services trusted on recovery, see SetOidcService
+	oidcServices []string
+	// index and operators seal searchable fields for operators, see SetIndex
+	index     map[string]string
+	operators [][]byte
+	// aad is the additional data bound to the file, see SetAAD
+	aad []byte
+	// aadChecked skips the check of aad, done by RecoverRaw for DecryptPayload
+	aadChecked bool
+	// seen records the cipher keys generated, see SetSeenStore
+	seen SeenStore
+	// random generates the cipher keys, defaults to crypto/rand
+	random io.Reader
+	// oaepSeed seeds the OAEP padding of the wrapped keys, see SetOAEPSeedReader
+	oaepSeed io.Reader
+	// rawSeed derives the cipher keys instead, in tests only, see SetRawSeed
+	rawSeed []byte
+	// selfTest recovers the cipher key just wrapped, with selfTestKeys if RSA, see SetSelfTest
+	selfTest     bool
+	selfTestKeys [][]byte
+	// requireKind pins the cipher key kind of recovered files, see RequireKeyKind
+	requireKind CipherKeyKind
+	// fips restricts the suites to those approved for FIPS mode, see SetFIPSMode
+	fips bool
+	// policy rejects legacy or weak parameters on recovery, see SetPolicy
+	policy Policy
+	// embedPub stores the public keys of the RSA recipients, see SetEmbedPublicKey
+	embedPub bool
+	// keyHint records key ID hints rather than fingerprints, see SetKeyHint
+	keyHint bool
+	// rsaLegacy writes the first RSA recipient into the legacy Rsa field, see SetLegacyRsaField
+	rsaLegacy bool
+	// stable orders recipients and parts by content, see SetStableMetadata
+	stable bool
+	// recovered describes the recovery of the cipher key, see Recover
+	recovered RecoverResult
+	// privateKey is the private key read by LoadPrivateKey
+	privateKey crypto.PrivateKey
+	// compressMeta stores the metadata gzip compressed, see SetCompressMetadata
+	compressMeta bool
+	block        cipher.Block
+	// keyCache caches the parsed keys of the RSA recipients, see SetKeyCache
+	keyCache *KeyCache
+	// passphrase supplies passphrases of encrypted private keys, defaults to the terminal
+	passphrase PassphraseProvider
+	// sshCAs and sshPrincipal restrict public keys to certificates, see SetTrustedCAs
+	sshCAs       []ssh.PublicKey
+	sshPrincipal string
+	// keyInfos describe the recipient keys by fingerprint, for wrapRsa to record
+	// the comments and certificate principals of the recipients
+	keyInfos map[string]KeyInfo
+	// recoverPrincipal and recoverCerts restrict the private key recovering the
+	// cipher key to a certified one, see SetRecoverPrincipal
+	recoverPrincipal string
+	recoverCerts     [][]byte
+}
+
+// String summarizes the Fortifier for logging. It reports whether the cipher
+// key is present but never prints key material or wrapped keys.
+func (f *Fortifier) String() string {
+	return fmt.Sprintf("Fortifier{kind:%s mode:%s verbose:%t recipients:%d raw:%t keyring:%d sealed:%t}",
+		f.key.kind, f.meta.Mode, f.verbose, f.recipientCount(), len(f.key.raw) > 0, len(f.keyring), f.sealMeta)
+}
+
+// recipientCount counts the holders able to recover the cipher key: the RSA
+// recipients once wrapped, or the secret shares.
+func (f *Fortifier) recipientCount() int {
+	switch f.key.kind {
+	case CipherKeyKindRSA:
+		return len(f.meta.rsaRecipients())
+	default:
+		return len(f.key.parts)
+	}
 }
 
 func NewEncrypter(mode CipherModeName, f *Fortifier) Encrypter {
@@ -59,21 +215,70 @@ func NewDecrypter(mode CipherModeName, f *Fortifier) Decrypter {
 	}
 }
 
+// ErrNoRecipients means no key to wrap the cipher key for, or to recover it
+// with, is configured.
+var ErrNoRecipients = errors.New("no recipients")
+
 func (f *Fortifier) SetupKey() (err error) {
-	if len(f.key.raw) > 0 {
+	if err = f.checkKeyKind(nil); err != nil {
 		return
 	}
-	switch f.key.kind {
-	case CipherKeyKindRSA:
-		err = f.setupRsaKey()
-	default:
-		err = f.setupSssKey()
+	if f.key.kind != CipherKeyKindRSA && (f.toSelf || len(f.recipients) > 0 || len(f.ageRecipients) > 0) {
+		return fmt.Errorf("additional recipients require cipher key kind %s, not %s", CipherKeyKindRSA, f.key.kind)
 	}
-	if err != nil {
-		return
+	if f.key.kind != CipherKeyKindRSA && (f.rsaScheme != "" || len(f.rsaDual) > 0) {
+		return fmt.Errorf("rsa scheme %s requires cipher key kind %s, not %s", f.rsaScheme, CipherKeyKindRSA, f.key.kind)
+	}
+	if f.key.kind != CipherKeyKindRSA && len(f.joint) > 0 {
+		return fmt.Errorf("joint recovery requires cipher key kind %s, not %s", CipherKeyKindRSA, f.key.kind)
 	}
-	if f.block, err = aes.NewCipher(f.key.raw); err != nil {
+	if f.key.kind != CipherKeyKindRSA && f.passFactor {
+		return fmt.Errorf("passphrase factor requires cipher key kind %s, not %s", CipherKeyKindRSA, f.key.kind)
+	}
+	if f.key.kind != CipherKeyKindRSA && f.keyHint {
+		return fmt.Errorf("key hints require cipher key kind %s, not %s", CipherKeyKindRSA, f.key.kind)
+	}
+	if len(f.key.raw) == 0 && f.meta.Rsa == nil && len(f.meta.Recipients) == 0 && f.meta.Joint == nil {
+		schemes := append([]RsaScheme{f.rsaScheme}, f.rsaDual...)
+		if len(f.ageRecipients) > 0 {
+			schemes = append(schemes, RsaSchemeAgeSSH)
+		}
+		if err = f.checkFIPS(f.key.kind, schemes...); err != nil {
+			return
+		}
+	} else if err = f.checkFIPS(f.key.kind); err != nil {
 		return
 	}
+	if len(f.key.raw) == 0 && f.key.kind == CipherKeyKindRSA && len(f.key.bytes) == 0 && len(f.keyring) == 0 && f.privateKey == nil {
+		// shares of a new SSS key are generated, but RSA needs a key to start from
+		if f.meta.Rsa != nil || len(f.meta.Recipients) > 0 || f.meta.Joint != nil {
+			return fmt.Errorf("%s: %w: %w: no private key provided", rsaFortifier, ErrEmptyKey, ErrNoRecipients)
+		}
+		if !f.toSelf && len(f.recipients) == 0 && len(f.ageRecipients) == 0 && len(f.joint) == 0 {
+			return fmt.Errorf("%s: %w: %w: no public key provided", rsaFortifier, ErrEmptyKey, ErrNoRecipients)
+		}
+	}
+	if len(f.key.raw) == 0 {
+		switch f.key.kind {
+		case CipherKeyKindRSA:
+			err = f.setupRsaKey()
+		case CipherKeyKindMnemonic:
+			err = f.setupMnemonicKey()
+		case CipherKeyKindDPAPI:
+			err = f.setupDpapiKey()
+		case CipherKeyKindOIDC:
+			err = f.setupOidcKey()
+		default:
+			err = f.setupSssKey()
+		}
+		if err != nil {
+			return
+		}
+	}
+	if f.block == nil {
+		if f.block, err = aes.NewCipher(f.key.raw); err != nil {
+			return
+		}
+	}
 	return
 }
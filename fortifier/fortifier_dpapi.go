@@ -0,0 +1,60 @@
+//go:build windows && !unix
+
+package fortifier
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func init() {
+	keyKinds[CipherKeyKindDPAPI] = KeyKindInfo{Kind: CipherKeyKindDPAPI}
+}
+
+var dpapiDescription, _ = windows.UTF16PtrFromString("fortify cipher key")
+
+// dpapiProtect protects the data with CryptProtectData under the scope, without
+// any prompt.
+func dpapiProtect(data, entropy []byte, scope DpapiScope) ([]byte, error) {
+	flags := uint32(windows.CRYPTPROTECT_UI_FORBIDDEN)
+	if scope == DpapiScopeMachine {
+		flags |= windows.CRYPTPROTECT_LOCAL_MACHINE
+	}
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(newDataBlob(data), dpapiDescription, newDataBlob(entropy), 0, nil, flags, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+// dpapiUnprotect recovers the data protected by dpapiProtect, which succeeds
+// only for the same user, or machine for the machine scope.
+func dpapiUnprotect(blob, entropy []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(newDataBlob(blob), nil, newDataBlob(entropy), 0, nil,
+		windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+func newDataBlob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// takeDataBlob copies the data allocated by DPAPI, then wipes and frees it.
+func takeDataBlob(blob *windows.DataBlob) []byte {
+	if blob.Data == nil {
+		return nil
+	}
+	data := unsafe.Slice(blob.Data, blob.Size)
+	b := make([]byte, len(data))
+	copy(b, data)
+	clear(data)
+	_, _ = windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	return b
+}
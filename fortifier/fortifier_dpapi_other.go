@@ -0,0 +1,11 @@
+//go:build !windows
+
+package fortifier
+
+func dpapiProtect([]byte, []byte, DpapiScope) ([]byte, error) {
+	return nil, ErrDpapiUnsupported
+}
+
+func dpapiUnprotect([]byte, []byte) ([]byte, error) {
+	return nil, ErrDpapiUnsupported
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package fortifier
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDpapiUnsupported(t *testing.T) {
+	f := NewFortifierWithDpapi(false, nil)
+	if err := f.SetDpapiScope("roaming"); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := f.SetupKey(); !errors.Is(err, ErrDpapiUnsupported) {
+		t.Fatalf("expect ErrDpapiUnsupported, got %v", err)
+	}
+}
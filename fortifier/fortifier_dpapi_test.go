@@ -0,0 +1,37 @@
+//go:build windows && !unix
+
+package fortifier
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestDpapiUserScope(t *testing.T) {
+	f := NewFortifierWithDpapi(false, nil)
+	if err := f.SetDpapiScope(DpapiScopeUser); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("bound to this user")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, encrypted)
+	defer func() { _ = in.Close() }()
+	meta := layout.Metadata()
+	if meta.Key != CipherKeyKindDPAPI || meta.Dpapi.Scope != DpapiScopeUser {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if err := layout.Verify(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	if err := NewDecrypter(meta.Mode, NewFortifierWithDpapi(false, meta)).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+	if !slices.ContainsFunc(SupportedKeyKinds(), func(info KeyKindInfo) bool { return info.Kind == CipherKeyKindDPAPI }) {
+		t.Fatalf("expect dpapi in supported key kinds")
+	}
+}
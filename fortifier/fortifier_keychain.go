@@ -0,0 +1,32 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrKeychainItemNotFound = errors.New("keychain item not found")
+
+// Keychain retrieves secrets kept in a credential store by service and account.
+type Keychain interface {
+	Secret(service, account string) ([]byte, error)
+}
+
+// SystemKeychain is the credential store of the operating system: the login
+// keychain on macOS, the Secret Service (via secret-tool) on other Unix systems
+// and the Credential Manager on Windows. The operating system prompts for
+// access to the item according to its own conventions.
+var SystemKeychain Keychain = systemKeychain{}
+
+// NewFortifierWithRsaKeychain makes a Fortifier recovering the cipher key with
+// the private key stored in the keychain under service and account.
+func NewFortifierWithRsaKeychain(verbose bool, meta *Metadata, keychain Keychain, service, account string) (*Fortifier, error) {
+	kb, err := keychain.Secret(service, account)
+	if err != nil {
+		return nil, fmt.Errorf("%s: keychain %s/%s: %w", rsaFortifier, service, account, err)
+	}
+	if len(kb) == 0 {
+		return nil, fmt.Errorf("%s: keychain %s/%s: empty private key", rsaFortifier, service, account)
+	}
+	return NewFortifierWithRsa(verbose, meta, kb), nil
+}
@@ -0,0 +1,32 @@
+//go:build darwin
+
+package fortifier
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os/exec"
+)
+
+type systemKeychain struct{}
+
+// Secret reads a generic password item with the security tool, which asks the
+// user to allow access when the item does not trust it already.
+func (systemKeychain) Secret(service, account string) ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 44 {
+		return nil, ErrKeychainItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimSuffix(out, []byte("\n"))
+	// security prints passwords that are not plain text, such as multi-line
+	// PEM blocks, in hexadecimal
+	if decoded, err := hex.DecodeString(string(out)); err == nil {
+		return decoded, nil
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type testKeychain map[string][]byte
+
+func (k testKeychain) Secret(service, account string) ([]byte, error) {
+	if secret, ok := k[service+"/"+account]; ok {
+		return secret, nil
+	}
+	return nil, ErrKeychainItemNotFound
+}
+
+func TestRsaKeychain(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("keychain payload")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	keychain := testKeychain{"fortify/alice": pri}
+
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f, err := NewFortifierWithRsaKeychain(false, layout.Metadata(), keychain, "fortify", "alice")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	if err = NewDecrypter(CipherModeAes256CTR, f).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(plaintext, out.Bytes()) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+	if _, err = NewFortifierWithRsaKeychain(false, layout.Metadata(), keychain, "fortify", "bob"); !errors.Is(err, ErrKeychainItemNotFound) {
+		t.Fatalf("expect ErrKeychainItemNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build unix && !darwin
+
+package fortifier
+
+import (
+	"errors"
+	"os/exec"
+)
+
+type systemKeychain struct{}
+
+// Secret looks the item up in the Secret Service with secret-tool, by its
+// service and account attributes. The keyring daemon prompts for unlocking.
+func (systemKeychain) Secret(service, account string) ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && len(out) == 0 {
+		return nil, ErrKeychainItemNotFound
+	}
+	return out, err
+}
@@ -0,0 +1,60 @@
+//go:build windows && !unix
+
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32     = windows.NewLazySystemDLL("advapi32.dll")
+	procCredRead = advapi32.NewProc("CredReadW")
+	procCredFree = advapi32.NewProc("CredFree")
+)
+
+const credTypeGeneric = 1
+
+// credential mirrors CREDENTIALW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+type systemKeychain struct{}
+
+// Secret reads the generic credential of the Credential Manager targeted at
+// the service, requiring its user name to be the account. Access is granted
+// to the logged-on user without a prompt, as Windows does for its own tools.
+func (systemKeychain) Secret(service, account string) ([]byte, error) {
+	target, err := windows.UTF16PtrFromString(service)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return nil, ErrKeychainItemNotFound
+		}
+		return nil, err
+	}
+	defer func() { _, _, _ = procCredFree.Call(uintptr(unsafe.Pointer(cred))) }()
+	if user := windows.UTF16PtrToString(cred.UserName); user != account {
+		return nil, fmt.Errorf("%w: credential of user %q", ErrKeychainItemNotFound, user)
+	}
+	return append([]byte(nil), unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)...), nil
+}
@@ -0,0 +1,205 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+const mnemonicFortifier = "mnemonic_fortifier"
+
+// DefaultMnemonicPath is the hardened BIP32 path of the wrapping key derived
+// from a mnemonic, away from the purposes used by wallets such as 44' and 84'.
+const DefaultMnemonicPath = "m/7027'/0'"
+
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// MetadataMnemonic holds the cipher key wrapped under the key derived from a
+// BIP39 mnemonic along the BIP32 path. The mnemonic itself is never stored.
+type MetadataMnemonic struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Digest     string    `json:"digest"`
+	Path       string    `json:"path"`
+	Ciphertext string    `json:"ciphertext"`
+}
+
+// NewFortifierWithMnemonic makes a Fortifier wrapping the cipher key under the
+// key derived from the BIP39 mnemonic, or recovering it when meta records a
+// wrapped key. meta may be nil. An empty mnemonic is read from the passphrase
+// provider when the key is set up. The mnemonic has no BIP39 passphrase.
+func NewFortifierWithMnemonic(verbose bool, meta *Metadata, mnemonic []byte) *Fortifier {
+	var m *MetadataMnemonic
+	if meta != nil {
+		m = meta.Mnemonic
+	}
+	return &Fortifier{
+		meta:    &Metadata{Mnemonic: m},
+		key:     &CipherKeyData{kind: CipherKeyKindMnemonic, bytes: mnemonic},
+		verbose: verbose,
+	}
+}
+
+// SetMnemonicPath sets the hardened BIP32 path, such as DefaultMnemonicPath, of
+// the wrapping key derived from the mnemonic when encrypting.
+func (f *Fortifier) SetMnemonicPath(path string) error {
+	if _, err := parseBip32Path(path); err != nil {
+		return err
+	}
+	f.mnemonicPath = path
+	return nil
+}
+
+func (f *Fortifier) setupMnemonicKey() (err error) {
+	m := f.meta.Mnemonic
+	if m == nil {
+		path := f.mnemonicPath
+		if path == "" {
+			path = DefaultMnemonicPath
+		}
+		m = &MetadataMnemonic{Timestamp: time.Now(), Path: path}
+	}
+	var aead cipher.AEAD
+	if aead, err = f.newMnemonicAEAD(m.Path); err != nil {
+		return
+	}
+	if f.meta.Mnemonic != nil {
+		return f.unwrapMnemonic(aead, m)
+	}
+	var raw []byte
+	if raw, err = f.newRaw(); err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	m.Digest = utils.ComputeDigest(raw)
+	m.Ciphertext = base64.URLEncoding.EncodeToString(aead.Seal(nonce, nonce, raw, []byte(m.Path)))
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindMnemonic
+	f.meta.Timestamp = time.Now()
+	f.meta.Mnemonic = m
+	return f.checkSelfTest()
+}
+
+func (f *Fortifier) unwrapMnemonic(aead cipher.AEAD, m *MetadataMnemonic) error {
+	sealed, err := base64.URLEncoding.DecodeString(m.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("%s: invalid ciphertext: %v", mnemonicFortifier, err)
+	}
+	size := aead.NonceSize()
+	if len(sealed) < size {
+		return fmt.Errorf("%s: invalid ciphertext: too short", mnemonicFortifier)
+	}
+	raw, err := aead.Open(nil, sealed[:size], sealed[size:], []byte(m.Path))
+	if err != nil {
+		return fmt.Errorf("%s: wrong mnemonic or corrupted ciphertext", mnemonicFortifier)
+	}
+	digest := utils.ComputeDigest(raw)
+	if subtle.ConstantTimeCompare([]byte(digest), []byte(m.Digest)) != 1 {
+		return fmt.Errorf("%s: digest mismatch", mnemonicFortifier)
+	}
+	f.key.raw = raw
+	return nil
+}
+
+// newMnemonicAEAD derives the wrapping key of the path from the mnemonic,
+// validating its words and checksum.
+func (f *Fortifier) newMnemonicAEAD(path string) (cipher.AEAD, error) {
+	indexes, err := parseBip32Path(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", mnemonicFortifier, err)
+	}
+	mnemonic := []byte(f.key.bytes)
+	if len(mnemonic) == 0 {
+		if mnemonic, err = f.enterPassphrase("Enter mnemonic phrase: "); err != nil {
+			return nil, err
+		}
+		defer clear(mnemonic)
+	}
+	seed, err := bip39Seed(string(mnemonic), "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w -- %v", mnemonicFortifier, ErrInvalidMnemonic, err)
+	}
+	defer clear(seed)
+	key, err := deriveBip32(seed, indexes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", mnemonicFortifier, err)
+	}
+	defer clear(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+const bip32Hardened = 1 << 31
+
+// secp256k1N is the order of the secp256k1 group, bounding BIP32 private keys.
+var secp256k1N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+// parseBip32Path parses a path like m/7027'/0' into its child indexes. Only
+// hardened indexes, marked by ' or h, are supported, which derive from the
+// private key alone.
+func parseBip32Path(path string) ([]uint32, error) {
+	elems := strings.Split(path, "/")
+	if elems[0] != "m" {
+		return nil, fmt.Errorf("invalid bip32 path %q: expect m/...", path)
+	}
+	indexes := make([]uint32, 0, len(elems)-1)
+	for _, elem := range elems[1:] {
+		trimmed := strings.TrimRight(elem, "'hH")
+		if len(elem)-len(trimmed) != 1 {
+			return nil, fmt.Errorf("invalid bip32 path %q: index %q is not hardened", path, elem)
+		}
+		i, err := strconv.ParseUint(trimmed, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bip32 path %q: index %q", path, elem)
+		}
+		indexes = append(indexes, uint32(i)+bip32Hardened)
+	}
+	return indexes, nil
+}
+
+// deriveBip32 derives the private key of the hardened child indexes from the
+// master key of the BIP32 seed.
+func deriveBip32(seed []byte, indexes []uint32) ([]byte, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chain := sum[:32], sum[32:]
+	if k := new(big.Int).SetBytes(key); k.Sign() == 0 || k.Cmp(secp256k1N) >= 0 {
+		return nil, errors.New("invalid bip32 master key")
+	}
+	for _, index := range indexes {
+		mac = hmac.New(sha512.New, chain)
+		mac.Write([]byte{0})
+		mac.Write(key)
+		mac.Write(binary.BigEndian.AppendUint32(nil, index))
+		sum = mac.Sum(nil)
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(secp256k1N) >= 0 {
+			return nil, fmt.Errorf("invalid bip32 child %d", index-bip32Hardened)
+		}
+		k := il.Add(il, new(big.Int).SetBytes(key))
+		if k.Mod(k, secp256k1N).Sign() == 0 {
+			return nil, fmt.Errorf("invalid bip32 child %d", index-bip32Hardened)
+		}
+		key, chain = k.FillBytes(make([]byte, 32)), sum[32:]
+	}
+	return key, nil
+}
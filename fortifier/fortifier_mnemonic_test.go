@@ -0,0 +1,135 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func testDecryptMnemonicFile(t testing.TB, path string, f func(meta *Metadata) *Fortifier) ([]byte, error) {
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	fortifier := f(layout.Metadata())
+	if err := fortifier.OpenMetadata(layout); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := NewDecrypter(layout.Metadata().Mode, fortifier).Decrypt(in, &out, layout); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func TestMnemonic(t *testing.T) {
+	dir := t.TempDir()
+	f := NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+	if err := f.SetMnemonicPath("m/7027'/1h"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("written down")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, dir, plaintext)
+	head, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Contains(head, []byte("abandon")) {
+		t.Fatalf("mnemonic stored in the head")
+	}
+	in, layout := testReadLayout(t, encrypted)
+	_ = in.Close()
+	if meta := layout.Metadata(); meta.Key != CipherKeyKindMnemonic || meta.Mnemonic.Path != "m/7027'/1h" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if err = layout.Verify(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// recovery from the phrase as written down, whitespace aside, or as prompted for
+	spaced := "  " + strings.ReplaceAll(testMnemonic, " ", "\n  ") + "\n"
+	actual, err := testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+		return NewFortifierWithMnemonic(false, meta, []byte(spaced))
+	})
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	actual, err = testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+		f := NewFortifierWithMnemonic(false, meta, nil)
+		f.SetPassphraseProvider(testPassphrase(testMnemonic))
+		return f
+	})
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+
+	other := strings.Repeat("zoo ", 11) + "wrong"
+	if _, err = testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+		return NewFortifierWithMnemonic(false, meta, []byte(other))
+	}); err == nil || errors.Is(err, ErrInvalidMnemonic) {
+		t.Fatalf("expect a wrong mnemonic error, got %v", err)
+	}
+}
+
+func TestMnemonicSealed(t *testing.T) {
+	f := NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+	f.SetSealMetadata(true)
+	plaintext := []byte("sealed and written down")
+	encrypted := testEncryptFile(t, f, CipherModeAes256OFB, t.TempDir(), plaintext)
+	actual, err := testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+		return NewFortifierWithMnemonic(false, meta, []byte(testMnemonic))
+	})
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+}
+
+func TestMnemonicChecksum(t *testing.T) {
+	invalid := strings.Repeat("abandon ", 11) + "abandon"
+	f := NewFortifierWithMnemonic(false, nil, []byte(invalid))
+	if err := f.SetupKey(); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Fatalf("expect ErrInvalidMnemonic, got %v", err)
+	}
+	f = NewFortifierWithMnemonic(false, nil, []byte(strings.Replace(testMnemonic, "about", "abut", 1)))
+	if err := f.SetupKey(); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Fatalf("expect ErrInvalidMnemonic, got %v", err)
+	}
+	for _, path := range []string{"", "m/0", "m/0'/1", "n/0'", "m/0''", "m/2147483648'"} {
+		if err := f.SetMnemonicPath(path); err == nil {
+			t.Fatalf("expect error for %q", path)
+		}
+	}
+	f = NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+	f.SetFIPSMode(true)
+	if err := f.SetupKey(); !errors.Is(err, ErrNonFIPSAlgorithm) {
+		t.Fatalf("expect ErrNonFIPSAlgorithm, got %v", err)
+	}
+}
+
+func TestDeriveBip32(t *testing.T) {
+	// test vector 1 of BIP32
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	for path, expect := range map[string]string{
+		"m":     "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		"m/0'":  "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		"m/0H'": "",
+	} {
+		indexes, err := parseBip32Path(path)
+		if expect == "" {
+			if err == nil {
+				t.Fatalf("expect error for %q", path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		key, err := deriveBip32(seed, indexes)
+		if err != nil || hex.EncodeToString(key) != expect {
+			t.Fatalf("bad %s: %x, err: %v", path, key, err)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package fortifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+const oidcFortifier = "oidc_fortifier"
+
+// OidcTokenEnv names the environment variable holding the OIDC token presented
+// to the unwrap service by default.
+const OidcTokenEnv = "FORTIFY_OIDC_TOKEN"
+
+// OidcServiceEnv names the environment variable holding the URL of the unwrap
+// service trusted on recovery, unless SetOidcService sets them.
+const OidcServiceEnv = "FORTIFY_OIDC_SERVICE"
+
+// ErrOidcUnwrap means the unwrap service refused or failed to unwrap the cipher key.
+var ErrOidcUnwrap = errors.New("oidc unwrap failed")
+
+// ErrOidcServiceNotAllowed means the head names an unwrap service other than
+// the ones trusted on recovery, which the token is never presented to.
+var ErrOidcServiceNotAllowed = errors.New("oidc unwrap service not allowed")
+
+// oidcAllowHTTP accepts plain http unwrap services, in tests only.
+var oidcAllowHTTP = false
+
+// oidcTimeout bounds a call to the unwrap service, so that a stalled service
+// fails the recovery rather than hanging it.
+var oidcTimeout = 30 * time.Second
+
+// OidcIdentity is the OIDC subject the cipher key is fortified to, and the
+// unwrap service recovering it for a valid token of that subject.
+type OidcIdentity struct {
+	Issuer   string `json:"issuer"`
+	Subject  string `json:"subject"`
+	Audience string `json:"audience"`
+	// Service is the URL of the unwrap service
+	Service string `json:"service"`
+}
+
+// Label returns the RSA-OAEP label binding a wrapped cipher key to the issuer,
+// subject and audience, which the unwrap service must derive from the claims of
+// the token it validated rather than from the request.
+func (id OidcIdentity) Label() []byte {
+	return []byte(strings.Join([]string{"fortify oidc", id.Issuer, id.Subject, id.Audience}, "\x00"))
+}
+
+// MetadataOidc holds the cipher key wrapped for the unwrap service of the OIDC identity.
+type MetadataOidc struct {
+	OidcIdentity
+	Timestamp  time.Time `json:"timestamp"`
+	Digest     string    `json:"digest"`
+	Ciphertext string    `json:"ciphertext"`
+	// Fingerprint identifies the public key of the unwrap service
+	Fingerprint string `json:"fingerprint"`
+}
+
+// OidcUnwrapRequest is the JSON body posted to the unwrap service, along with
+// the token as a bearer token.
+type OidcUnwrapRequest struct {
+	OidcIdentity
+	Ciphertext  string `json:"ciphertext"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// OidcUnwrapResponse is the JSON body of a successful unwrap response, with
+// the cipher key in URL base64.
+type OidcUnwrapResponse struct {
+	Key string `json:"key"`
+}
+
+// TokenSource supplies the OIDC token presented to the unwrap service.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenFunc adapts a function to a TokenSource.
+type TokenFunc func() (string, error)
+
+func (fn TokenFunc) Token() (string, error) {
+	return fn()
+}
+
+// EnvTokenSource reads the token from the environment variable OidcTokenEnv.
+var EnvTokenSource TokenSource = TokenFunc(func() (string, error) {
+	if token := strings.TrimSpace(os.Getenv(OidcTokenEnv)); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("no oidc token, set %s", OidcTokenEnv)
+})
+
+// NewFortifierWithOidc makes a Fortifier wrapping the cipher key for the OIDC
+// identity of SetOidcIdentity under the public key bytes of its unwrap service,
+// or recovering it from the service when meta records a wrapped key. meta may
+// be nil, and so may the key bytes on recovery.
+func NewFortifierWithOidc(verbose bool, meta *Metadata, servicePub []byte) *Fortifier {
+	var m *MetadataOidc
+	if meta != nil {
+		m = meta.Oidc
+	}
+	return &Fortifier{
+		meta:    &Metadata{Oidc: m},
+		key:     &CipherKeyData{kind: CipherKeyKindOIDC, bytes: servicePub},
+		verbose: verbose,
+	}
+}
+
+// SetOidcIdentity sets the OIDC identity to fortify the cipher key to.
+func (f *Fortifier) SetOidcIdentity(id OidcIdentity) error {
+	if id.Issuer == "" || id.Subject == "" || id.Audience == "" {
+		return errors.New("oidc identity requires an issuer, a subject and an audience")
+	}
+	if err := checkOidcService(id.Service); err != nil {
+		return err
+	}
+	f.oidcIdentity = id
+	return nil
+}
+
+// SetOidcService sets the URLs of the unwrap services trusted on recovery, in
+// place of the one of OidcServiceEnv. The token is presented only to a service
+// of the head among them, so that a crafted file cannot send it elsewhere.
+func (f *Fortifier) SetOidcService(services ...string) error {
+	for _, s := range services {
+		if err := checkOidcService(s); err != nil {
+			return err
+		}
+	}
+	f.oidcServices = services
+	return nil
+}
+
+// checkOidcService checks the URL of an unwrap service is an https one.
+func checkOidcService(service string) error {
+	u, err := url.Parse(service)
+	if err != nil || (u.Scheme != "https" && !(oidcAllowHTTP && u.Scheme == "http")) || u.Host == "" {
+		return fmt.Errorf("invalid oidc unwrap service url %q, expecting https", service)
+	}
+	return nil
+}
+
+// trustOidcService checks the unwrap service of the head is one of those of
+// SetOidcService, or the one of OidcServiceEnv.
+func (f *Fortifier) trustOidcService(service string) error {
+	trusted := f.oidcServices
+	if trusted == nil {
+		if env := strings.TrimSpace(os.Getenv(OidcServiceEnv)); env != "" {
+			trusted = []string{env}
+		}
+	}
+	if !slices.Contains(trusted, service) {
+		return fmt.Errorf("%w: %q, see SetOidcService or %s", ErrOidcServiceNotAllowed, service, OidcServiceEnv)
+	}
+	return checkOidcService(service)
+}
+
+// SetOidcTokenSource replaces EnvTokenSource as the source of the token
+// presented to the unwrap service.
+func (f *Fortifier) SetOidcTokenSource(ts TokenSource) {
+	f.oidcToken = ts
+}
+
+// SetHTTPClient replaces http.DefaultClient for calls to the unwrap service and
+// the lookups of AddWKDRecipient. Both give up after their own timeout, whatever
+// the timeout of the client.
+func (f *Fortifier) SetHTTPClient(c *http.Client) {
+	f.httpClient = c
+}
+
+func (f *Fortifier) setupOidcKey() (err error) {
+	if f.meta.Oidc != nil {
+		return f.unwrapOidc(f.meta.Oidc)
+	}
+	id := f.oidcIdentity
+	if id.Service == "" {
+		return fmt.Errorf("%s: no oidc identity, see SetOidcIdentity", oidcFortifier)
+	}
+	if len(f.key.bytes) == 0 {
+		return fmt.Errorf("%s: service key: %w", oidcFortifier, ErrEmptyKey)
+	}
+	var pub *rsa.PublicKey
+	if pub, err = f.parseRsaPublicKey(); err != nil {
+		return fmt.Errorf("%s: service key: %w", oidcFortifier, err)
+	}
+	var raw, encrypted []byte
+	if raw, err = f.newRaw(); err != nil {
+		return
+	}
+	if encrypted, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, raw, id.Label()); err != nil {
+		return
+	}
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindOIDC
+	f.meta.Timestamp = time.Now()
+	f.meta.Oidc = &MetadataOidc{OidcIdentity: id, Timestamp: f.meta.Timestamp, Digest: utils.ComputeDigest(raw),
+		Ciphertext: base64.URLEncoding.EncodeToString(encrypted), Fingerprint: PublicKeyFingerprint(pub)}
+	return
+}
+
+// unwrapOidc presents the token to the unwrap service to recover the cipher key,
+// giving up after 30 seconds.
+func (f *Fortifier) unwrapOidc(m *MetadataOidc) error {
+	if err := f.trustOidcService(m.Service); err != nil {
+		return fmt.Errorf("%s: %w", oidcFortifier, err)
+	}
+	ts := f.oidcToken
+	if ts == nil {
+		ts = EnvTokenSource
+	}
+	token, err := ts.Token()
+	if err != nil {
+		return fmt.Errorf("%s: %w", oidcFortifier, err)
+	}
+	body, err := json.Marshal(&OidcUnwrapRequest{OidcIdentity: m.OidcIdentity, Ciphertext: m.Ciphertext,
+		Fingerprint: m.Fingerprint})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oidcTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.Service, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", oidcFortifier, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	client := f.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", oidcFortifier, ErrOidcUnwrap, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %w: %s %s", oidcFortifier, ErrOidcUnwrap, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var unwrapped OidcUnwrapResponse
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&unwrapped); err != nil {
+		return fmt.Errorf("%s: %w: invalid response: %v", oidcFortifier, ErrOidcUnwrap, err)
+	}
+	raw, err := base64.URLEncoding.DecodeString(unwrapped.Key)
+	if err != nil {
+		return fmt.Errorf("%s: %w: invalid key: %v", oidcFortifier, ErrOidcUnwrap, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.ComputeDigest(raw)), []byte(m.Digest)) != 1 {
+		clear(raw)
+		return fmt.Errorf("%s: %w: digest mismatch", oidcFortifier, ErrOidcUnwrap)
+	}
+	f.key.raw = raw
+	return nil
+}
@@ -0,0 +1,179 @@
+package fortifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testOidcAlice = OidcIdentity{Issuer: "https://issuer.example.com", Subject: "alice", Audience: "fortify"}
+
+// testUnwrapServer mocks an unwrap service validating tokens by a fixed table
+// of the identity each token asserts.
+func testUnwrapServer(t testing.TB, pri *rsa.PrivateKey, tokens map[string]OidcIdentity) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		if !ok {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		var req OidcUnwrapRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ciphertext, err := base64.URLEncoding.DecodeString(req.Ciphertext)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the label comes from the validated token, never from the request
+		raw, err := rsa.DecryptOAEP(sha256.New(), nil, pri, ciphertext, id.Label())
+		if err != nil {
+			http.Error(w, "not wrapped for this identity", http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&OidcUnwrapResponse{Key: base64.URLEncoding.EncodeToString(raw)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOidc(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	bob := testOidcAlice
+	bob.Subject = "bob"
+	server := testUnwrapServer(t, testRsaPrivateKey(t), map[string]OidcIdentity{
+		"alice-token": testOidcAlice, "bob-token": bob})
+	id := testOidcAlice
+	id.Service = server.URL + "/unwrap"
+
+	dir := t.TempDir()
+	f := NewFortifierWithOidc(false, nil, pub)
+	if err := f.SetOidcIdentity(id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("released to a workload")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, dir, plaintext)
+	in, layout := testReadLayout(t, encrypted)
+	_ = in.Close()
+	if meta := layout.Metadata(); meta.Key != CipherKeyKindOIDC || meta.Oidc.OidcIdentity != id {
+		t.Fatalf("unexpected metadata: %+v", meta.Oidc)
+	}
+	if err := layout.Verify(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	decrypt := func(token string, services ...string) ([]byte, error) {
+		return testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+			f := NewFortifierWithOidc(false, meta, nil)
+			f.SetHTTPClient(server.Client())
+			f.SetOidcTokenSource(TokenFunc(func() (string, error) {
+				if token == "" {
+					t.Fatalf("token requested for an untrusted service")
+				}
+				return token, nil
+			}))
+			if err := f.SetOidcService(services...); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			return f
+		})
+	}
+	// the token goes only to a service trusted on recovery
+	t.Setenv(OidcServiceEnv, "")
+	for _, services := range [][]string{nil, {"https://unwrap.example.com/unwrap"}} {
+		if _, err := decrypt("", services...); !errors.Is(err, ErrOidcServiceNotAllowed) {
+			t.Fatalf("expect ErrOidcServiceNotAllowed, got %v", err)
+		}
+	}
+	t.Setenv(OidcServiceEnv, id.Service)
+	if actual, err := decrypt("alice-token"); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	t.Setenv(OidcServiceEnv, "")
+	actual, err := decrypt("alice-token", id.Service)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	if _, err = decrypt("forged-token", id.Service); !errors.Is(err, ErrOidcUnwrap) || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expect an invalid token error, got %v", err)
+	}
+	if _, err = decrypt("bob-token", id.Service); !errors.Is(err, ErrOidcUnwrap) || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expect a wrong identity error, got %v", err)
+	}
+}
+
+func TestOidcTimeout(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	stalled := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// once the request is read, the server notices the client giving up
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer stalled.Close()
+	id := testOidcAlice
+	id.Service = stalled.URL + "/unwrap"
+	f := NewFortifierWithOidc(false, nil, pub)
+	if err := f.SetOidcIdentity(id); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+
+	timeout := oidcTimeout
+	oidcTimeout = 100 * time.Millisecond
+	defer func() { oidcTimeout = timeout }()
+	_, err := testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+		f := NewFortifierWithOidc(false, meta, nil)
+		f.SetHTTPClient(stalled.Client())
+		f.SetOidcTokenSource(TokenFunc(func() (string, error) { return "alice-token", nil }))
+		if err := f.SetOidcService(id.Service); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return f
+	})
+	if !errors.Is(err, ErrOidcUnwrap) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect a timeout, got %v", err)
+	}
+}
+
+func TestOidcIdentity(t *testing.T) {
+	f := NewFortifierWithOidc(false, nil, nil)
+	id := testOidcAlice
+	if err := f.SetOidcIdentity(id); err == nil {
+		t.Fatalf("expect error")
+	}
+	for _, service := range []string{"file:///unwrap", "http://unwrap.example.com"} {
+		id.Service = service
+		if err := f.SetOidcIdentity(id); err == nil {
+			t.Fatalf("expect error for %s", service)
+		}
+		if err := f.SetOidcService(service); err == nil {
+			t.Fatalf("expect error for %s", service)
+		}
+	}
+	oidcAllowHTTP = true
+	err := f.SetOidcService("http://127.0.0.1:8080/unwrap")
+	oidcAllowHTTP = false
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	id.Service = "https://unwrap.example.com"
+	id.Subject = ""
+	if err := f.SetOidcIdentity(id); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
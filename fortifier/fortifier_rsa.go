@@ -1,21 +1,15 @@
 package fortifier
 
 import (
-	"crypto/rand"
+	"crypto"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
-	"encoding/pem"
 	"errors"
 	"fmt"
-	"reflect"
-	"strings"
+	"slices"
 	"time"
 
-	"github.com/deatil/go-cryptobin/pkcs8"
 	"github.com/i3ash/fortify/utils"
-	"golang.org/x/crypto/ssh"
 )
 
 const rsaFortifier = "rsa_fortifier"
@@ -24,173 +18,499 @@ type MetadataRsa struct {
 	Timestamp  time.Time `json:"timestamp"`
 	Digest     string    `json:"digest"`
 	Ciphertext string    `json:"ciphertext"`
+	// CiphertextDigest binds the ciphertext so a damaged entry is detected before unwrapping
+	CiphertextDigest string `json:"ciphertext_digest,omitempty"`
+	// Redundant is a second wrapping of the same key under independent OAEP
+	// randomness, tried when Ciphertext fails to unwrap, see SetRedundantWrap
+	Redundant       string `json:"redundant,omitempty"`
+	RedundantDigest string `json:"redundant_digest,omitempty"`
+	// Fingerprint identifies the recipient public key to shortlist private keys of a keyring
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// KeyHint replaces Fingerprint with a salted hint of the public key, see SetKeyHint
+	KeyHint string `json:"key_hint,omitempty"`
+	// Scheme wraps the cipher key, RSA-OAEP if empty, see SetRsaScheme
+	Scheme RsaScheme `json:"scheme,omitempty"`
+	// PublicKey is the base64 DER SubjectPublicKeyInfo of the recipient, see SetEmbedPublicKey
+	PublicKey string `json:"public_key,omitempty"`
+	// Group names the recipient group the recipient was added with, see AddRecipientGroup
+	Group string `json:"group,omitempty"`
+	// Role is RecipientRoleOperator for the recipients of the index key, see
+	// SetIndex, and empty for the recipients of the cipher key
+	Role string `json:"role,omitempty"`
+	// KDFInfo is the HKDF info of the rsa-kem scheme, the package label if empty, see SetKDFInfo
+	KDFInfo string `json:"kdf_info,omitempty"`
+	// KeyWrap wraps the cipher key of the rsa-kem scheme, AES-256-GCM if empty, see SetKeyWrap
+	KeyWrap KeyWrap `json:"key_wrap,omitempty"`
+	// Principals are those of the SSH certificate the recipient was given as, for audit only
+	Principals []string `json:"principals,omitempty"`
+	// Label names the recipient for people reading the head, the comment of its
+	// authorized key, such as user@host, unless set by AddLabeledRecipient
+	Label string `json:"label,omitempty"`
 }
 
+// ErrEmptyKey means the RSA key bytes are nil or empty while nothing else, such
+// as an additional recipient or a keyring, supplies a key.
+var ErrEmptyKey = errors.New("empty key bytes")
+
+// NewFortifierWithRsa makes a Fortifier wrapping the cipher key for the public
+// key bytes, or recovering it with the private key bytes when meta records RSA
+// recipients. meta may be nil. The key bytes may be nil or empty only if the key
+// comes from elsewhere: AddRecipient, SetEncryptToSelf, LoadPrivateKey or a
+// keyring; otherwise SetupKey fails with ErrEmptyKey.
 func NewFortifierWithRsa(verbose bool, meta *Metadata, bytes []byte) *Fortifier {
 	var m *MetadataRsa
+	var recipients []*MetadataRsa
+	var passphrase *MetadataPassphrase
+	var salt string
+	var ageFile *MetadataAge
+	var joint *MetadataJoint
 	if meta != nil {
-		m = meta.Rsa
+		m, recipients, passphrase, salt, ageFile = meta.Rsa, meta.Recipients, meta.Passphrase, meta.KeyHintSalt, meta.Age
+		joint = meta.Joint
 	}
 	return &Fortifier{
-		meta:    &Metadata{Rsa: m},
+		meta: &Metadata{Rsa: m, Recipients: recipients, Passphrase: passphrase, KeyHintSalt: salt, Age: ageFile,
+			Joint: joint},
 		key:     &CipherKeyData{kind: CipherKeyKindRSA, bytes: bytes},
 		verbose: verbose,
 	}
 }
 
 func (f *Fortifier) setupRsaKey() error {
-	if f.meta.Rsa == nil {
+	if f.meta.Joint != nil {
+		return f.setupJointPrivateKeys()
+	}
+	if f.meta.Rsa == nil && len(f.meta.Recipients) == 0 {
+		if len(f.joint) > 0 {
+			return f.setupJointPublicKeys()
+		}
 		return f.setupRsaPublicKey()
 	} else {
-		return f.setupRsaPrivateKey()
+		return f.setupPrivateKey()
 	}
 }
 
 func (f *Fortifier) setupRsaPublicKey() (err error) {
-	var pub *rsa.PublicKey
-	parsed, _, _, _, x := ssh.ParseAuthorizedKey(f.key.bytes)
-	if x != nil {
-		parsed, err = ParseSSH2PublicKey(string(f.key.bytes))
-	}
-	if parsed != nil {
-		if parsedCryptoKey, ok := parsed.(ssh.CryptoPublicKey); ok {
-			k := parsedCryptoKey.CryptoPublicKey()
-			pub, _ = k.(*rsa.PublicKey)
-		}
-	}
-	if pub == nil {
-		blocks := f.decodePemFile()
-		if len(blocks) == 0 {
-			return fmt.Errorf("%s: pem file decoding failed", rsaFortifier)
-		}
-		block := &blocks[0]
-		var k any
-		switch block.Type {
-		case "RSA PUBLIC KEY":
-			if k, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
-				return fmt.Errorf("%s: not public key in PKCS #1, ASN.1 DER form -- %v", rsaFortifier, err)
-			}
-		case "PUBLIC KEY":
-			if k, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
-				return fmt.Errorf("%s: error parsing PKCS#8 public key -- %v", rsaFortifier, err)
-			}
+	if f.keyHint && f.embedPub {
+		return fmt.Errorf("%s: key hints conflict with embedded public keys", rsaFortifier)
+	}
+	extras, groups, labels := f.recipients, f.recipientGroups, f.recipientLabels
+	if f.toSelf {
+		var self []byte
+		if self, err = SelfPublicKey(); err != nil {
+			return fmt.Errorf("%s: %w", rsaFortifier, err)
+		}
+		extras = append(extras[:len(extras):len(extras)], self)
+		groups = append(groups[:len(groups):len(groups)], "")
+		labels = append(labels[:len(labels):len(labels)], "")
+	}
+	// without the key bytes, the additional recipients stand in for the missing key
+	var pubs []*rsa.PublicKey
+	var pubGroups, pubLabels []string
+	if len(f.key.bytes) > 0 {
+		var pub *rsa.PublicKey
+		if pub, err = f.parseRsaPublicKey(); err != nil {
+			return
+		}
+		pubs, pubGroups, pubLabels = append(pubs, pub), append(pubGroups, ""), append(pubLabels, "")
+	}
+	for i, kb := range extras {
+		var extra *rsa.PublicKey
+		if extra, err = f.parseRsaRecipient(kb); err != nil {
+			return
 		}
-		if k == nil {
-			return fmt.Errorf("%s: unsupported key type %q", rsaFortifier, block.Type)
+		if slices.ContainsFunc(pubs, func(k *rsa.PublicKey) bool { return k.Equal(extra) }) {
+			continue
 		}
-		pub = k.(*rsa.PublicKey)
+		pubs = append(pubs, extra)
+		pubGroups, pubLabels = append(pubGroups, groups[i]), append(pubLabels, labels[i])
 	}
-	if pub == nil {
-		return
+	if f.keyHint {
+		if err = f.newKeyHintSalt(); err != nil {
+			return
+		}
 	}
-	raw := make([]byte, 32)
-	if _, err = rand.Read(raw); err != nil {
+	var raw []byte
+	if raw, err = f.newRaw(); err != nil {
 		return
 	}
-	var encrypted []byte
-	if encrypted, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, raw, nil); err != nil {
+	share := raw
+	if f.passFactor {
+		if share, err = f.splitPassphraseFactor(raw); err != nil {
+			return
+		}
+	}
+	wrapped := make([][]*MetadataRsa, len(pubs))
+	run := forEach
+	if f.oaepSeed != nil {
+		// the seeds are read in recipient order, for a reproducible wrapping
+		run = forEachInOrder
+	}
+	if err = run(len(pubs), func(i int) (err error) {
+		if wrapped[i], err = f.wrapRsaSchemes(pubs[i], share); err != nil {
+			return
+		}
+		for _, m := range wrapped[i] {
+			m.Group = pubGroups[i]
+			if pubLabels[i] != "" {
+				m.Label = pubLabels[i]
+			}
+		}
+		return
+	}); err != nil {
 		return
 	}
+	recipients := slices.Concat(wrapped...)
+	ageEntries := f.newAgeEntries(share)
+	recipients = append(recipients, ageEntries...)
+	if f.stable && f.rsaLegacy {
+		sortRecipients(recipients[1:])
+	} else if f.stable {
+		sortRecipients(recipients)
+	}
+	f.meta.Age = nil
+	if len(ageEntries) > 0 {
+		if f.meta.Age, err = f.wrapAgeSSH(recipients, ageEntries, share); err != nil {
+			return
+		}
+	}
 	f.key.raw = raw
 	f.meta.Key = CipherKeyKindRSA
 	f.meta.Timestamp = time.Now()
-	f.meta.Rsa = &MetadataRsa{
-		Timestamp:  time.Now(),
-		Digest:     utils.ComputeDigest(raw),
-		Ciphertext: base64.URLEncoding.EncodeToString(encrypted),
+	f.meta.Rsa, f.meta.Recipients = nil, recipients
+	if f.rsaLegacy {
+		f.meta.Rsa, f.meta.Recipients = recipients[0], recipients[1:]
 	}
-	return
+	return f.checkSelfTest()
 }
 
-func (f *Fortifier) setupRsaPrivateKey() (err error) {
-	var pri *rsa.PrivateKey
-	if pri, err = f.parseRsaPrivateKey(); err != nil {
+// wrapRsa wraps the cipher key for the public key with the scheme, twice if
+// SetRedundantWrap is enabled.
+func (f *Fortifier) wrapRsa(pub *rsa.PublicKey, raw []byte, scheme RsaScheme) (m *MetadataRsa, err error) {
+	var encrypted []byte
+	if encrypted, err = f.wrapScheme(scheme, pub, raw); err != nil {
 		return
 	}
-	m := f.meta.Rsa
-	var ciphertext []byte
-	ciphertext, err = base64.URLEncoding.DecodeString(m.Ciphertext)
-	if f.key.raw, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, pri, ciphertext, nil); err != nil {
-		return fmt.Errorf("%s: decrypting secret key failed. %v", rsaFortifier, err)
+	m = &MetadataRsa{
+		Timestamp:        time.Now(),
+		Digest:           utils.ComputeDigest(raw),
+		Ciphertext:       scheme.encode(encrypted),
+		CiphertextDigest: utils.ComputeDigest(encrypted),
+		Scheme:           scheme,
 	}
-	actual := utils.ComputeDigest(f.key.raw)
-	if m.Digest != actual {
-		return fmt.Errorf("%s: digest mismatch. expect %q, actual %q", rsaFortifier, m.Digest, actual)
+	if scheme == RsaSchemeKEM {
+		m.KDFInfo, m.KeyWrap = f.kdfInfo, f.keyWrap
+	}
+	f.identify(m, pub)
+	if !f.keyHint {
+		// like the fingerprint, comments and principals would link the file to the recipient
+		info := f.keyInfos[PublicKeyFingerprint(pub)]
+		m.Label, m.Principals = info.Comment, info.Principals
+	}
+	if f.embedPub {
+		if err = m.embedPublicKey(pub); err != nil {
+			return
+		}
+	}
+	if f.rsaRedundant {
+		if encrypted, err = f.wrapScheme(scheme, pub, raw); err != nil {
+			return
+		}
+		m.Redundant = scheme.encode(encrypted)
+		m.RedundantDigest = utils.ComputeDigest(encrypted)
 	}
 	return
 }
 
-func (f *Fortifier) parseRsaPrivateKey() (*rsa.PrivateKey, error) {
-	var k any
-	var err error
-	bytes := f.key.bytes
-	if k, err = ssh.ParseRawPrivateKey(bytes); err != nil {
-		var passphraseMissingError *ssh.PassphraseMissingError
-		if errors.As(err, &passphraseMissingError) {
-			k, err = ssh.ParseRawPrivateKeyWithPassphrase(bytes, enterPassphrase())
+// SetLegacyRsaField enables writing the first RSA recipient into the legacy Rsa
+// field of the metadata, and only the others into Recipients, for the readers
+// predating Recipients.
+func (f *Fortifier) SetLegacyRsaField(b bool) {
+	f.rsaLegacy = b
+}
+
+// SetRedundantWrap enables wrapping the cipher key twice for the RSA recipient,
+// with independent randomness, so that a single faulty wrapping still
+// leaves the file recoverable.
+func (f *Fortifier) SetRedundantWrap(b bool) {
+	f.rsaRedundant = b
+}
+
+// parseRsaRecipient parses the public key of an additional recipient, requiring
+// an RSA key whose certificate, if any, permits key encipherment, and a
+// certificate of a trusted CA key if SetTrustedCAs is set.
+func (f *Fortifier) parseRsaRecipient(kb []byte) (*rsa.PublicKey, error) {
+	if len(f.sshCAs) > 0 {
+		pub, err := f.parseRsaCertificate(kb)
+		if err != nil {
+			return nil, fmt.Errorf("%s: recipient: %w", rsaFortifier, err)
 		}
+		return pub, nil
 	}
+	k, info, err := f.parseCachedPublicKey(kb, f.passphrase)
 	if err != nil {
-		blocks := f.decodePemFile()
-		if len(blocks) == 0 {
-			return nil, err
-		}
-		block := &blocks[0]
-		switch block.Type {
-		case "ENCRYPTED PRIVATE KEY":
-			passphrase := enterPassphrase()
-			var decrypted []byte
-			decrypted, err = pkcs8.DecryptPEMBlock(block, passphrase)
-			if err != nil {
-				return nil, fmt.Errorf("%s: decrypt PKCS #8 private key failed", rsaFortifier)
-			}
-			if k, err = x509.ParsePKCS8PrivateKey(decrypted); err != nil {
-				return nil, err
-			}
+		return nil, fmt.Errorf("%s: recipient: %w", rsaFortifier, err)
+	}
+	pub, err := requireRsaPublicKey(k, info)
+	if err == nil {
+		err = f.checkKeyUsage(info)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: recipient: %w", rsaFortifier, err)
+	}
+	f.noteKeyInfo(pub, info)
+	return pub, nil
+}
+
+// noteKeyInfo records the comment and certificate principals of pub, if any,
+// for wrapRsa to record with the recipient.
+func (f *Fortifier) noteKeyInfo(pub *rsa.PublicKey, info KeyInfo) {
+	if info.Comment == "" && len(info.Principals) == 0 {
+		return
+	}
+	if f.keyInfos == nil {
+		f.keyInfos = make(map[string]KeyInfo)
+	}
+	f.keyInfos[PublicKeyFingerprint(pub)] = info
+}
+
+// parseRsaPublicKey parses the key bytes with ParsePublicKey, requiring an RSA
+// key whose certificate, if any, permits key encipherment.
+func (f *Fortifier) parseRsaPublicKey() (*rsa.PublicKey, error) {
+	if len(f.sshCAs) > 0 {
+		pub, err := f.parseRsaCertificate(f.key.bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rsaFortifier, err)
 		}
+		return pub, nil
 	}
+	k, info, err := f.parseCachedPublicKey(f.key.bytes, PassphraseFunc(f.enterPassphrase))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", rsaFortifier, err)
 	}
-	if key, ok := k.(*rsa.PrivateKey); !ok {
-		return nil, fmt.Errorf("%s: requiring *rsa.PrivateKey, not %v", rsaFortifier, reflect.TypeOf(k))
-	} else {
-		return key, nil
+	pub, err := requireRsaPublicKey(k, info)
+	if err == nil {
+		err = f.checkKeyUsage(info)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rsaFortifier, err)
+	}
+	f.noteKeyInfo(pub, info)
+	return pub, nil
+}
+
+// ErrInvalidRSAKey means an RSA private key is inconsistent, such as a tampered
+// key whose primes do not multiply to its modulus.
+var ErrInvalidRSAKey = errors.New("invalid rsa private key")
+
+// prepareRsaPrivateKey validates the RSA private key and precomputes its CRT
+// values, which keys assembled from their parts, rather than parsed by x509 or
+// ssh, may lack.
+func prepareRsaPrivateKey(pri *rsa.PrivateKey) error {
+	if err := pri.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRSAKey, err)
+	}
+	pri.Precompute()
+	return nil
+}
+
+func (f *Fortifier) setupRsaPrivateKey(pri *rsa.PrivateKey) error {
+	if err := prepareRsaPrivateKey(pri); err != nil {
+		return fmt.Errorf("%s: %w", rsaFortifier, err)
+	}
+	if err := f.policy.checkRsa(pri, f.meta.rsaRecipients()); err != nil {
+		return fmt.Errorf("%s: %w", rsaFortifier, err)
 	}
+	if err := f.checkRecoverPrincipal(pri); err != nil {
+		return fmt.Errorf("%s: %w", rsaFortifier, err)
+	}
+	return f.recoverFromRecipients(PublicKeyFingerprint(&pri.PublicKey), func(m *MetadataRsa, ciphertext []byte) ([]byte, error) {
+		return m.unwrapWith(pri, ciphertext)
+	})
 }
 
-func (f *Fortifier) decodePemFile() (blocks []pem.Block) {
-	kb := f.key.bytes
-	for {
-		var blk *pem.Block
-		blk, kb = pem.Decode(kb)
-		if blk == nil || blk.Type == "" {
-			break
+// recoverFromRecipients recovers the cipher key from the first wrapped key of
+// the RSA recipients which unwrap unwraps, reporting the private key it was
+// recovered with by its fingerprint.
+func (f *Fortifier) recoverFromRecipients(fingerprint string, unwrap func(m *MetadataRsa, ciphertext []byte) ([]byte, error)) error {
+	var errs []error
+	for i, m := range f.meta.rsaRecipients() {
+		if f.rsaRecover != "" && m.Scheme.String() != f.rsaRecover {
+			continue
+		}
+		if err := f.checkFIPS(CipherKeyKindRSA, m.Scheme); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := f.checkKDFInfo(m); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, w := range m.wrappedKeys() {
+			raw, encoding, err := m.unwrap(w, func(ciphertext []byte) ([]byte, error) {
+				if m.Scheme == RsaSchemeAgeSSH {
+					var err error
+					if ciphertext, err = f.meta.ageFile(ciphertext); err != nil {
+						return nil, err
+					}
+				}
+				return unwrap(m, ciphertext)
+			})
+			if err == nil {
+				if f.verbose {
+					fmt.Printf("%s: %s decoded as %s base64\n", rsaFortifier, w.name, encoding)
+				}
+				if m.Digest == "" && f.verbose {
+					fmt.Printf("warning: %s: recipient without digest, skipped the digest check\n", rsaFortifier)
+				}
+				if f.meta.Passphrase != nil {
+					if raw, err = f.combinePassphraseFactor(raw); err != nil {
+						return fmt.Errorf("%s: %w", rsaFortifier, err)
+					}
+				}
+				f.key.raw = raw
+				f.recovered.Recipient, f.recovered.Fingerprint = i, fingerprint
+				f.recovered.Scheme, f.recovered.Redundant = m.Scheme, w.ciphertext != m.Ciphertext
+				if m.Scheme == "" {
+					f.recovered.Scheme = RsaSchemeOAEP
+				}
+				return nil
+			}
+			errs = append(errs, err)
 		}
-		blocks = append(blocks, *blk)
 	}
-	return
+	if len(errs) == 0 {
+		return fmt.Errorf("%s: no recipient wrapped with %s", rsaFortifier, f.rsaRecover)
+	}
+	return fmt.Errorf("%s: %w", rsaFortifier, errors.Join(errs...))
 }
 
-func ParseSSH2PublicKey(keyData string) (ssh.PublicKey, error) {
-	lines := strings.Split(keyData, "\n")
-	var base64Data string
-	inKey := false
-	for _, line := range lines {
-		if line == "---- BEGIN SSH2 PUBLIC KEY ----" {
-			inKey = true
+// migrate moves a populated legacy Rsa field to the front of Recipients, the
+// canonical representation of the RSA recipients.
+func (m *Metadata) migrate() {
+	if m.Rsa != nil {
+		m.Recipients = append([]*MetadataRsa{m.Rsa}, m.Recipients...)
+		m.Rsa = nil
+	}
+}
+
+// rsaRecipients returns the RSA recipients, including the one of the legacy
+// Rsa field of metadata not loaded by ReadHeadIn.
+func (m *Metadata) rsaRecipients() []*MetadataRsa {
+	var recipients []*MetadataRsa
+	if m.Rsa != nil {
+		recipients = append(recipients, m.Rsa)
+	}
+	for _, r := range m.Recipients {
+		if r != nil {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+// mayRecover reports whether the private key of pub may unwrap the cipher key
+// for one of the RSA recipients, those without a fingerprint or a key ID hint
+// included. The OpenSSH fingerprints recorded by earlier versions are matched as
+// well.
+func (m *Metadata) mayRecover(pub crypto.PublicKey) bool {
+	fingerprint, legacy := PublicKeyFingerprint(pub), sshFingerprint(pub)
+	var hint string
+	for _, r := range m.rsaRecipients() {
+		if r.KeyHint != "" {
+			if hint == "" {
+				hint = m.keyHint(pub)
+			}
+			if r.KeyHint == hint {
+				return true
+			}
 			continue
 		}
-		if line == "---- END SSH2 PUBLIC KEY ----" {
-			break
+		if r.Fingerprint == "" || r.Fingerprint == fingerprint || r.Fingerprint == legacy {
+			return true
 		}
-		if inKey && !strings.HasPrefix(line, "Comment:") {
-			base64Data += line
+	}
+	return false
+}
+
+// wrappedKey is a ciphertext of the cipher key with the digest binding it.
+type wrappedKey struct {
+	name       string
+	ciphertext string
+	digest     string
+}
+
+func (m *MetadataRsa) wrappedKeys() []wrappedKey {
+	keys := []wrappedKey{{"ciphertext", m.Ciphertext, m.CiphertextDigest}}
+	if m.Redundant != "" {
+		keys = append(keys, wrappedKey{"redundant ciphertext", m.Redundant, m.RedundantDigest})
+	}
+	return keys
+}
+
+// unwrap recovers the cipher key from the wrapped key with the private key
+// behind unwrap, also returning the name of the base64 encoding the wrapped key
+// was decoded with.
+func (m *MetadataRsa) unwrap(w wrappedKey, unwrap func(ciphertext []byte) ([]byte, error)) (raw []byte, encoding string, err error) {
+	var ciphertext []byte
+	if ciphertext, encoding, err = w.decode(); err != nil {
+		return
+	}
+	if raw, err = unwrap(ciphertext); err != nil {
+		return nil, "", fmt.Errorf("decrypting secret key failed. %v", err)
+	}
+	if m.Digest == "" {
+		// files of the older ciphertext-only format carry no digest; the head
+		// checksum, keyed by raw, still rejects a wrong key
+		return
+	}
+	actual := utils.ComputeDigest(raw)
+	if m.Digest != actual {
+		return nil, "", fmt.Errorf("digest mismatch. expect %q, actual %q", m.Digest, actual)
+	}
+	return
+}
+
+// encodeCiphertext encodes a wrapped key into the metadata, replaced by the
+// tests of SetSelfTest to inject faulty encodings.
+var encodeCiphertext = base64.URLEncoding.EncodeToString
+
+// ciphertextEncodings are the base64 encodings a wrapped key is decoded with in
+// turn: the one this package writes, then those written by other implementations.
+var ciphertextEncodings = []struct {
+	name     string
+	encoding *base64.Encoding
+}{
+	{"url", base64.URLEncoding},
+	{"standard", base64.StdEncoding},
+	{"raw url", base64.RawURLEncoding},
+	{"raw standard", base64.RawStdEncoding},
+}
+
+// decode decodes the wrapped key and checks it against its digest, if any. It
+// also returns the name of the encoding which decoded it: a base64 encoding, or
+// the armor of RsaSchemePGP.
+func (w wrappedKey) decode() ([]byte, string, error) {
+	if isArmoredPGPMessage(w.ciphertext) {
+		ciphertext := []byte(w.ciphertext)
+		if w.digest != "" && w.digest != utils.ComputeDigest(ciphertext) {
+			return nil, "", fmt.Errorf("%w: %s digest mismatch", ErrCorruptedRecipient, w.name)
 		}
+		return ciphertext, "pgp armor", nil
 	}
-	decodedData, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		return nil, fmt.Errorf("base64 decoding error: %v", err)
+	var first error
+	for _, e := range ciphertextEncodings {
+		ciphertext, err := e.encoding.DecodeString(w.ciphertext)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		if w.digest != "" && w.digest != utils.ComputeDigest(ciphertext) {
+			return nil, "", fmt.Errorf("%w: %s digest mismatch", ErrCorruptedRecipient, w.name)
+		}
+		return ciphertext, e.name, nil
 	}
-	return ssh.ParsePublicKey(decodedData)
+	return nil, "", fmt.Errorf("%w: %s: %v", ErrCorruptedRecipient, w.name, first)
 }
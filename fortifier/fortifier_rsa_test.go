@@ -0,0 +1,243 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testSshPublicKey(t testing.TB, k any) ssh.PublicKey {
+	pub, err := ssh.NewPublicKey(k)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return pub
+}
+
+func testSsh2PublicKey(t testing.TB, k any) []byte {
+	encoded := base64.StdEncoding.EncodeToString(testSshPublicKey(t, k).Marshal())
+	var b strings.Builder
+	b.WriteString(ssh2PublicKeyBegin + "\n")
+	b.WriteString("Comment: \"test key\"\n")
+	for len(encoded) > 70 {
+		b.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(ssh2PublicKeyEnd + "\n")
+	return []byte(b.String())
+}
+
+func TestParseRsaPublicKeyCombined(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	key := testRsaPrivateKey(t)
+	combined := append(testSsh2PublicKey(t, &key.PublicKey), pub...)
+	f := NewFortifierWithRsa(false, nil, combined)
+	actual, err := f.parseRsaPublicKey()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !actual.Equal(&key.PublicKey) {
+		t.Fatalf("bad: parsed another key")
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conflicting := append(testSsh2PublicKey(t, &other.PublicKey), pub...)
+	f = NewFortifierWithRsa(false, nil, conflicting)
+	if _, err = f.parseRsaPublicKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestParseRsaPublicKeyNone(t *testing.T) {
+	f := NewFortifierWithRsa(false, nil, []byte("not a key"))
+	if _, err := f.parseRsaPublicKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+// testFlip changes the character of the base64 string s at index i.
+func testFlip(s string, i int) string {
+	b := []byte(s)
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	return string(b)
+}
+
+func TestRsaRedundantWrap(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("redundant payload")
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetRedundantWrap(true)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+
+	for _, bound := range []bool{true, false} {
+		in, layout := testReadLayout(t, path)
+		m := layout.Metadata().Recipients[0]
+		if m.Redundant == "" || m.Redundant == m.Ciphertext {
+			t.Fatalf("expect an independent redundant ciphertext")
+		}
+		m.Ciphertext = testFlip(m.Ciphertext, 10)
+		if !bound {
+			// a faulty wrapping stored intact is only detected by unwrapping
+			m.CiphertextDigest = ""
+		} else if err := layout.Verify(); !errors.Is(err, ErrCorruptedRecipient) {
+			t.Fatalf("expect ErrCorruptedRecipient, got: %v", err)
+		}
+		var out bytes.Buffer
+		err := NewDecrypter(CipherModeAes256CTR, NewFortifierWithRsa(false, layout.Metadata(), pri)).Decrypt(in, &out, layout)
+		_ = in.Close()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), plaintext) {
+			t.Fatalf("bad: %q", out.Bytes())
+		}
+	}
+
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	m := layout.Metadata().Recipients[0]
+	m.Ciphertext, m.Redundant = testFlip(m.Ciphertext, 10), testFlip(m.Redundant, 10)
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); !errors.Is(err, ErrCorruptedRecipient) {
+		t.Fatalf("expect ErrCorruptedRecipient, got: %v", err)
+	}
+}
+
+func TestRsaCiphertextOnlyMetadata(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("archived payload")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	meta := layout.Metadata()
+	meta.Timestamp = time.Time{}
+	meta.Rsa, meta.Recipients = &MetadataRsa{Ciphertext: meta.Recipients[0].Ciphertext}, nil
+	if err := layout.Verify(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	if err := NewDecrypter(CipherModeAes256CTR, NewFortifierWithRsa(true, meta, pri)).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+}
+
+func TestRsaLegacyField(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	other := testOtherRsaPem(t)
+	plaintext := []byte("legacy shaped")
+	for _, legacy := range []bool{true, false} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.AddRecipient(testPublicPem(t, other))
+		f.SetLegacyRsaField(legacy)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		if written := bytes.Contains(layout.metadataRaw, []byte(`"rsa":{`)); written != legacy {
+			t.Fatalf("legacy %v: unexpected metadata %s", legacy, layout.metadataRaw)
+		}
+		meta := layout.Metadata()
+		if meta.Rsa != nil || len(meta.Recipients) != 2 || meta.Recipients[0].Fingerprint != PublicKeyFingerprint(&testRsaPrivateKey(t).PublicKey) {
+			t.Fatalf("legacy %v: expect the recipients migrated in order", legacy)
+		}
+		if err := layout.Verify(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for _, key := range [][]byte{pri, other} {
+			if actual, err := testDecryptRsaFile(t, path, key); err != nil || !bytes.Equal(actual, plaintext) {
+				t.Fatalf("legacy %v bad: %q, err: %v", legacy, actual, err)
+			}
+		}
+	}
+}
+
+func TestRsaCiphertextEncodings(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	for _, e := range ciphertextEncodings {
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		m := layout.Metadata().Recipients[0]
+		ciphertext, err := base64.URLEncoding.DecodeString(m.Ciphertext)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		m.Ciphertext = e.encoding.EncodeToString(ciphertext)
+		w := m.wrappedKeys()[0]
+		decoded, name, err := w.decode()
+		if err != nil || !bytes.Equal(decoded, ciphertext) {
+			t.Fatalf("%s: err: %v", e.name, err)
+		}
+		// without these characters the url and standard alphabets coincide
+		if name != e.name && strings.ContainsAny(m.Ciphertext, "+/-_") {
+			t.Fatalf("%s: decoded as %q", e.name, name)
+		}
+		if err = NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err != nil {
+			t.Fatalf("%s: err: %v", e.name, err)
+		}
+	}
+}
+
+func TestEmptyKey(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	for name, kb := range map[string][]byte{"nil": nil, "empty": {}} {
+		for _, meta := range []*Metadata{nil, {}, layout.Metadata(), {Recipients: []*MetadataRsa{nil}}} {
+			f := NewFortifierWithRsa(false, meta, kb)
+			if err := f.SetupKey(); !errors.Is(err, ErrEmptyKey) {
+				t.Fatalf("%s: expect ErrEmptyKey, got %v", name, err)
+			}
+		}
+	}
+}
+
+func TestRsaPrivateKeyPrecompute(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	plaintext := []byte("assembled from parts")
+	encrypted := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	decrypt := func(pri *rsa.PrivateKey) ([]byte, error) {
+		return testDecryptMnemonicFile(t, encrypted, func(meta *Metadata) *Fortifier {
+			f := NewFortifierWithRsa(false, meta, nil)
+			f.privateKey = pri
+			return f
+		})
+	}
+	key := testRsaPrivateKey(t)
+	assembled := &rsa.PrivateKey{PublicKey: key.PublicKey, D: key.D, Primes: key.Primes}
+	actual, err := decrypt(assembled)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	if assembled.Precomputed.Dp == nil {
+		t.Fatalf("expect precomputed values")
+	}
+
+	tampered := &rsa.PrivateKey{PublicKey: key.PublicKey, D: new(big.Int).Add(key.D, big.NewInt(2)), Primes: key.Primes}
+	if _, err = decrypt(tampered); !errors.Is(err, ErrInvalidRSAKey) {
+		t.Fatalf("expect an invalid key error, got %v", err)
+	}
+	inconsistent := &rsa.PrivateKey{PublicKey: key.PublicKey, D: key.D,
+		Primes: []*big.Int{new(big.Int).Add(key.Primes[0], big.NewInt(2)), key.Primes[1]}}
+	if _, err = decrypt(inconsistent); !errors.Is(err, ErrInvalidRSAKey) {
+		t.Fatalf("expect an invalid key error, got %v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package fortifier
 
 import (
-	"crypto/rand"
 	"time"
 
 	"github.com/i3ash/fortify/sss"
@@ -41,23 +40,29 @@ func (f *Fortifier) setupSssKey() (err error) {
 		if f.key.raw, err = sss.Combine(f.key.parts); err != nil {
 			return
 		}
+		if err = f.checkSssSelfTest(f.key.parts); err != nil {
+			return
+		}
 	} else {
-		f.key.raw = make([]byte, 32)
-		raw := f.key.raw
-		meta := f.meta
-		if _, err = rand.Read(raw); err != nil {
+		var raw []byte
+		if raw, err = f.newRaw(); err != nil {
 			return
 		}
+		f.key.raw = raw
+		meta := f.meta
 		var ps []sss.Part
 		if ps, err = sss.Split(raw, meta.Sss.Parts, meta.Sss.Threshold); err != nil {
 			return
 		}
+		meta.Sss.Digest = ps[0].Digest
+		meta.Sss.Timestamp = ps[0].Timestamp
+		if err = f.checkSssSelfTest(ps); err != nil {
+			return
+		}
 		if err = sss.AppendParts(ps, 0, 1, "fortified.key", f.truncate); err != nil {
 			return
 		}
 		defer sss.CloseAllFilesForWrite()
-		meta.Sss.Digest = ps[0].Digest
-		meta.Sss.Timestamp = ps[0].Timestamp
 	}
 	return
 }
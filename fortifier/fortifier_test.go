@@ -0,0 +1,349 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i3ash/fortify/sss"
+)
+
+var testRsaKeyOnce sync.Once
+var testRsaKey *rsa.PrivateKey
+
+func testRsaPrivateKey(t testing.TB) *rsa.PrivateKey {
+	testRsaKeyOnce.Do(func() {
+		var err error
+		if testRsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	})
+	return testRsaKey
+}
+
+// testPublicPem returns the PEM public key of the PEM PKCS #8 private key.
+func testPublicPem(t testing.TB, pri []byte) []byte {
+	block, _ := pem.Decode(pri)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.(*rsa.PrivateKey).Public())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func testRsaPem(t testing.TB) (pub, pri []byte) {
+	key := testRsaPrivateKey(t)
+	pubDer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	priDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pub = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer})
+	pri = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priDer})
+	return
+}
+
+func testWriteFile(t testing.TB, dir, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return path
+}
+
+// testEncryptFile encrypts plaintext with f into a file under dir and returns its path.
+func testEncryptFile(t testing.TB, f *Fortifier, mode CipherModeName, dir string, plaintext []byte) string {
+	in, err := os.Open(testWriteFile(t, dir, "plain.data", plaintext))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(filepath.Join(dir, "fortified.data"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	if err = NewEncrypter(mode, f).EncryptFile(in, out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return out.Name()
+}
+
+func testReadLayout(t testing.TB, path string) (*os.File, *FileLayout) {
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(in); err != nil {
+		_ = in.Close()
+		t.Fatalf("err: %v", err)
+	}
+	return in, layout
+}
+
+// testDecryptRsaFile decrypts the fortified file at path with the private key pri.
+func testDecryptRsaFile(t testing.TB, path string, pri []byte) ([]byte, error) {
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	if err := f.OpenMetadata(layout); err != nil {
+		return nil, err
+	}
+	dec := NewDecrypter(layout.Metadata().Mode, f)
+	if dec == nil {
+		t.Fatalf("unknown cipher mode name: %s", layout.Metadata().Mode)
+	}
+	var out bytes.Buffer
+	if err := dec.Decrypt(in, &out, layout); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func testSssParts(t testing.TB) []sss.Part {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	parts, err := sss.Split(raw, 3, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return parts
+}
+
+// testPayload returns the IV and ciphertext following the head of a fortified file.
+func testPayload(t testing.TB, path string) []byte {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	return content[len(content)-int(layout.DataLength())-16:]
+}
+
+func TestRsaRoundTrip(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("the quick brown fox jumps over the lazy dog")
+	for _, mode := range []CipherModeName{CipherModeAes256CTR, CipherModeAes256OFB, CipherModeAes256CFB} {
+		dir := t.TempDir()
+		path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), mode, dir, plaintext)
+		actual, err := testDecryptRsaFile(t, path, pri)
+		if err != nil {
+			t.Fatalf("%s err: %v", mode, err)
+		}
+		if !bytes.Equal(actual, plaintext) {
+			t.Fatalf("%s bad: %q", mode, actual)
+		}
+	}
+}
+
+func TestDecryptToPipe(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("piped payload")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	done := make(chan error, 1)
+	go func() {
+		defer func() { _ = w.Close() }()
+		done <- NewDecrypter(layout.Metadata().Mode, NewFortifierWithRsa(false, layout.Metadata(), pri)).DecryptFile(in, w, layout)
+	}()
+	actual, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestSealMetadata(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("sealed metadata")
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetSealMetadata(true)
+	path := testEncryptFile(t, f, CipherModeAes256OFB, t.TempDir(), plaintext)
+
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	raw := string(layout.metadataRaw)
+	if strings.Contains(raw, string(CipherModeAes256OFB)) {
+		t.Fatalf("metadata is readable: %s", raw)
+	}
+	meta := layout.Metadata()
+	if !meta.Timestamp.IsZero() || !meta.Recipients[0].Timestamp.IsZero() {
+		t.Fatalf("metadata is readable: %s", raw)
+	}
+	if meta.Sealed == "" || meta.Rsa != nil || len(meta.Recipients) != 1 || meta.Recipients[0].Ciphertext == "" {
+		t.Fatalf("bad locator metadata: %s", raw)
+	}
+
+	actual, err := testDecryptRsaFile(t, path, pri)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestDeterministicIv(t *testing.T) {
+	parts := testSssParts(t)
+	plaintext := []byte("deterministic payload")
+	encrypt := func(deterministic bool) []byte {
+		f := NewFortifierWithSss(false, false, parts)
+		f.SetDeterministicIv(deterministic)
+		return testPayload(t, testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext))
+	}
+	if !bytes.Equal(encrypt(true), encrypt(true)) {
+		t.Fatalf("expect identical payloads")
+	}
+	if bytes.Equal(encrypt(false), encrypt(false)) {
+		t.Fatalf("expect different payloads")
+	}
+
+	f := NewFortifierWithSss(false, false, parts)
+	f.SetDeterministicIv(true)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	if !layout.Metadata().Deterministic {
+		t.Fatalf("expect deterministic flag in metadata")
+	}
+	var out bytes.Buffer
+	dec := NewDecrypter(CipherModeAes256CTR, NewFortifierWithSss(false, false, parts))
+	if err := dec.Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+}
+
+func TestDeterministicIvRequiresCtr(t *testing.T) {
+	f := NewFortifierWithSss(false, false, testSssParts(t))
+	f.SetDeterministicIv(true)
+	in, err := os.Open(testWriteFile(t, t.TempDir(), "plain.data", []byte("data")))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(filepath.Join(t.TempDir(), "fortified.data"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	if err = NewEncrypter(CipherModeAes256OFB, f).EncryptFile(in, out); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("payload integrity"))
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"flipped":   func(b []byte) []byte { b[len(b)-1] ^= 1; return b },
+		"truncated": func(b []byte) []byte { return b[:len(b)-1] },
+		"no iv":     func(b []byte) []byte { return b[:len(b)-len("payload integrity")-8] },
+	} {
+		testWriteFile(t, filepath.Dir(path), filepath.Base(path), corrupt(bytes.Clone(content)))
+		_, err = testDecryptRsaFile(t, path, pri)
+		if !errors.Is(err, ErrPayloadAuthFailed) || errors.Is(err, ErrDecryptFailed) {
+			t.Fatalf("%s: expect ErrPayloadAuthFailed, got %v", name, err)
+		}
+	}
+	testWriteFile(t, filepath.Dir(path), filepath.Base(path), content)
+	if _, err = testDecryptRsaFile(t, path, testOtherRsaPem(t)); !errors.Is(err, ErrDecryptFailed) || errors.Is(err, ErrPayloadAuthFailed) {
+		t.Fatalf("expect ErrDecryptFailed, got %v", err)
+	}
+}
+
+func TestAuthenticateMetadata(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("dated"))
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		if err := NewFortifierWithRsa(false, layout.Metadata(), pri).AuthenticateMetadata(layout); err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+		if layout.Metadata().Timestamp.IsZero() {
+			t.Fatalf("seal %v: expect the timestamp", seal)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		i := bytes.Index(content, []byte(`"timestamp":"`)) + len(`"timestamp":"`) + 3
+		content[i] ^= 1 // another year
+		testWriteFile(t, filepath.Dir(path), filepath.Base(path), content)
+		in, layout = testReadLayout(t, path)
+		_ = in.Close()
+		err = NewFortifierWithRsa(false, layout.Metadata(), pri).AuthenticateMetadata(layout)
+		if !errors.Is(err, ErrDecryptFailed) {
+			t.Fatalf("seal %v: expect ErrDecryptFailed, got %v", seal, err)
+		}
+	}
+}
+
+func TestNoRecipients(t *testing.T) {
+	f := NewFortifierWithRsa(false, nil, nil)
+	if err := f.SetupKey(); !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("expect ErrNoRecipients, got %v", err)
+	}
+	if len(f.key.raw) != 0 || f.meta.Timestamp != (time.Time{}) {
+		t.Fatalf("expect no key setup")
+	}
+
+	pub, pri := testRsaPem(t)
+	f.AddRecipient(pub)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	for _, f = range []*Fortifier{
+		NewFortifierWithRsa(false, layout.Metadata(), nil),
+		NewFortifierWithRsaKeyring(false, layout.Metadata(), nil),
+	} {
+		if err := f.SetupKey(); !errors.Is(err, ErrNoRecipients) {
+			t.Fatalf("expect ErrNoRecipients, got %v", err)
+		}
+	}
+	if actual, err := testDecryptRsaFile(t, path, pri); err != nil || string(actual) != "data" {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+}
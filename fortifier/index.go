@@ -0,0 +1,169 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+const (
+	indexLabel   = "fortify index"
+	maxIndexSize = 4096
+)
+
+// RecipientRoleOperator is the role of the recipients of the index key, who
+// read the index of SetIndex but not the payload.
+const RecipientRoleOperator = "operator"
+
+// ErrNotOperator means no private key of an operator of the index was given.
+var ErrNotOperator = errors.New("not an operator of the index")
+
+// MetadataIndex is the searchable index of the file, sealed under an index key
+// of its own, which is wrapped for the operators rather than the recipients of
+// the payload.
+type MetadataIndex struct {
+	// Sealed is the nonce and AES-256-GCM ciphertext of the index fields in JSON
+	Sealed string `json:"sealed"`
+	// Operators are the RSA recipients of the index key, of role RecipientRoleOperator
+	Operators []*MetadataRsa `json:"operators"`
+}
+
+// SetIndex sets index fields, such as tenant or customer ID, which operators
+// of multi-tenant storage search file heads by without reading the payloads.
+// The fields are sealed under an index key wrapped for the RSA public key bytes
+// of each operator only: the operators do not recover the cipher key, and the
+// recipients of the payload do not read the index unless they are operators
+// too. Like the tags, the index totals at most 4096 bytes. It stays readable by
+// the operators when the metadata is sealed, see OpenIndex. Empty fields
+// disable the index.
+func (f *Fortifier) SetIndex(fields map[string]string, operators ...[]byte) error {
+	if len(fields) == 0 {
+		f.index, f.operators = nil, nil
+		return nil
+	}
+	if len(operators) == 0 {
+		return errors.New("index requires an operator")
+	}
+	size := 0
+	for k, v := range fields {
+		if k == "" {
+			return errors.New("empty index field name")
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxIndexSize {
+		return fmt.Errorf("%w: index of more than %d bytes", ErrLabelsTooLarge, maxIndexSize)
+	}
+	f.index, f.operators = maps.Clone(fields), operators
+	return nil
+}
+
+// sealIndex seals the index fields under a new index key, wrapped for the operators.
+func (f *Fortifier) sealIndex() (*MetadataIndex, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	plain, err := json.Marshal(f.index)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newIndexAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	index := &MetadataIndex{Sealed: base64.URLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, []byte(indexLabel)))}
+	for _, kb := range f.operators {
+		pub, err := f.parseRsaRecipient(kb)
+		if err != nil {
+			return nil, fmt.Errorf("operator: %w", err)
+		}
+		m, err := f.wrapRsa(pub, key, "")
+		if err != nil {
+			return nil, err
+		}
+		m.Role = RecipientRoleOperator
+		index.Operators = append(index.Operators, m)
+	}
+	return index, nil
+}
+
+// OpenIndex reads the index fields of the metadata with the private key bytes
+// of an operator, such as those the Fortifier was made with by
+// NewFortifierWithRsa, without recovering the cipher key of the payload. It
+// returns nil if the metadata has no index.
+func (f *Fortifier) OpenIndex(meta *Metadata) (map[string]string, error) {
+	if meta.Index == nil {
+		return nil, nil
+	}
+	k := f.privateKey
+	if k == nil {
+		var err error
+		if k, err = f.parsePrivateKey(); err != nil {
+			return nil, err
+		}
+	}
+	pri, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s private key", ErrNotOperator, privateKeyAlgorithm(k))
+	}
+	if err := prepareRsaPrivateKey(pri); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, m := range meta.Index.Operators {
+		for _, w := range m.wrappedKeys() {
+			key, _, err := m.unwrap(w, func(ciphertext []byte) ([]byte, error) { return m.unwrapWith(pri, ciphertext) })
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			fields, err := openIndex(meta.Index, key)
+			clear(key)
+			return fields, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrNotOperator, errors.Join(errs...))
+}
+
+func openIndex(index *MetadataIndex, key []byte) (map[string]string, error) {
+	sealed, err := base64.URLEncoding.DecodeString(index.Sealed)
+	if err != nil {
+		return nil, fmt.Errorf("sealed index: %w", err)
+	}
+	aead, err := newIndexAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed index too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(indexLabel))
+	if err != nil {
+		return nil, fmt.Errorf("open sealed index: %w", err)
+	}
+	var fields map[string]string
+	if err = json.Unmarshal(plain, &fields); err != nil {
+		return nil, fmt.Errorf("sealed index: %w", err)
+	}
+	return fields, nil
+}
+
+func newIndexAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
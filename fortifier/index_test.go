@@ -0,0 +1,68 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	operator := testOtherRsaPem(t)
+	fields := map[string]string{"tenant": "acme", "customer": "42"}
+	plaintext := []byte("tenant payload")
+	for _, sealed := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		if err := f.SetIndex(fields, testPublicPem(t, operator)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		f.SetSealMetadata(sealed)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		meta := layout.Metadata()
+		if meta.Index == nil || len(meta.Index.Operators) != 1 || meta.Index.Operators[0].Role != RecipientRoleOperator {
+			t.Fatalf("unexpected index: %+v", meta.Index)
+		}
+		if strings.Contains(string(layout.metadataRaw), "acme") {
+			t.Fatalf("index is readable: %s", layout.metadataRaw)
+		}
+
+		// the operator reads the index from the head, but not the payload
+		actual, err := NewFortifierWithRsa(false, meta, operator).OpenIndex(meta)
+		if err != nil || !maps.Equal(actual, fields) {
+			t.Fatalf("bad: %v, err: %v", actual, err)
+		}
+		if _, err = testDecryptRsaFile(t, path, operator); err == nil {
+			t.Fatalf("expect error")
+		}
+		// and the recipient the payload, but not the index
+		if _, err = NewFortifierWithRsa(false, meta, pri).OpenIndex(meta); !errors.Is(err, ErrNotOperator) {
+			t.Fatalf("expect ErrNotOperator, got %v", err)
+		}
+		decrypted, err := testDecryptRsaFile(t, path, pri)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("bad: %q, err: %v", decrypted, err)
+		}
+	}
+}
+
+func TestIndexInvalid(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetIndex(map[string]string{"tenant": "acme"}); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := f.SetIndex(map[string]string{"": "acme"}, pub); err == nil {
+		t.Fatalf("expect error")
+	}
+	large := map[string]string{"tenant": strings.Repeat("a", maxIndexSize)}
+	if err := f.SetIndex(large, pub); !errors.Is(err, ErrLabelsTooLarge) {
+		t.Fatalf("expect ErrLabelsTooLarge, got %v", err)
+	}
+	if index, err := f.OpenIndex(&Metadata{}); index != nil || err != nil {
+		t.Fatalf("bad: %v, err: %v", index, err)
+	}
+}
@@ -0,0 +1,122 @@
+package fortifier
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// FileInfo describes a fortified file as far as its head tells without any
+// key, see Inspect.
+type FileInfo struct {
+	// Version is the version of the layout, and CompressedMetadata reports
+	// the metadata is stored gzip compressed
+	Version            string         `json:"version"`
+	CompressedMetadata bool           `json:"compressed_metadata,omitempty"`
+	Suite              string         `json:"suite,omitempty"`
+	Key                CipherKeyKind  `json:"key"`
+	Mode               CipherModeName `json:"mode,omitempty"`
+	Timestamp          time.Time      `json:"timestamp"`
+	HeadSize           int64          `json:"head_size"`
+	PayloadSize        uint64         `json:"payload_size"`
+	// Sealed reports the metadata is sealed under the cipher key, which hides
+	// the mode and timestamps, see SetSealMetadata
+	Sealed        bool              `json:"sealed,omitempty"`
+	Deterministic bool              `json:"deterministic,omitempty"`
+	Nested        bool              `json:"nested,omitempty"`
+	Producer      string            `json:"producer,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	Recipients    []RecipientInfo   `json:"recipients,omitempty"`
+}
+
+// RecipientInfo describes one way of recovering the cipher key, or the index
+// key for the operators.
+type RecipientInfo struct {
+	// Kind is the RSA scheme of an RSA recipient, or the cipher key kind otherwise
+	Kind string `json:"kind"`
+	// Fingerprint identifies the public key, prefixed with "hint:" for a key ID hint
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Label is the role, label or group of an RSA recipient, or what else identifies the recipient
+	Label     string    `json:"label,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Principals are those of the SSH certificate an RSA recipient was given as
+	Principals []string `json:"principals,omitempty"`
+}
+
+// Inspect reads the head of the fortified file, binary or armored, from r and
+// describes it. It requires no key and does not read the payload.
+func Inspect(r io.Reader) (*FileInfo, error) {
+	layout, _, err := ReadContainer(r)
+	if err != nil {
+		return nil, err
+	}
+	return inspectLayout(layout), nil
+}
+
+func inspectLayout(layout *FileLayout) *FileInfo {
+	meta := layout.Metadata()
+	info := &FileInfo{
+		Version:            string(layout.Version()),
+		CompressedMetadata: layout.Version() == layoutVersionCompressed,
+		Suite:              meta.Suite,
+		Key:                meta.Key,
+		Mode:               meta.Mode,
+		Timestamp:          meta.Timestamp,
+		HeadSize:           layout.HeadLength(),
+		PayloadSize:        layout.DataLength(),
+		Sealed:             meta.Sealed != "",
+		Deterministic:      meta.Deterministic,
+		Nested:             meta.Nested,
+		Producer:           meta.Producer,
+		Description:        meta.Description,
+		Tags:               meta.Tags,
+	}
+	for _, m := range meta.rsaRecipients() {
+		info.Recipients = append(info.Recipients, inspectRsa(m))
+	}
+	if j := meta.Joint; j != nil {
+		for _, s := range j.Shares {
+			r := inspectRsa(&s.MetadataRsa)
+			r.Label = s.Name
+			info.Recipients = append(info.Recipients, r)
+		}
+	}
+	if m := meta.Sss; m != nil {
+		info.Recipients = append(info.Recipients, RecipientInfo{Kind: CipherKeyKindSSS.String(),
+			Label: fmt.Sprintf("%d of %d parts", m.Threshold, m.Parts), Timestamp: m.Timestamp})
+	}
+	if m := meta.Mnemonic; m != nil {
+		info.Recipients = append(info.Recipients, RecipientInfo{Kind: CipherKeyKindMnemonic.String(),
+			Label: m.Path, Timestamp: m.Timestamp})
+	}
+	if m := meta.Dpapi; m != nil {
+		info.Recipients = append(info.Recipients, RecipientInfo{Kind: CipherKeyKindDPAPI.String(),
+			Label: string(m.Scope), Timestamp: m.Timestamp})
+	}
+	if m := meta.Oidc; m != nil {
+		info.Recipients = append(info.Recipients, RecipientInfo{Kind: CipherKeyKindOIDC.String(),
+			Fingerprint: m.Fingerprint, Label: m.Issuer + " " + m.Subject, Timestamp: m.Timestamp})
+	}
+	if meta.Index != nil {
+		for _, m := range meta.Index.Operators {
+			info.Recipients = append(info.Recipients, inspectRsa(m))
+		}
+	}
+	return info
+}
+
+func inspectRsa(m *MetadataRsa) RecipientInfo {
+	r := RecipientInfo{Kind: m.Scheme.String(), Fingerprint: m.Fingerprint, Label: m.Group, Timestamp: m.Timestamp,
+		Principals: m.Principals}
+	if m.KeyHint != "" {
+		r.Fingerprint = "hint:" + m.KeyHint
+	}
+	if m.Label != "" {
+		r.Label = m.Label
+	}
+	if m.Role != "" {
+		r.Label = m.Role
+	}
+	return r
+}
@@ -0,0 +1,48 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// The sample in testdata/inspect was fortified for testdata/oaep/public.pem,
+// and another key as a member of the group @ops, with a description and tags.
+func TestInspect(t *testing.T) {
+	in, err := os.Open("testdata/inspect/sample.fortified")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = in.Close() }()
+	info, err := Inspect(in)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	actual, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	actual = append(actual, '\n')
+	const golden = "testdata/inspect/sample.golden.json"
+	if *updateGolden {
+		if err = os.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("bad:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	if _, err = Inspect(bytes.NewReader([]byte("plain text, not fortified"))); !errors.Is(err, ErrNotFortified) {
+		t.Fatalf("expect not fortified, got %v", err)
+	}
+}
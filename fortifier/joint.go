@@ -0,0 +1,218 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JointSchemeXor2of2 splits the cipher key into a random share and its XOR with
+// the cipher key, so that each share alone tells nothing of the cipher key.
+const JointSchemeXor2of2 = "xor-2-of-2"
+
+// ErrJointRecovery means the private keys given do not unwrap the shares of
+// all the parties of a joint recovery.
+var ErrJointRecovery = errors.New("joint recovery requires both parties")
+
+// JointRecipient is a named party of a joint recovery, see SetJointRecovery.
+type JointRecipient struct {
+	Name      string
+	PublicKey []byte
+}
+
+// MetadataJoint records the shares of the cipher key split by SetJointRecovery.
+type MetadataJoint struct {
+	Scheme string                `json:"scheme"`
+	Shares []*MetadataJointShare `json:"shares"`
+}
+
+// MetadataJointShare is a share of the cipher key wrapped for the RSA public
+// key of the named party.
+type MetadataJointShare struct {
+	Name string `json:"name"`
+	MetadataRsa
+}
+
+// SetJointRecovery requires two parties to recover the cipher key together: it
+// is split into two shares, each wrapped for the RSA public key bytes of one
+// party only, and recovery needs the private keys of both, such as through a
+// keyring. The parties must differ by name and key, and replace any other
+// recipient of the cipher key.
+func (f *Fortifier) SetJointRecovery(a, b JointRecipient) error {
+	if a.Name == "" || b.Name == "" {
+		return errors.New("joint recipient without name")
+	}
+	if a.Name == b.Name {
+		return fmt.Errorf("joint recipients of the same name %q", a.Name)
+	}
+	if len(a.PublicKey) == 0 || len(b.PublicKey) == 0 {
+		return fmt.Errorf("joint recipient: %w", ErrEmptyKey)
+	}
+	if bytes.Equal(a.PublicKey, b.PublicKey) {
+		return errors.New("joint recipients of the same public key")
+	}
+	f.joint = []JointRecipient{a, b}
+	return nil
+}
+
+// setupJointPublicKeys splits a new cipher key and wraps a share for each party.
+func (f *Fortifier) setupJointPublicKeys() error {
+	if len(f.key.bytes) > 0 || len(f.recipients) > 0 || f.toSelf || len(f.ageRecipients) > 0 || f.passFactor {
+		return fmt.Errorf("%s: joint recovery excludes other recipients", rsaFortifier)
+	}
+	pubs := make([]*rsa.PublicKey, len(f.joint))
+	for i, r := range f.joint {
+		pub, err := f.parseRsaRecipient(r.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%s: joint recipient %s: %w", rsaFortifier, r.Name, err)
+		}
+		if i > 0 && pub.Equal(pubs[0]) {
+			return fmt.Errorf("%s: joint recipients of the same public key", rsaFortifier)
+		}
+		pubs[i] = pub
+	}
+	if f.keyHint {
+		if err := f.newKeyHintSalt(); err != nil {
+			return err
+		}
+	}
+	raw, err := f.newRaw()
+	if err != nil {
+		return err
+	}
+	// the first share is random as the cipher key, from the same source
+	first := make([]byte, len(raw))
+	if _, err = io.ReadFull(f.randomReader(), first); err != nil {
+		return err
+	}
+	defer clear(first)
+	second := make([]byte, len(raw))
+	defer clear(second)
+	subtle.XORBytes(second, raw, first)
+	joint := &MetadataJoint{Scheme: JointSchemeXor2of2}
+	for i, share := range [][]byte{first, second} {
+		m, err := f.wrapRsa(pubs[i], share, f.rsaScheme)
+		if err != nil {
+			return err
+		}
+		joint.Shares = append(joint.Shares, &MetadataJointShare{Name: f.joint[i].Name, MetadataRsa: *m})
+	}
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindRSA
+	f.meta.Timestamp = time.Now()
+	f.meta.Rsa, f.meta.Recipients, f.meta.Joint = nil, nil, joint
+	return f.checkSelfTest()
+}
+
+// setupJointPrivateKeys unwraps every share with one of the private keys given
+// and combines the shares into the cipher key.
+func (f *Fortifier) setupJointPrivateKeys() error {
+	joint := f.meta.Joint
+	if joint.Scheme != JointSchemeXor2of2 {
+		return fmt.Errorf("%s: unsupported joint scheme %q", rsaFortifier, joint.Scheme)
+	}
+	if len(joint.Shares) != 2 {
+		return fmt.Errorf("%s: %w: %d joint shares", rsaFortifier, ErrCorruptedRecipient, len(joint.Shares))
+	}
+	keys, err := f.jointPrivateKeys()
+	if err != nil {
+		return err
+	}
+	var raw []byte
+	for _, s := range joint.Shares {
+		share, err := f.unwrapJointShare(s, keys)
+		if err != nil {
+			clear(raw)
+			return fmt.Errorf("%s: %w: share of %s: %w", rsaFortifier, ErrJointRecovery, s.Name, err)
+		}
+		if raw == nil {
+			raw = share
+			continue
+		}
+		if len(share) != len(raw) {
+			clear(raw)
+			clear(share)
+			return fmt.Errorf("%s: %w: joint shares of different sizes", rsaFortifier, ErrCorruptedRecipient)
+		}
+		subtle.XORBytes(raw, raw, share)
+		clear(share)
+	}
+	f.key.raw = raw
+	return nil
+}
+
+// jointPrivateKeys returns the RSA private keys of the keyring, or the one of
+// LoadPrivateKey or the key bytes, checked against the policy and the recover
+// principal like the private key of a single recipient.
+func (f *Fortifier) jointPrivateKeys() ([]*rsa.PrivateKey, error) {
+	var ks []crypto.PrivateKey
+	if f.privateKey != nil {
+		ks = append(ks, f.privateKey)
+	}
+	candidates := f.keyring
+	if len(candidates) == 0 && len(f.key.bytes) > 0 {
+		candidates = [][]byte{f.key.bytes}
+	}
+	for i, kb := range candidates {
+		f.key.bytes = kb
+		k, err := f.parsePrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+		ks = append(ks, k)
+	}
+	if len(f.keyring) > 0 {
+		f.key.bytes = nil
+	}
+	shares := make([]*MetadataRsa, len(f.meta.Joint.Shares))
+	for i, s := range f.meta.Joint.Shares {
+		shares[i] = &s.MetadataRsa
+	}
+	var keys []*rsa.PrivateKey
+	for _, k := range ks {
+		pri, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: joint recovery requires rsa private keys, not %s", rsaFortifier, privateKeyAlgorithm(k))
+		}
+		if err := prepareRsaPrivateKey(pri); err != nil {
+			return nil, fmt.Errorf("%s: %w", rsaFortifier, err)
+		}
+		if err := f.policy.checkRsa(pri, shares); err != nil {
+			return nil, fmt.Errorf("%s: %w", rsaFortifier, err)
+		}
+		if err := f.checkRecoverPrincipal(pri); err != nil {
+			return nil, fmt.Errorf("%s: %w", rsaFortifier, err)
+		}
+		keys = append(keys, pri)
+	}
+	return keys, nil
+}
+
+func (f *Fortifier) unwrapJointShare(s *MetadataJointShare, keys []*rsa.PrivateKey) ([]byte, error) {
+	m := &s.MetadataRsa
+	if err := f.checkFIPS(CipherKeyKindRSA, m.Scheme); err != nil {
+		return nil, err
+	}
+	if err := f.checkKDFInfo(m); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, pri := range keys {
+		for _, w := range m.wrappedKeys() {
+			share, _, err := m.unwrap(w, func(ciphertext []byte) ([]byte, error) { return m.unwrapWith(pri, ciphertext) })
+			if err == nil {
+				return share, nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no private key of the party")
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,170 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestJointRecovery(t *testing.T) {
+	alicePub, alice := testRsaPem(t)
+	bob := testOtherRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, nil)
+	if err := f.SetJointRecovery(JointRecipient{Name: "alice", PublicKey: alicePub},
+		JointRecipient{Name: "bob", PublicKey: testPublicPem(t, bob)}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.SetSealMetadata(true)
+	plaintext := []byte("joint payload")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	joint := layout.Metadata().Joint
+	if joint == nil || joint.Scheme != JointSchemeXor2of2 || len(joint.Shares) != 2 {
+		t.Fatalf("bad: %+v", joint)
+	}
+	if joint.Shares[0].Name != "alice" || joint.Shares[1].Name != "bob" || len(layout.Metadata().Recipients) != 0 {
+		t.Fatalf("bad: %+v", layout.Metadata())
+	}
+
+	for name, keys := range map[string][][]byte{"alice": {alice}, "bob": {bob}, "other": {alice, testOtherRsaPem(t)}} {
+		t.Run(name, func(t *testing.T) {
+			in, layout := testReadLayout(t, path)
+			defer func() { _ = in.Close() }()
+			err := NewFortifierWithRsaKeyring(false, layout.Metadata(), keys).OpenMetadata(layout)
+			if !errors.Is(err, ErrJointRecovery) {
+				t.Fatalf("expect ErrJointRecovery, got %v", err)
+			}
+		})
+	}
+
+	in, layout = testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f = NewFortifierWithRsaKeyring(false, layout.Metadata(), [][]byte{bob, alice})
+	if err := f.OpenMetadata(layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	if err := NewDecrypter(layout.Metadata().Mode, f).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(plaintext, out.Bytes()) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+}
+
+func TestJointRecoveryChecks(t *testing.T) {
+	alicePub, alice := testRsaPem(t)
+	bob := testOtherRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, nil)
+	if err := f.SetJointRecovery(JointRecipient{Name: "alice", PublicKey: alicePub},
+		JointRecipient{Name: "bob", PublicKey: testPublicPem(t, bob)}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("joint payload"))
+	recover := func(setup func(f *Fortifier)) error {
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		f := NewFortifierWithRsaKeyring(false, layout.Metadata(), [][]byte{alice, bob})
+		setup(f)
+		return f.SetupKey()
+	}
+
+	// bob's key has 1024 bits
+	if err := recover(func(f *Fortifier) { f.SetPolicy(StrictPolicy) }); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("expect ErrPolicyViolation, got %v", err)
+	}
+
+	block, _ := pem.Decode(bob)
+	bobKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ca := testSshSigner(t)
+	validBefore := time.Now().Add(time.Hour)
+	aliceCert := ssh.MarshalAuthorizedKey(testSshCertificate(t, ca, validBefore, "ops"))
+	bobCert := ssh.MarshalAuthorizedKey(testSshCertificateOf(t, ca, &bobKey.(*rsa.PrivateKey).PublicKey, validBefore, "ops"))
+	if err = recover(func(f *Fortifier) {
+		f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "")
+		f.SetRecoverPrincipal("ops", aliceCert)
+	}); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got %v", err)
+	}
+	if err = recover(func(f *Fortifier) {
+		f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "")
+		f.SetRecoverPrincipal("ops", aliceCert, bobCert)
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestSetJointRecovery(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	other := testPublicPem(t, testOtherRsaPem(t))
+	f := NewFortifierWithRsa(false, nil, nil)
+	for _, c := range [][2]JointRecipient{
+		{{Name: "alice", PublicKey: pub}, {Name: "alice", PublicKey: other}},
+		{{Name: "alice", PublicKey: pub}, {Name: "bob", PublicKey: pub}},
+		{{Name: "alice", PublicKey: pub}, {PublicKey: other}},
+		{{Name: "alice", PublicKey: pub}, {Name: "bob"}},
+	} {
+		if err := f.SetJointRecovery(c[0], c[1]); err == nil {
+			t.Fatalf("expect error: %+v", c)
+		}
+	}
+	if err := f.SetJointRecovery(JointRecipient{Name: "alice", PublicKey: pub},
+		JointRecipient{Name: "bob", PublicKey: other}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.AddRecipient(pub)
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestJointSelfTest(t *testing.T) {
+	alicePub, alice := testRsaPem(t)
+	bob := testOtherRsaPem(t)
+	parties := []JointRecipient{{Name: "alice", PublicKey: alicePub}, {Name: "bob", PublicKey: testPublicPem(t, bob)}}
+	for _, c := range []struct {
+		keys [][]byte
+		err  error
+	}{
+		{[][]byte{alice, bob}, nil},
+		{[][]byte{alice}, ErrSelfTest},
+	} {
+		f := NewFortifierWithRsa(false, nil, nil)
+		if err := f.SetJointRecovery(parties[0], parties[1]); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := f.SetSelfTest(true, c.keys...); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := f.SetupKey(); !errors.Is(err, c.err) {
+			t.Fatalf("expect %v, got %v", c.err, err)
+		}
+	}
+
+	// the first share comes from the source of the cipher key
+	random := bytes.Repeat([]byte{1}, 32)
+	random = append(random, bytes.Repeat([]byte{2}, 32)...)
+	f := NewFortifierWithRsa(false, nil, nil)
+	if err := f.SetJointRecovery(parties[0], parties[1]); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.random = bytes.NewReader(random)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	share, err := f.unwrapJointShare(f.meta.Joint.Shares[0], []*rsa.PrivateKey{testRsaPrivateKey(t)})
+	if err != nil || !bytes.Equal(share, random[32:]) || !bytes.Equal(f.key.raw, random[:32]) {
+		t.Fatalf("bad: %x, err: %v", share, err)
+	}
+}
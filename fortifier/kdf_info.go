@@ -0,0 +1,39 @@
+package fortifier
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// ErrKDFInfoMismatch means an rsa-kem recipient was wrapped with an HKDF info
+// other than the one pinned by SetKDFInfo.
+var ErrKDFInfoMismatch = errors.New("kdf info mismatch")
+
+// SetKDFInfo sets the HKDF info the rsa-kem scheme derives the wrapping keys
+// with, followed by the label of the key wrap, separating the domains of
+// applications sharing RSA keys. The info is recorded with each recipient so
+// that recovery derives the same key. When recovering, a non-empty info pins
+// the expected one: rsa-kem recipients recorded with another info are rejected
+// with ErrKDFInfoMismatch. An empty info restores the package label. Other
+// schemes do not use HKDF and ignore it.
+func (f *Fortifier) SetKDFInfo(info string) {
+	f.kdfInfo = info
+}
+
+// checkKDFInfo checks the info of the rsa-kem recipient against the one pinned.
+func (f *Fortifier) checkKDFInfo(m *MetadataRsa) error {
+	if f.kdfInfo == "" || m.Scheme != RsaSchemeKEM || m.KDFInfo == f.kdfInfo {
+		return nil
+	}
+	return fmt.Errorf("%w: recipient info %q, expecting %q", ErrKDFInfoMismatch, m.KDFInfo, f.kdfInfo)
+}
+
+// unwrapWith unwraps the cipher key of the recipient with its scheme, deriving
+// the rsa-kem key with the recorded info and unwrapping with the recorded key wrap.
+func (m *MetadataRsa) unwrapWith(pri *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	if m.Scheme == RsaSchemeKEM {
+		return kemUnwrapRawKey(pri, ciphertext, m.KDFInfo, m.KeyWrap)
+	}
+	return m.Scheme.unwrap(pri, ciphertext)
+}
@@ -0,0 +1,82 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestKemKDFInfo(t *testing.T) {
+	pri := testRsaPrivateKey(t)
+	raw := bytes.Repeat([]byte{1}, 32)
+	// with the same encapsulated secret, only the info tells the wrapping keys apart
+	z := big.NewInt(42).FillBytes(make([]byte, pri.Size()))
+	seal := func(info string) []byte {
+		aead, err := newKemAEAD(z, info)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return aead.Seal(nil, make([]byte, aead.NonceSize()), raw, nil)
+	}
+	if bytes.Equal(seal("app one"), seal("app two")) || bytes.Equal(seal(""), seal("app one")) {
+		t.Fatalf("expect distinct wrapping keys")
+	}
+	// a custom info still tells the key wraps apart
+	for _, info := range []string{"", "app one"} {
+		gcm, err := kemKey(z, info, rsaKemInfo)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		kw, err := kemKey(z, info, rsaKemKWInfo)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if bytes.Equal(gcm, kw) {
+			t.Fatalf("info %q: expect distinct keys of the key wraps", info)
+		}
+	}
+	ciphertext, err := kemWrapRawKey(&pri.PublicKey, raw, "app one", "", bytes.NewReader(bytes.Repeat([]byte{7}, 512)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err = kemUnwrapRawKey(pri, ciphertext, "app two", ""); err == nil {
+		t.Fatalf("expect error")
+	}
+	if unwrapped, err := kemUnwrapRawKey(pri, ciphertext, "app one", ""); err != nil || !bytes.Equal(unwrapped, raw) {
+		t.Fatalf("bad: %x, err: %v", unwrapped, err)
+	}
+}
+
+func TestSetKDFInfo(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetRsaScheme(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.SetKDFInfo("app one")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if info := layout.Metadata().Recipients[0].KDFInfo; info != "app one" {
+		t.Fatalf("unexpected info %q", info)
+	}
+	// recovery derives with the recorded info, whether pinned or not
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	g := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	g.SetKDFInfo("app one")
+	if err := g.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	g = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	g.SetKDFInfo("app two")
+	if err := g.SetupKey(); !errors.Is(err, ErrKDFInfoMismatch) {
+		t.Fatalf("expect kdf info mismatch, got %v", err)
+	}
+	layout.Metadata().Recipients[0].KDFInfo = "app two"
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
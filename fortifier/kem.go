@@ -0,0 +1,229 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// RsaScheme is the way the cipher key is wrapped for an RSA recipient.
+type RsaScheme string
+
+const (
+	// RsaSchemeOAEP encrypts the cipher key with RSA-OAEP, the default
+	RsaSchemeOAEP RsaScheme = "rsa-oaep"
+	// RsaSchemeKEM encapsulates a random value with raw RSA, as in RSA-KEM of
+	// ISO 18033-2, and wraps the cipher key under a key derived from it by HKDF
+	RsaSchemeKEM RsaScheme = "rsa-kem"
+)
+
+const rsaKemInfo = "fortify rsa-kem aes256-gcm"
+
+func (s RsaScheme) String() string {
+	if s == "" {
+		return string(RsaSchemeOAEP)
+	}
+	return string(s)
+}
+
+// SetRsaScheme selects how the cipher key is wrapped for the RSA recipients.
+// Recovery follows the scheme recorded for each recipient.
+func (f *Fortifier) SetRsaScheme(scheme RsaScheme) (err error) {
+	f.rsaScheme, err = scheme.normalize()
+	return
+}
+
+// normalize returns the scheme as recorded in the metadata, empty for the default.
+func (s RsaScheme) normalize() (RsaScheme, error) {
+	switch s {
+	case "", RsaSchemeOAEP:
+		return "", nil
+	case RsaSchemeKEM, RsaSchemePGP:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown rsa scheme: %s", s)
+	}
+}
+
+// wrap wraps the raw cipher key for pub with the scheme.
+func (s RsaScheme) wrap(pub *rsa.PublicKey, raw []byte, random io.Reader) ([]byte, error) {
+	switch s {
+	case "", RsaSchemeOAEP:
+		return wrapRawKey(pub, raw, random)
+	case RsaSchemeKEM:
+		return kemWrapRawKey(pub, raw, "", "", random)
+	case RsaSchemePGP:
+		return pgpWrapRawKey(pub, raw, random)
+	default:
+		return nil, fmt.Errorf("unknown rsa scheme: %s", s)
+	}
+}
+
+// encode encodes the wrapped key as the ciphertext of the recipient entry.
+func (s RsaScheme) encode(ciphertext []byte) string {
+	if s == RsaSchemePGP {
+		return string(ciphertext)
+	}
+	return encodeCiphertext(ciphertext)
+}
+
+func (s RsaScheme) unwrap(pri *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	switch s {
+	case "", RsaSchemeOAEP:
+		return unwrapRawKey(pri, ciphertext)
+	case RsaSchemeKEM:
+		return kemUnwrapRawKey(pri, ciphertext, "", "")
+	case RsaSchemeAgeSSH:
+		return unwrapAgeSSHRsa(pri, ciphertext)
+	case RsaSchemePGP:
+		return pgpUnwrapRawKey(pri, ciphertext)
+	default:
+		return nil, fmt.Errorf("unknown rsa scheme: %s", s)
+	}
+}
+
+// kemWrapRawKey picks z at random below the modulus, and returns z^e mod n
+// followed by the raw cipher key wrapped with the key wrap under the key derived
+// from z with the HKDF info, the package label if empty.
+func kemWrapRawKey(pub *rsa.PublicKey, raw []byte, info string, wrap KeyWrap, random io.Reader) ([]byte, error) {
+	z, err := rand.Int(random, pub.N)
+	if err != nil {
+		return nil, err
+	}
+	c := new(big.Int).Exp(z, big.NewInt(int64(pub.E)), pub.N)
+	secret := z.FillBytes(make([]byte, pub.Size()))
+	defer clear(secret)
+	if wrap == KeyWrapAESKW {
+		kek, err := kemKey(secret, info, rsaKemKWInfo)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(kek)
+		wrapped, err := AESKeyWrap(kek, raw)
+		if err != nil {
+			return nil, err
+		}
+		return append(c.FillBytes(make([]byte, pub.Size(), pub.Size()+len(wrapped))), wrapped...), nil
+	}
+	aead, err := newKemAEAD(secret, info)
+	if err != nil {
+		return nil, err
+	}
+	out := c.FillBytes(make([]byte, pub.Size(), pub.Size()+len(raw)+aead.Overhead()))
+	return aead.Seal(out, make([]byte, aead.NonceSize()), raw, nil), nil
+}
+
+func kemUnwrapRawKey(pri *rsa.PrivateKey, ciphertext []byte, info string, wrap KeyWrap) ([]byte, error) {
+	if _, err := wrap.normalize(); err != nil {
+		return nil, err
+	}
+	size := pri.Size()
+	if len(ciphertext) <= size {
+		return nil, errors.New("rsa-kem ciphertext too short")
+	}
+	c := new(big.Int).SetBytes(ciphertext[:size])
+	if c.Cmp(pri.N) >= 0 {
+		return nil, errors.New("rsa-kem encapsulation out of range")
+	}
+	z, err := kemDecapsulate(pri, c)
+	if err != nil {
+		return nil, err
+	}
+	secret := z.FillBytes(make([]byte, size))
+	defer clear(secret)
+	if wrap == KeyWrapAESKW {
+		kek, err := kemKey(secret, info, rsaKemKWInfo)
+		if err != nil {
+			return nil, err
+		}
+		defer clear(kek)
+		raw, err := AESKeyUnwrap(kek, ciphertext[size:])
+		if err != nil {
+			return nil, fmt.Errorf("rsa-kem: %w", err)
+		}
+		return raw, nil
+	}
+	aead, err := newKemAEAD(secret, info)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[size:], nil)
+	if err != nil {
+		return nil, errors.New("rsa-kem authentication failed")
+	}
+	return raw, nil
+}
+
+// kemDecapsulate returns c^d mod n. math/big is not constant-time, so c is
+// blinded with a random r^e first, as crypto/rsa did before Go 1.20, and the
+// private operation runs on the CRT values of the key. The result is checked
+// against c, so that a faulty computation does not leak the factors.
+func kemDecapsulate(pri *rsa.PrivateKey, c *big.Int) (*big.Int, error) {
+	n, e := pri.N, big.NewInt(int64(pri.E))
+	var r, ir *big.Int
+	for ir == nil {
+		var err error
+		if r, err = rand.Int(rand.Reader, n); err != nil {
+			return nil, err
+		}
+		if r.Sign() > 0 {
+			ir = new(big.Int).ModInverse(r, n)
+		}
+	}
+	blinded := new(big.Int).Exp(r, e, n)
+	blinded.Mul(blinded, c).Mod(blinded, n)
+	var z *big.Int
+	if p := pri.Precomputed; len(pri.Primes) == 2 && p.Dp != nil && p.Dq != nil && p.Qinv != nil {
+		// z = zq + q * (qInv * (zp - zq) mod p)
+		zp := new(big.Int).Exp(blinded, p.Dp, pri.Primes[0])
+		zq := new(big.Int).Exp(blinded, p.Dq, pri.Primes[1])
+		zp.Sub(zp, zq).Mul(zp, p.Qinv).Mod(zp, pri.Primes[0])
+		z = zp.Mul(zp, pri.Primes[1]).Add(zp, zq)
+	} else {
+		z = blinded.Exp(blinded, pri.D, n)
+	}
+	z.Mul(z, ir).Mod(z, n)
+	if new(big.Int).Exp(z, e, n).Cmp(c) != 0 {
+		return nil, errors.New("rsa-kem decapsulation failed")
+	}
+	return z, nil
+}
+
+// newKemAEAD derives the wrapping key from the encapsulated secret. The key is
+// fresh for every wrapping, so the nonce is fixed at zero.
+func newKemAEAD(secret []byte, info string) (cipher.AEAD, error) {
+	key, err := kemKey(secret, info, rsaKemInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// kemKey derives the AES-256 key wrapping the cipher key from the encapsulated
+// secret with the label of the key wrap as HKDF info, following the info given
+// if any, so that a custom info keeps the key wraps apart.
+func kemKey(secret []byte, info, label string) ([]byte, error) {
+	if info != "" {
+		info += "\x00" + label
+	} else {
+		info = label
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
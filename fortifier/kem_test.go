@@ -0,0 +1,88 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+)
+
+func TestRsaKem(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("encapsulated payload")
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		f.SetRedundantWrap(true)
+		if err := f.SetRsaScheme(RsaSchemeKEM); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		actual, err := testDecryptRsaFile(t, path, pri)
+		if err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+		if !bytes.Equal(actual, plaintext) {
+			t.Fatalf("seal %v bad: %q", seal, actual)
+		}
+	}
+}
+
+func TestRsaKemSchemeFromMetadata(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetRsaScheme(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if layout.Metadata().Recipients[0].Scheme != RsaSchemeKEM {
+		t.Fatalf("unexpected scheme %q", layout.Metadata().Recipients[0].Scheme)
+	}
+	if suite := layout.Metadata().Suite; suite != "RSA-KEM-HKDF-SHA256+AES256-CTR-HMAC-SHA256" {
+		t.Fatalf("unexpected suite %q", suite)
+	}
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	layout.Metadata().Recipients[0].Scheme = RsaSchemeOAEP
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+
+	path = testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout = testReadLayout(t, path)
+	_ = in.Close()
+	if layout.Metadata().Recipients[0].Scheme != "" {
+		t.Fatalf("expect the default scheme unrecorded, got %q", layout.Metadata().Recipients[0].Scheme)
+	}
+	layout.Metadata().Recipients[0].Scheme = RsaSchemeKEM
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := f.SetRsaScheme("rsa-pkcs1"); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestKemDecapsulate(t *testing.T) {
+	pri := testRsaPrivateKey(t)
+	// a key assembled from its parts, without the CRT values
+	bare := &rsa.PrivateKey{PublicKey: pri.PublicKey, D: pri.D, Primes: pri.Primes}
+	for i := int64(2); i < 6; i++ {
+		z := big.NewInt(i * 1000003)
+		c := new(big.Int).Exp(z, big.NewInt(int64(pri.E)), pri.N)
+		for _, k := range []*rsa.PrivateKey{pri, bare} {
+			if actual, err := kemDecapsulate(k, c); err != nil || actual.Cmp(z) != 0 {
+				t.Fatalf("bad: %v, err: %v", actual, err)
+			}
+		}
+	}
+	// a fault in the private operation is caught
+	faulty := *pri
+	faulty.Precomputed.Dp = new(big.Int).Add(pri.Precomputed.Dp, big.NewInt(2))
+	if _, err := kemDecapsulate(&faulty, big.NewInt(2)); err == nil {
+		t.Fatalf("expect error")
+	}
+}
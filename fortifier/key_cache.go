@@ -0,0 +1,122 @@
+package fortifier
+
+import (
+	"container/list"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+)
+
+const defaultKeyCacheSize = 256
+
+// SharedKeyCache is the process-wide KeyCache of SetKeyCache, for services
+// fortifying for the same recipients over and over.
+var SharedKeyCache = NewKeyCache(defaultKeyCacheSize)
+
+// KeyCache caches public keys parsed by ParsePublicKey, keyed by the SHA-256
+// digest of the exact key bytes, evicting the least recently used beyond its
+// size. It is safe for concurrent use. Entries are immutable: every hit
+// returns a copy of the key and KeyInfo, which the caller may modify freely.
+// Only RSA, ECDSA and Ed25519 keys are cached; parse errors are not.
+type KeyCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type keyCacheEntry struct {
+	digest [sha256.Size]byte
+	key    crypto.PublicKey
+	info   KeyInfo
+}
+
+// NewKeyCache makes a KeyCache holding at most size keys, at least one.
+func NewKeyCache(size int) *KeyCache {
+	return &KeyCache{size: max(size, 1), order: list.New(), entries: make(map[[sha256.Size]byte]*list.Element)}
+}
+
+// SetKeyCache makes the Fortifier look up the key bytes of its RSA recipients,
+// of AddRecipient or NewFortifierWithRsa, in the cache, such as SharedKeyCache,
+// before parsing them. nil disables the cache.
+func (f *Fortifier) SetKeyCache(c *KeyCache) {
+	f.keyCache = c
+}
+
+// ParsePublicKey returns the cached key of the bytes, or parses and caches it
+// as ParsePublicKey.
+func (c *KeyCache) ParsePublicKey(bytes []byte) (crypto.PublicKey, KeyInfo, error) {
+	return c.parsePublicKey(bytes, nil)
+}
+
+// Len returns the number of cached keys.
+func (c *KeyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *KeyCache) parsePublicKey(bytes []byte, passphrase PassphraseProvider) (crypto.PublicKey, KeyInfo, error) {
+	digest := sha256.Sum256(bytes)
+	c.mu.Lock()
+	if e, ok := c.entries[digest]; ok {
+		c.order.MoveToFront(e)
+		entry := e.Value.(*keyCacheEntry)
+		c.mu.Unlock()
+		k, _ := clonePublicKey(entry.key)
+		return k, cloneKeyInfo(entry.info), nil
+	}
+	c.mu.Unlock()
+	// parsed unlocked, as parsing may prompt for a passphrase
+	k, info, err := parsePublicKey(bytes, passphrase)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	cached, ok := clonePublicKey(k)
+	if !ok {
+		return k, info, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok = c.entries[digest]; !ok {
+		c.entries[digest] = c.order.PushFront(&keyCacheEntry{digest: digest, key: cached, info: cloneKeyInfo(info)})
+		for c.order.Len() > c.size {
+			delete(c.entries, c.order.Remove(c.order.Back()).(*keyCacheEntry).digest)
+		}
+	}
+	return k, info, nil
+}
+
+// parseCachedPublicKey parses the key bytes through the cache of SetKeyCache, if any.
+func (f *Fortifier) parseCachedPublicKey(bytes []byte, passphrase PassphraseProvider) (crypto.PublicKey, KeyInfo, error) {
+	if f.keyCache == nil {
+		return parsePublicKey(bytes, passphrase)
+	}
+	return f.keyCache.parsePublicKey(bytes, passphrase)
+}
+
+// clonePublicKey deeply copies the key, reporting whether its type is known.
+func clonePublicKey(k crypto.PublicKey) (crypto.PublicKey, bool) {
+	switch k := k.(type) {
+	case *rsa.PublicKey:
+		return &rsa.PublicKey{N: new(big.Int).Set(k.N), E: k.E}, true
+	case *ecdsa.PublicKey:
+		return &ecdsa.PublicKey{Curve: k.Curve, X: new(big.Int).Set(k.X), Y: new(big.Int).Set(k.Y)}, true
+	case ed25519.PublicKey:
+		return slices.Clone(k), true
+	}
+	return k, false
+}
+
+func cloneKeyInfo(info KeyInfo) KeyInfo {
+	info.Options = maps.Clone(info.Options)
+	info.ExtKeyUsage = slices.Clone(info.ExtKeyUsage)
+	info.Principals = slices.Clone(info.Principals)
+	return info
+}
@@ -0,0 +1,80 @@
+package fortifier
+
+import (
+	"crypto/rsa"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestKeyCache(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	c := NewKeyCache(2)
+	k, info, err := c.ParsePublicKey(pub)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cached, cachedInfo, err := c.ParsePublicKey(pub)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !k.(*rsa.PublicKey).Equal(cached) || !reflect.DeepEqual(info, cachedInfo) || c.Len() != 1 {
+		t.Fatalf("cache hit differs: %+v, %+v", info, cachedInfo)
+	}
+	cached.(*rsa.PublicKey).N.Set(big.NewInt(3))
+	if again, _, _ := c.ParsePublicKey(pub); !k.(*rsa.PublicKey).Equal(again) {
+		t.Fatalf("cache entry modified through a hit")
+	}
+
+	_, _, _ = c.ParsePublicKey(testPublicPem(t, testOtherRsaPem(t)))
+	authorized, _, _ := testEd25519Ssh(t)
+	_, _, _ = c.ParsePublicKey(authorized)
+	if c.Len() != 2 {
+		t.Fatalf("bad cache size: %d", c.Len())
+	}
+	if _, _, err = c.ParsePublicKey([]byte("not a key")); err == nil || c.Len() != 2 {
+		t.Fatalf("expect error, not cached")
+	}
+
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetKeyCache(c)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	if _, err = testDecryptRsaFile(t, path, pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestKeyCacheConcurrent(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	keys := [][]byte{pub, testPublicPem(t, testOtherRsaPem(t))}
+	for range 4 {
+		authorized, _, _ := testEd25519Ssh(t)
+		keys = append(keys, authorized)
+	}
+	c := NewKeyCache(3)
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kb := keys[i%len(keys)]
+			expected, _, _ := ParsePublicKey(kb)
+			for range 50 {
+				k, _, err := c.ParsePublicKey(kb)
+				if err != nil {
+					t.Errorf("err: %v", err)
+					return
+				}
+				if !reflect.DeepEqual(k, expected) {
+					t.Errorf("cache hit differs")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Len() > 3 {
+		t.Fatalf("bad cache size: %d", c.Len())
+	}
+}
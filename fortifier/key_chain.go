@@ -0,0 +1,61 @@
+package fortifier
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SetKeyChain recovers the cipher key through a hierarchy of keys kept
+// fortified: the private key bytes of NewFortifierWithRsa, the root key,
+// decrypt the first fortified file of the chain, whose payload is the private
+// key decrypting the second, and so on, the payload of the last one being the
+// private key which recovers the cipher key. The passphrase provider of the
+// Fortifier also serves the keys of the chain. Each intermediate private key is
+// zeroized once used. An empty chain uses the root key directly.
+func (f *Fortifier) SetKeyChain(chain ...[]byte) {
+	f.keyChain = chain
+}
+
+// loadKeyChain recovers the private key at the end of the key chain in place of
+// the key bytes.
+func (f *Fortifier) loadKeyChain() error {
+	kb := f.key.bytes
+	for i, fortified := range f.keyChain {
+		next, err := f.unwrapChainedKey(kb, fortified)
+		if i > 0 {
+			clear(kb)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: key chain level %d: %w", rsaFortifier, i+1, err)
+		}
+		kb = next
+	}
+	defer clear(kb)
+	return f.LoadPrivateKey(bytes.NewReader(kb))
+}
+
+// unwrapChainedKey decrypts the fortified private key with the private key bytes.
+func (f *Fortifier) unwrapChainedKey(kb, fortified []byte) ([]byte, error) {
+	r := bytes.NewReader(fortified)
+	layout := &FileLayout{}
+	if err := layout.ReadHeadIn(r); err != nil {
+		return nil, err
+	}
+	if layout.DataLength() > maxPrivateKeySize {
+		return nil, fmt.Errorf("%w: payload of %d bytes", ErrPrivateKeyTooLarge, layout.DataLength())
+	}
+	g := NewFortifierWithRsa(f.verbose, layout.Metadata(), kb)
+	g.passphrase, g.policy, g.fips = f.passphrase, f.policy, f.fips
+	raw, err := g.RecoverRaw(layout)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(raw)
+	var buf bytes.Buffer
+	buf.Grow(int(layout.DataLength()))
+	if err = DecryptPayload(raw, layout, r, &buf); err != nil {
+		clear(buf.Bytes())
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
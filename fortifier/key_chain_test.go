@@ -0,0 +1,48 @@
+package fortifier
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestKeyChain(t *testing.T) {
+	rootPub, rootPri := testRsaPem(t)
+	masterPri, dataPri := testOtherRsaPem(t), testOtherRsaPem(t)
+	fortify := func(pub, plaintext []byte) []byte {
+		b, err := os.ReadFile(testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return b
+	}
+	// the master key is fortified under the root key, the data key under the master key
+	master := fortify(rootPub, masterPri)
+	data := fortify(testPublicPem(t, masterPri), dataPri)
+	plaintext := []byte("key hierarchy payload")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, testPublicPem(t, dataPri)), CipherModeAes256CTR, t.TempDir(), plaintext)
+
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsa(false, layout.Metadata(), rootPri)
+	f.SetKeyChain(master, data)
+	raw, err := f.RecoverRaw(layout)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	if err = DecryptPayload(raw, layout, in, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+
+	for _, chain := range [][][]byte{{data, master}, {master}, {data}, {master, []byte("not fortified")}} {
+		f = NewFortifierWithRsa(false, layout.Metadata(), rootPri)
+		f.SetKeyChain(chain...)
+		if _, err = f.RecoverRaw(layout); err == nil {
+			t.Fatalf("expect error")
+		}
+	}
+}
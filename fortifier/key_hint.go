@@ -0,0 +1,63 @@
+package fortifier
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// keyHintSize is the number of bytes of the HMAC kept as the key ID hint.
+const keyHintSize = 4
+
+// SetKeyHint enables recording a key ID hint for every RSA recipient instead
+// of its fingerprint: the first 4 bytes of HMAC-SHA256 over the DER
+// SubjectPublicKeyInfo of the recipient, keyed by a random salt of the file.
+// A key holder confirms the hint against their own key, which shortlists the
+// private keys of a keyring as the fingerprints do, but the hints of a key
+// differ from file to file and cannot be looked up in a list of fingerprints,
+// so the files of a recipient are not linked together. Anyone holding a
+// candidate public key can still test it against a hint, which also matches one
+// other key in about four billion. It excludes SetEmbedPublicKey.
+func (f *Fortifier) SetKeyHint(b bool) {
+	f.keyHint = b
+}
+
+// newKeyHintSalt generates the salt of the key ID hints of the file.
+func (f *Fortifier) newKeyHintSalt() error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	f.meta.KeyHintSalt = base64.RawURLEncoding.EncodeToString(salt)
+	return nil
+}
+
+// identify records the public key of the recipient by its fingerprint, or by
+// its key ID hint if SetKeyHint is enabled.
+func (f *Fortifier) identify(m *MetadataRsa, pub crypto.PublicKey) {
+	if f.keyHint {
+		m.KeyHint = f.meta.keyHint(pub)
+	} else {
+		m.Fingerprint = PublicKeyFingerprint(pub)
+	}
+}
+
+// keyHint returns the key ID hint of pub under the salt of the metadata, or
+// an empty string if the salt is missing or invalid.
+func (m *Metadata) keyHint(pub crypto.PublicKey) string {
+	salt, err := base64.RawURLEncoding.DecodeString(m.KeyHintSalt)
+	if err != nil || len(salt) == 0 {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(der)
+	return hex.EncodeToString(mac.Sum(nil)[:keyHintSize])
+}
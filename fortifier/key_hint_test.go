@@ -0,0 +1,69 @@
+package fortifier
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeyHint(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	other := testOtherRsaPem(t)
+	plaintext := []byte("no fingerprints")
+	encrypt := func() *Metadata {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetKeyHint(true)
+		in, layout := testReadLayout(t, testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext))
+		_ = in.Close()
+		return layout.Metadata()
+	}
+	meta := encrypt()
+	r := meta.Recipients[0]
+	if r.Fingerprint != "" || len(r.KeyHint) != 2*keyHintSize || meta.KeyHintSalt == "" {
+		t.Fatalf("unexpected recipient: %+v", r)
+	}
+	if again := encrypt(); again.Recipients[0].KeyHint == r.KeyHint {
+		t.Fatalf("key hint not salted: %s", r.KeyHint)
+	}
+	if !meta.mayRecover(&testRsaPrivateKey(t).PublicKey) {
+		t.Fatalf("key hint mismatches the recipient key")
+	}
+	otherPub, _, err := ParsePublicKey(testPublicPem(t, other))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if meta.mayRecover(otherPub) {
+		t.Fatalf("key hint matches another key")
+	}
+
+	path := testEncryptFile(t, func() *Fortifier {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetKeyHint(true)
+		f.SetSealMetadata(true)
+		return f
+	}(), CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsaKeyring(false, layout.Metadata(), [][]byte{other, pri})
+	var out bytes.Buffer
+	if err = NewDecrypter(CipherModeAes256CTR, f).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+	err = NewFortifierWithRsaKeyring(false, layout.Metadata(), [][]byte{other}).SetupKey()
+	if err == nil || !strings.Contains(err.Error(), "key 1: fingerprint mismatch") {
+		t.Fatalf("expect a fingerprint mismatch, got %v", err)
+	}
+}
+
+func TestKeyHintEmbedPublicKey(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetKeyHint(true)
+	f.SetEmbedPublicKey(true)
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,75 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrKeyKindMismatch = errors.New("cipher key kind mismatch")
+
+// Passphrase interactions of a key kind, see KeyKindInfo
+const (
+	PassphrasePrivateKey = "private-key" // encrypted PKCS #8 or OpenSSH private key
+	PassphrasePKCS12     = "pkcs12"      // password of a PKCS #12 bundle
+	PassphraseMnemonic   = "mnemonic"    // BIP39 mnemonic phrase
+)
+
+// KeyKindInfo describes the capabilities of a cipher key kind.
+type KeyKindInfo struct {
+	Kind CipherKeyKind
+	// PublicKeyOnly reports the kind fortifies with public keys alone,
+	// without any secret at hand
+	PublicKeyOnly bool
+	// Hardware reports the kind needs a hardware token or module
+	Hardware bool
+	// Passphrases lists the passphrases the kind may prompt for
+	Passphrases []string
+}
+
+// keyKinds registers the kinds compiled in. Kinds behind build tags add
+// themselves from an init function of their tagged file.
+var keyKinds = map[CipherKeyKind]KeyKindInfo{
+	CipherKeyKindRSA: {Kind: CipherKeyKindRSA, PublicKeyOnly: true,
+		Passphrases: []string{PassphrasePrivateKey, PassphrasePKCS12}},
+	CipherKeyKindSSS:  {Kind: CipherKeyKindSSS},
+	CipherKeyKindOIDC: {Kind: CipherKeyKindOIDC, PublicKeyOnly: true},
+	CipherKeyKindMnemonic: {Kind: CipherKeyKindMnemonic,
+		Passphrases: []string{PassphraseMnemonic}},
+}
+
+// SupportedKeyKinds lists the cipher key kinds compiled in, sorted by name.
+func SupportedKeyKinds() []KeyKindInfo {
+	kinds := make([]KeyKindInfo, 0, len(keyKinds))
+	for _, info := range keyKinds {
+		info.Passphrases = slices.Clone(info.Passphrases)
+		kinds = append(kinds, info)
+	}
+	slices.SortFunc(kinds, func(a, b KeyKindInfo) int {
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+	return kinds
+}
+
+// RequireKeyKind pins the cipher key kind of the files to recover: a file
+// recording another kind, or a fortifier of another kind, fails with
+// ErrKeyKindMismatch before any recovery is attempted. An empty kind lifts it.
+func (f *Fortifier) RequireKeyKind(kind CipherKeyKind) {
+	f.requireKind = kind
+}
+
+// checkKeyKind checks the kind recorded in the metadata, if given, and the kind
+// of the fortifier against RequireKeyKind.
+func (f *Fortifier) checkKeyKind(meta *Metadata) error {
+	if f.requireKind == "" {
+		return nil
+	}
+	if meta != nil && meta.Key != f.requireKind {
+		return fmt.Errorf("%w: file of kind %q, requiring %s", ErrKeyKindMismatch, meta.Key, f.requireKind)
+	}
+	if f.key.kind != f.requireKind {
+		return fmt.Errorf("%w: %s fortifier, requiring %s", ErrKeyKindMismatch, f.key.kind, f.requireKind)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package fortifier
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSupportedKeyKinds(t *testing.T) {
+	kinds := SupportedKeyKinds()
+	i := slices.IndexFunc(kinds, func(info KeyKindInfo) bool { return info.Kind == CipherKeyKindRSA })
+	if i < 0 {
+		t.Fatalf("expect rsa in %+v", kinds)
+	}
+	if rsa := kinds[i]; !rsa.PublicKeyOnly || rsa.Hardware || !slices.Contains(rsa.Passphrases, PassphrasePrivateKey) {
+		t.Fatalf("unexpected rsa capabilities %+v", rsa)
+	}
+	for _, info := range kinds {
+		// no hardware-backed kind is compiled into the default build
+		if info.Hardware {
+			t.Fatalf("unexpected hardware kind %s", info.Kind)
+		}
+	}
+	kinds[i].Passphrases[0] = ""
+	if SupportedKeyKinds()[i].Passphrases[0] != PassphrasePrivateKey {
+		t.Fatalf("expect a copy of the registry")
+	}
+}
+
+func TestRequireKeyKind(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("rsa wrapped"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.RequireKeyKind(CipherKeyKindRSA)
+	if err := f.AuthenticateMetadata(layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.RequireKeyKind(CipherKeyKindSSS)
+	if err := f.AuthenticateMetadata(layout); !errors.Is(err, ErrKeyKindMismatch) {
+		t.Fatalf("expect ErrKeyKindMismatch, got %v", err)
+	}
+	if len(f.key.raw) != 0 {
+		t.Fatalf("expect no recovery attempted")
+	}
+
+	// a file claiming another kind is refused before its recipients are looked at
+	layout.Metadata().Key = CipherKeyKindSSS
+	f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.RequireKeyKind(CipherKeyKindRSA)
+	if _, err := f.RecoverRaw(layout); !errors.Is(err, ErrKeyKindMismatch) {
+		t.Fatalf("expect ErrKeyKindMismatch, got %v", err)
+	}
+
+	f = NewFortifierWithSss(false, false, testSssParts(t))
+	f.RequireKeyKind(CipherKeyKindRSA)
+	if err := f.SetupKey(); !errors.Is(err, ErrKeyKindMismatch) {
+		t.Fatalf("expect ErrKeyKindMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package fortifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+)
+
+// MatchesPublicKey reports whether the private key corresponds to the public
+// key: RSA keys by modulus and exponent, ECDSA and Ed25519 keys by the public
+// key derived from the private scalar or seed, rather than the public half
+// stored along with the private key. Keys of different or unknown kinds do not
+// match.
+func MatchesPublicKey(priv crypto.PrivateKey, pub crypto.PublicKey) bool {
+	switch k := normalizePrivateKey(priv).(type) {
+	case *rsa.PrivateKey:
+		p, ok := pub.(*rsa.PublicKey)
+		return ok && k != nil && p != nil && k.N != nil && p.N != nil && k.N.Cmp(p.N) == 0 && k.E == p.E
+	case *ecdsa.PrivateKey:
+		p, ok := pub.(*ecdsa.PublicKey)
+		if !ok || k == nil || p == nil {
+			return false
+		}
+		derived, err := k.ECDH()
+		if err != nil {
+			return false
+		}
+		expected, err := p.ECDH()
+		return err == nil && derived.PublicKey().Equal(expected)
+	case ed25519.PrivateKey:
+		p, ok := pub.(ed25519.PublicKey)
+		if !ok || len(k) != ed25519.PrivateKeySize {
+			return false
+		}
+		return ed25519.NewKeyFromSeed(k.Seed()).Public().(ed25519.PublicKey).Equal(p)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package fortifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestMatchesPublicKey(t *testing.T) {
+	rsaKey := testRsaPrivateKey(t)
+	otherRsa, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	otherEc, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	otherEdPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// the public half stored with the private key is not trusted
+	swapped := *ecKey
+	swapped.PublicKey = otherEc.PublicKey
+
+	for name, c := range map[string]struct {
+		priv  crypto.PrivateKey
+		pub   crypto.PublicKey
+		match bool
+	}{
+		"rsa":                 {rsaKey, &rsaKey.PublicKey, true},
+		"rsa other":           {rsaKey, &otherRsa.PublicKey, false},
+		"rsa other exponent":  {rsaKey, &rsa.PublicKey{N: rsaKey.N, E: 3}, false},
+		"ecdsa":               {ecKey, &ecKey.PublicKey, true},
+		"ecdsa other":         {ecKey, &otherEc.PublicKey, false},
+		"ecdsa stored public": {&swapped, &otherEc.PublicKey, false},
+		"ed25519":             {edKey, edPub, true},
+		"ed25519 pointer":     {&edKey, edPub, true},
+		"ed25519 other":       {edKey, otherEdPub, false},
+		"rsa for ecdsa":       {rsaKey, &ecKey.PublicKey, false},
+		"ecdsa for ed25519":   {ecKey, edPub, false},
+		"ed25519 for rsa":     {edKey, &rsaKey.PublicKey, false},
+		"nil":                 {nil, edPub, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := MatchesPublicKey(c.priv, c.pub); got != c.match {
+				t.Fatalf("expect %t, got %t", c.match, got)
+			}
+		})
+	}
+}
+
+func TestMayRecoverWithEmbeddedPublicKey(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	m := &MetadataRsa{KeyHint: "0000"}
+	if err := m.embedPublicKey(&key.PublicKey); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	meta := &Metadata{Recipients: []*MetadataRsa{m}}
+	if meta.mayRecover(&key.PublicKey) {
+		t.Fatalf("expect no match of the key hint")
+	}
+	if !meta.mayRecoverWith(key) {
+		t.Fatalf("expect a match of the embedded public key")
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if meta.mayRecoverWith(other) {
+		t.Fatalf("expect no match of another key")
+	}
+}
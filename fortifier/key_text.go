@@ -0,0 +1,30 @@
+package fortifier
+
+import "bytes"
+
+// utf8BOM is the byte order mark Windows editors put at the start of UTF-8 text.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// normalizeKeyText strips the byte order mark and the surrounding whitespace of
+// a key pasted as text and turns CRLF and lone CR line endings into LF, which
+// only moves line breaks the base64 of PEM blocks ignores. DER keys are left
+// untouched. b is returned sliced unless it holds CRs, in which case a copy is
+// returned and copied is true, for the copy of a private key to be cleared.
+func normalizeKeyText(b []byte) (normalized []byte, copied bool) {
+	if looksLikeDer(b) {
+		return b, false
+	}
+	b = bytes.TrimSpace(bytes.TrimPrefix(b, utf8BOM))
+	if bytes.IndexByte(b, '\r') < 0 {
+		return b, false
+	}
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\r' {
+			out = append(out, b[i])
+		} else if i+1 == len(b) || b[i+1] != '\n' {
+			out = append(out, '\n')
+		}
+	}
+	return out, true
+}
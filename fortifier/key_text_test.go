@@ -0,0 +1,69 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testWindowsText makes b look pasted from a Windows editor: a byte order
+// mark, CRLF line endings and surrounding whitespace.
+func testWindowsText(b []byte) []byte {
+	crlf := bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+	return append(append(append([]byte{}, utf8BOM...), " \t\r\n"...), append(crlf, "  \r\n\r\n"...)...)
+}
+
+func TestNormalizeKeyText(t *testing.T) {
+	for in, expected := range map[string]string{
+		"\xEF\xBB\xBF  key  \r\n": "key",
+		"a\r\nb\rc\n":             "a\nb\nc",
+		"a b\n":                   "a b",
+	} {
+		if actual, _ := normalizeKeyText([]byte(in)); string(actual) != expected {
+			t.Fatalf("%q: bad: %q", in, actual)
+		}
+	}
+	der := x509.MarshalPKCS1PrivateKey(testRsaPrivateKey(t))
+	if actual, copied := normalizeKeyText(der); !bytes.Equal(actual, der) || copied {
+		t.Fatalf("expect DER untouched")
+	}
+}
+
+func TestParseKeysPastedFromWindows(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	_, expected, err := ParsePublicKey(pub)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	authorized := ssh.MarshalAuthorizedKey(testSshPublicKey(t, &testRsaPrivateKey(t).PublicKey))
+	for _, kb := range [][]byte{pub, authorized} {
+		_, info, err := ParsePublicKey(testWindowsText(kb))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if info.Fingerprint != expected.Fingerprint {
+			t.Fatalf("bad fingerprint: %s", info.Fingerprint)
+		}
+	}
+
+	_, openssh, _ := testEd25519Ssh(t)
+	for _, kb := range [][]byte{pri, testWindowsText(pri), testWindowsText(openssh)} {
+		pasted := bytes.Clone(kb)
+		if _, err = NewFortifierWithRsa(false, nil, pasted).parsePrivateKey(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !bytes.Equal(pasted, kb) {
+			t.Fatalf("key bytes altered")
+		}
+	}
+
+	// the base64 of the block is not altered, only the line breaks around it
+	broken := strings.Replace(string(pri), "\n", "\r\n", 3)
+	normalized, _ := normalizeKeyText([]byte(broken))
+	if !bytes.Equal(normalized, bytes.TrimSpace(pri)) {
+		t.Fatalf("bad: %q", normalized)
+	}
+}
@@ -0,0 +1,120 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// KeyWrap is the symmetric wrapping of the cipher key under the key derived
+// from the encapsulated secret of an rsa-kem recipient.
+type KeyWrap string
+
+const (
+	// KeyWrapGCM seals the cipher key with AES-256-GCM, the default
+	KeyWrapGCM KeyWrap = "aes256-gcm"
+	// KeyWrapAESKW wraps the cipher key with the AES Key Wrap of RFC 3394, as
+	// HSMs and KMS unwrapping keys expect, and as RSA-KEM of RFC 5990 does
+	KeyWrapAESKW KeyWrap = "aes-kw"
+)
+
+const rsaKemKWInfo = "fortify rsa-kem aes-kw"
+
+// aesKWIV is the default initial value of RFC 3394, section 2.2.3.1.
+var aesKWIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// ErrKeyUnwrapIntegrity means AES Key Wrap ciphertext fails its integrity check.
+var ErrKeyUnwrapIntegrity = errors.New("aes key unwrap integrity check failed")
+
+func (w KeyWrap) String() string {
+	if w == "" {
+		return string(KeyWrapGCM)
+	}
+	return string(w)
+}
+
+// normalize returns the key wrap as recorded in the metadata, empty for the default.
+func (w KeyWrap) normalize() (KeyWrap, error) {
+	switch w {
+	case "", KeyWrapGCM:
+		return "", nil
+	case KeyWrapAESKW:
+		return w, nil
+	default:
+		return "", fmt.Errorf("unknown key wrap: %s", w)
+	}
+}
+
+// SetKeyWrap selects how the rsa-kem scheme wraps the cipher key under the key
+// derived from the encapsulated secret, recorded with each recipient so that
+// recovery follows it. Other schemes ignore it.
+func (f *Fortifier) SetKeyWrap(w KeyWrap) (err error) {
+	f.keyWrap, err = w.normalize()
+	return
+}
+
+// AESKeyWrap wraps the key data, a multiple of 8 bytes of at least 16 bytes,
+// under the key encryption key with the AES Key Wrap of RFC 3394 and its default
+// initial value. The ciphertext is 8 bytes longer than the key data.
+func AESKeyWrap(kek, plaintext []byte) ([]byte, error) {
+	if len(plaintext) < 16 || len(plaintext)%8 != 0 {
+		return nil, fmt.Errorf("aes key wrap of %d bytes, not a multiple of 8 of at least 16", len(plaintext))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(plaintext) / 8
+	out := make([]byte, 8+len(plaintext))
+	copy(out, aesKWIV)
+	copy(out[8:], plaintext)
+	b := make([]byte, aes.BlockSize)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b, out[:8])
+			copy(b[8:], out[8*i:8*i+8])
+			block.Encrypt(b, b)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[8*i:8*i+8], b[8:])
+		}
+	}
+	clear(b)
+	return out, nil
+}
+
+// AESKeyUnwrap unwraps the ciphertext of AESKeyWrap under the key encryption
+// key, failing with ErrKeyUnwrapIntegrity unless the initial value checks.
+func AESKeyUnwrap(kek, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 24 || len(ciphertext)%8 != 0 {
+		return nil, fmt.Errorf("aes key unwrap of %d bytes, not a multiple of 8 of at least 24", len(ciphertext))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(ciphertext)/8 - 1
+	a := make([]byte, 8)
+	copy(a, ciphertext)
+	out := make([]byte, 8*n)
+	copy(out, ciphertext[8:])
+	b := make([]byte, aes.BlockSize)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], out[8*(i-1):8*i])
+			block.Decrypt(b, b)
+			copy(a, b[:8])
+			copy(out[8*(i-1):8*i], b[8:])
+		}
+	}
+	clear(b)
+	if subtle.ConstantTimeCompare(a, aesKWIV) != 1 {
+		clear(out)
+		return nil, ErrKeyUnwrapIntegrity
+	}
+	return out, nil
+}
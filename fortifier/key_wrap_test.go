@@ -0,0 +1,67 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// RFC 3394, section 4
+func TestAESKeyWrapVectors(t *testing.T) {
+	const kek = "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F"
+	const data = "00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F"
+	for _, c := range []struct {
+		kek, data, ciphertext string
+	}{
+		{kek[:32], data[:32], "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5"},
+		{kek[:48], data[:32], "96778B25AE6CA435F92B5B97C050AED2468AB8A17AD84E5D"},
+		{kek, data[:32], "64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7"},
+		{kek[:48], data[:48], "031D33264E15D33268F24EC260743EDCE1C6C7DDEE725A936BA814915C6762D2"},
+		{kek, data[:48], "A8F9BC1612C68B3FF6E6F4FBE30E71E4769C8B80A32CB8958CD5D17D6B254DA1"},
+		{kek, data, "28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21"},
+	} {
+		k, _ := hex.DecodeString(c.kek)
+		d, _ := hex.DecodeString(c.data)
+		want, _ := hex.DecodeString(c.ciphertext)
+		got, err := AESKeyWrap(k, d)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("bad: %X, err: %v", got, err)
+		}
+		unwrapped, err := AESKeyUnwrap(k, want)
+		if err != nil || !bytes.Equal(unwrapped, d) {
+			t.Fatalf("bad: %X, err: %v", unwrapped, err)
+		}
+		want[len(want)-1] ^= 1
+		if _, err = AESKeyUnwrap(k, want); !errors.Is(err, ErrKeyUnwrapIntegrity) {
+			t.Fatalf("expect ErrKeyUnwrapIntegrity, got %v", err)
+		}
+	}
+	if _, err := AESKeyWrap(make([]byte, 32), make([]byte, 12)); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestSetKeyWrap(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetKeyWrap("aes-gcm-siv"); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := f.SetRsaScheme(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetKeyWrap(KeyWrapAESKW); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("data")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if w := layout.Metadata().Recipients[0].KeyWrap; w != KeyWrapAESKW {
+		t.Fatalf("unexpected key wrap %q", w)
+	}
+	if out, err := testDecryptRsaFile(t, path, pri); err != nil || !bytes.Equal(out, plaintext) {
+		t.Fatalf("bad: %q, err: %v", out, err)
+	}
+}
@@ -0,0 +1,59 @@
+package fortifier
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+)
+
+// NewFortifierWithRsaKeyring makes a Fortifier recovering the cipher key with
+// the first of the private keys that unwraps it.
+func NewFortifierWithRsaKeyring(verbose bool, meta *Metadata, keys [][]byte) *Fortifier {
+	f := NewFortifierWithRsa(verbose, meta, nil)
+	f.keyring = keys
+	return f
+}
+
+// mayRecoverWith reports whether the private key may unwrap the cipher key for
+// one of the RSA recipients: one embedding a public key the private key
+// matches, see MatchesPublicKey, or one shortlisted by mayRecover.
+func (m *Metadata) mayRecoverWith(k crypto.PrivateKey) bool {
+	signer, ok := k.(crypto.Signer)
+	if !ok {
+		return true
+	}
+	for _, r := range m.rsaRecipients() {
+		if pub, err := r.EmbeddedPublicKey(); err == nil && pub != nil && MatchesPublicKey(k, pub) {
+			return true
+		}
+	}
+	return m.mayRecover(signer.Public())
+}
+
+// setupKeyring tries the private keys of the keyring in turn. When the recipient
+// fingerprints are known, only the keys matching one of them are tried.
+func (f *Fortifier) setupKeyring() error {
+	var errs []error
+	for i, kb := range f.keyring {
+		f.key.bytes = kb
+		k, err := f.parsePrivateKey()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", i+1, err))
+			continue
+		}
+		if !f.meta.mayRecoverWith(k) {
+			errs = append(errs, fmt.Errorf("key %d: fingerprint mismatch", i+1))
+			continue
+		}
+		if err = f.recoverWithPrivateKey(k); err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", i+1, err))
+			continue
+		}
+		return nil
+	}
+	f.key.bytes = nil
+	if len(errs) == 0 {
+		return fmt.Errorf("%s: no private key provided", rsaFortifier)
+	}
+	return fmt.Errorf("%s: no private key recovers the cipher key -- %w", rsaFortifier, errors.Join(errs...))
+}
@@ -0,0 +1,66 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func testOtherRsaPem(t testing.TB) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestRsaKeyring(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("keyring payload")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	keyring := [][]byte{testOtherRsaPem(t), pri, testOtherRsaPem(t)}
+
+	legacy := sshFingerprint(&testRsaPrivateKey(t).PublicKey)
+	for name, fingerprint := range map[string]*string{"shortlisted": nil, "legacy": &legacy, "unlisted": new(string)} {
+		t.Run(name, func(t *testing.T) {
+			in, layout := testReadLayout(t, path)
+			defer func() { _ = in.Close() }()
+			if fingerprint != nil {
+				layout.Metadata().Recipients[0].Fingerprint = *fingerprint
+			}
+			f := NewFortifierWithRsaKeyring(false, layout.Metadata(), keyring)
+			var out bytes.Buffer
+			if err := NewDecrypter(CipherModeAes256CTR, f).Decrypt(in, &out, layout); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !bytes.Equal(plaintext, out.Bytes()) {
+				t.Fatalf("bad: %q", out.Bytes())
+			}
+		})
+	}
+}
+
+func TestRsaKeyringNoMatch(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	f := NewFortifierWithRsaKeyring(false, layout.Metadata(), [][]byte{testOtherRsaPem(t), []byte("garbage")})
+	err := f.SetupKey()
+	if err == nil {
+		t.Fatalf("expect error")
+	}
+	for _, s := range []string{"key 1: fingerprint mismatch", "key 2:"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expect %q in %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+const (
+	maxTags       = 64
+	maxLabelsSize = 4096
+)
+
+var ErrLabelsTooLarge = errors.New("description and tags too large")
+
+// SetDescription sets a free-form description of the fortified file. Like the
+// tags, it stays readable in the file head, even when the metadata is sealed,
+// and is authenticated by the head checksum.
+func (f *Fortifier) SetDescription(description string) error {
+	return f.setLabels(description, f.meta.Tags)
+}
+
+// SetTags sets labels, such as environment, owner or ticket, for inventory
+// tooling to read from the file head.
+func (f *Fortifier) SetTags(tags map[string]string) error {
+	return f.setLabels(f.meta.Description, tags)
+}
+
+func (f *Fortifier) setLabels(description string, tags map[string]string) error {
+	m := &Metadata{Description: description, Tags: maps.Clone(tags)}
+	if err := m.checkLabels(); err != nil {
+		return err
+	}
+	f.meta.Description, f.meta.Tags = m.Description, m.Tags
+	return nil
+}
+
+// checkLabels limits the number of tags and the total size of the description
+// and tags.
+func (m *Metadata) checkLabels() error {
+	if len(m.Tags) > maxTags {
+		return fmt.Errorf("%w: more than %d tags", ErrLabelsTooLarge, maxTags)
+	}
+	size := len(m.Description)
+	for k, v := range m.Tags {
+		if k == "" {
+			return errors.New("empty tag name")
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxLabelsSize {
+		return fmt.Errorf("%w: more than %d bytes", ErrLabelsTooLarge, maxLabelsSize)
+	}
+	return nil
+}
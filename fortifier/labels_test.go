@@ -0,0 +1,111 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testLabeledFortifier(t testing.TB, pub []byte) *Fortifier {
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetDescription("database credentials"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetTags(map[string]string{"env": "prod", "owner": "ops"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return f
+}
+
+func TestLabelsRoundTrip(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	tags := map[string]string{"env": "prod", "owner": "ops"}
+	for _, seal := range []bool{false, true} {
+		f := testLabeledFortifier(t, pub)
+		f.SetSealMetadata(seal)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("secret"))
+
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		if meta := layout.Metadata(); meta.Description != "database credentials" || !maps.Equal(meta.Tags, tags) {
+			t.Fatalf("seal %v: unexpected labels %q %v", seal, meta.Description, meta.Tags)
+		}
+		if _, err := testDecryptRsaFile(t, path, pri); err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+	}
+}
+
+func TestLabelsRotation(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	old := testLabeledFortifier(t, pub)
+	old.SetSealMetadata(true)
+	path := testEncryptFile(t, old, CipherModeAes256CTR, t.TempDir(), []byte("secret"))
+	rotate := func(to *Fortifier) *Metadata {
+		in, layout := testReadLayout(t, path)
+		defer func() { _ = in.Close() }()
+		out, err := os.Create(filepath.Join(t.TempDir(), "rotated.data"))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer func() { _ = out.Close() }()
+		if err = Reencrypt(NewFortifierWithRsa(false, layout.Metadata(), pri), to, layout, in, out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		in, layout = testReadLayout(t, out.Name())
+		_ = in.Close()
+		return layout.Metadata()
+	}
+
+	if meta := rotate(NewFortifierWithSss(false, false, testSssParts(t))); meta.Description != "database credentials" || meta.Tags["env"] != "prod" {
+		t.Fatalf("unexpected labels %q %v", meta.Description, meta.Tags)
+	}
+	to := NewFortifierWithRsa(false, nil, pub)
+	if err := to.SetDescription("rotated credentials"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if meta := rotate(to); meta.Description != "rotated credentials" || meta.Tags["owner"] != "ops" {
+		t.Fatalf("unexpected labels %q %v", meta.Description, meta.Tags)
+	}
+}
+
+func TestLabelsTampered(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, testLabeledFortifier(t, pub), CipherModeAes256CTR, t.TempDir(), []byte("secret"))
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tampered := bytes.Replace(content, []byte(`"env":"prod"`), []byte(`"env":"test"`), 1)
+	if bytes.Equal(tampered, content) {
+		t.Fatalf("tag not found in head")
+	}
+	if err = os.WriteFile(path, tampered, 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err = testDecryptRsaFile(t, path, pri); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestLabelsTooLarge(t *testing.T) {
+	f := NewFortifierWithRsa(false, nil, nil)
+	tags := make(map[string]string)
+	for i := 0; i <= maxTags; i++ {
+		tags[fmt.Sprint("tag", i)] = "value"
+	}
+	if err := f.SetTags(tags); !errors.Is(err, ErrLabelsTooLarge) {
+		t.Fatalf("expect ErrLabelsTooLarge, got %v", err)
+	}
+	if err := f.SetDescription(strings.Repeat("x", maxLabelsSize+1)); !errors.Is(err, ErrLabelsTooLarge) {
+		t.Fatalf("expect ErrLabelsTooLarge, got %v", err)
+	}
+	if err := f.SetTags(map[string]string{"": "value"}); err == nil {
+		t.Fatalf("expect error")
+	}
+}
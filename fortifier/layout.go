@@ -1,6 +1,7 @@
 package fortifier
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/binary"
 	"encoding/json"
@@ -13,6 +14,18 @@ import (
 
 const FileMagicNumber = uint32(0x40F1ED00)
 
+// Versions of the layout, in the low byte of the magic number
+const (
+	layoutVersion           = '1'
+	layoutVersionCompressed = '2' // metadata stored gzip compressed
+)
+
+var ErrNotFortified = errors.New("not a fortified input file")
+
+// ErrUnsupportedLayout means the head is of a layout version this package does
+// not read.
+var ErrUnsupportedLayout = errors.New("unsupported layout version")
+
 var layoutDataStart = "🔒fortified🔒"
 var layoutByteOrder = binary.BigEndian
 
@@ -22,12 +35,16 @@ type FileLayout struct {
 	dataLength     uint64
 	headChecksum   []byte
 	metadataLength uint32
-	metadataRaw    []byte
+	metadataRaw    []byte // as stored, gzip compressed in version 2
 	dataStartMark  []byte
 	nonce          []byte
 	//
 	version  rune
 	metadata *Metadata
+	// metadataPlain is the JSON of the metadata, which the head checksum covers
+	metadataPlain []byte
+	// compress stores the metadata gzip compressed, see SetCompressMetadata
+	compress bool
 }
 
 func (f *FileLayout) DataLength() uint64 {
@@ -46,17 +63,31 @@ func (f *FileLayout) String() string {
 	return fmt.Sprintf("\nMagic: %X\nVersion: %c\nChecksum: %X\nData Length: %d\n"+
 		"Head Checksum: %X\nMetadata Length: %d\nMetadata Raw: %s\nData Start Mark: %s\nNonce: %X\n",
 		f.magic, f.Version(), f.checksum, f.dataLength, f.headChecksum,
-		f.metadataLength, f.metadataRaw, f.dataStartMark, f.nonce,
+		f.metadataLength, f.metadataPlain, f.dataStartMark, f.nonce,
 	)
 }
 
+// HeadLength returns the length of the head as read or written, which is the
+// offset of the payload in the fortified file.
+func (f *FileLayout) HeadLength() int64 {
+	return int64(4 + len(f.checksum) + 8 + len(f.headChecksum) + 4 + len(f.metadataRaw) + len(f.dataStartMark) + len(f.nonce))
+}
+
+func isFortifiedMagic(magic uint32) bool {
+	return FileMagicNumber == (magic & 0x7FFFFF00)
+}
+
 func (f *FileLayout) ReadHeadIn(in io.Reader) (err error) {
 	endian := layoutByteOrder
 	if err = binary.Read(in, endian, &f.magic); err != nil {
 		return
 	}
-	if FileMagicNumber != (f.magic & 0x7FFFFF00) {
-		return errors.New("not a fortified input file")
+	if !isFortifiedMagic(f.magic) {
+		return ErrNotFortified
+	}
+	f.version = rune(0xFF & f.magic)
+	if f.version != layoutVersion && f.version != layoutVersionCompressed {
+		return fmt.Errorf("%w: %c", ErrUnsupportedLayout, f.version)
 	}
 	f.checksum = make([]byte, 32)
 	if err = binary.Read(in, endian, f.checksum); err != nil {
@@ -72,6 +103,9 @@ func (f *FileLayout) ReadHeadIn(in io.Reader) (err error) {
 	if err = binary.Read(in, endian, &f.metadataLength); err != nil {
 		return
 	}
+	if f.metadataLength > maxMetadataSize {
+		return fmt.Errorf("%w: %d bytes", ErrMetadataTooLarge, f.metadataLength)
+	}
 	f.metadataRaw = make([]byte, f.metadataLength)
 	if err = binary.Read(in, endian, f.metadataRaw); err != nil {
 		return
@@ -85,20 +119,62 @@ func (f *FileLayout) ReadHeadIn(in io.Reader) (err error) {
 		return
 	}
 	//
-	f.version = rune(0xFF & f.magic)
+	f.metadataPlain = f.metadataRaw
+	if f.version == layoutVersionCompressed {
+		if f.metadataPlain, err = decompressMetadata(f.metadataRaw); err != nil {
+			return
+		}
+	}
 	f.metadata = &Metadata{}
-	if err = json.Unmarshal(f.metadataRaw, f.metadata); err != nil {
+	if err = json.Unmarshal(f.metadataPlain, f.metadata); err != nil {
 		return
 	}
+	f.metadata.migrate()
+	if err = f.metadata.validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+	}
 	return
 }
 
+// MarshalBinary encodes the head as read by ReadHeadIn, so that it can be
+// stored apart from the payload.
+func (f *FileLayout) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	items := []any{f.magic, f.checksum, f.dataLength, f.headChecksum,
+		f.metadataLength, f.metadataRaw, f.dataStartMark, f.nonce}
+	for _, item := range items {
+		if err := binary.Write(&buf, layoutByteOrder, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a head encoded by MarshalBinary.
+func (f *FileLayout) UnmarshalBinary(b []byte) error {
+	r := bytes.NewReader(b)
+	if err := f.ReadHeadIn(r); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%d bytes trailing the head", r.Len())
+	}
+	return nil
+}
+
 func (f *FileLayout) WriteHeadOut(out io.Writer) (err error) {
-	f.magic = FileMagicNumber | '1'
-	f.version = rune(0xFF & f.magic)
-	if f.metadataRaw, err = json.Marshal(f.metadata); err != nil {
+	f.magic = FileMagicNumber | layoutVersion
+	if f.metadataPlain, err = json.Marshal(f.metadata); err != nil {
 		return
 	}
+	f.metadataRaw = f.metadataPlain
+	if f.compress {
+		f.magic = FileMagicNumber | layoutVersionCompressed
+		if f.metadataRaw, err = compressMetadata(f.metadataPlain); err != nil {
+			return
+		}
+	}
+	f.version = rune(0xFF & f.magic)
 	f.metadataLength = uint32(len(f.metadataRaw))
 	f.checksum = make([]byte, 32)     // place hold
 	f.dataLength = 0                  // place hold
@@ -161,12 +237,12 @@ func (f *FileLayout) makeChecksumHead(key *CipherKeyData) []byte {
 	dataLen := make([]byte, 8)
 	endian.PutUint64(dataLen, f.dataLength)
 	metaLen := make([]byte, 4)
-	endian.PutUint32(metaLen, f.metadataLength)
+	endian.PutUint32(metaLen, uint32(len(f.metadataPlain)))
 	check := key.NewSha256()
 	check.Write(magic)
 	check.Write(dataLen)
 	check.Write(metaLen)
-	check.Write(f.metadataRaw)
+	check.Write(f.metadataPlain)
 	check.Write(f.dataStartMark)
 	check.Write(f.nonce)
 	return check.Sum(nil)
@@ -0,0 +1,172 @@
+package fortifier
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidMetadata means metadata failed the checks of UnmarshalMetadata.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// UnmarshalMetadata decodes the JSON metadata of a fortified file from an
+// untrusted source, such as a head fetched from a remote backend, checking it
+// as ReadHeadIn does: on top of the types and timestamps JSON decoding checks,
+// the digests must be SHA-512 digests, the base64 fields must decode and the
+// sizes must be in range. The metadata is migrated as by ReadHeadIn. It fails
+// with ErrInvalidMetadata, never panics, whatever the input.
+func UnmarshalMetadata(b []byte) (*Metadata, error) {
+	if len(b) > maxMetadataSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrMetadataTooLarge, maxMetadataSize)
+	}
+	meta := &Metadata{}
+	if err := json.Unmarshal(b, meta); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+	}
+	meta.migrate()
+	if err := meta.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMetadata, err)
+	}
+	return meta, nil
+}
+
+// validate checks the fields of the metadata which JSON decoding takes as they
+// are, for ReadHeadIn, OpenMetadata and UnmarshalMetadata.
+func (m *Metadata) validate() error {
+	if err := m.applySuite(); err != nil {
+		return err
+	}
+	if err := m.checkPublicKeys(); err != nil {
+		return err
+	}
+	if err := m.checkLabels(); err != nil {
+		return err
+	}
+	if err := (&FileLayout{metadata: m}).Verify(); err != nil {
+		return err
+	}
+	if err := checkSegmentSize(m.Segment); err != nil {
+		return err
+	}
+	if err := checkChunkSize(m.Chunk); err != nil {
+		return err
+	}
+	if _, err := base64.URLEncoding.DecodeString(m.Sealed); err != nil {
+		return fmt.Errorf("sealed: %w", err)
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(m.KeyHintSalt); err != nil {
+		return fmt.Errorf("key hint salt: %w", err)
+	}
+	if m.AAD != "" {
+		if aad, err := base64.URLEncoding.DecodeString(m.AAD); err != nil || len(aad) != sha256.Size {
+			return errors.New("aad: not a base64 HMAC-SHA256")
+		}
+	}
+	if r := m.Sss; r != nil {
+		if err := checkDigest(r.Digest); err != nil {
+			return fmt.Errorf("sss recipient: %w", err)
+		}
+	}
+	for i, r := range m.Recipients {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rsa recipient %d: %w", i+1, err)
+		}
+	}
+	if j := m.Joint; j != nil {
+		for i, s := range j.Shares {
+			if s == nil {
+				return fmt.Errorf("joint share %d: missing", i+1)
+			}
+			if err := s.validate(); err != nil {
+				return fmt.Errorf("joint share %d: %w", i+1, err)
+			}
+		}
+	}
+	if r := m.Mnemonic; r != nil {
+		if err := checkDigest(r.Digest); err != nil {
+			return fmt.Errorf("mnemonic recipient: %w", err)
+		}
+	}
+	if r := m.Dpapi; r != nil {
+		if err := checkDigest(r.Digest); err != nil {
+			return fmt.Errorf("dpapi recipient: %w", err)
+		}
+		if _, err := base64.StdEncoding.DecodeString(r.Entropy); err != nil {
+			return fmt.Errorf("dpapi recipient: entropy: %w", err)
+		}
+	}
+	if r := m.Oidc; r != nil {
+		if err := checkDigest(r.Digest); err != nil {
+			return fmt.Errorf("oidc recipient: %w", err)
+		}
+	}
+	if p := m.Passphrase; p != nil {
+		if _, err := base64.StdEncoding.DecodeString(p.Salt); err != nil {
+			return fmt.Errorf("passphrase salt: %w", err)
+		}
+	}
+	if a := m.Age; a != nil {
+		if _, err := base64.URLEncoding.DecodeString(a.Payload); err != nil {
+			return fmt.Errorf("age payload: %w", err)
+		}
+	}
+	if x := m.Index; x != nil {
+		if _, err := base64.URLEncoding.DecodeString(x.Sealed); err != nil {
+			return fmt.Errorf("sealed index: %w", err)
+		}
+		for i, r := range x.Operators {
+			if r == nil {
+				return fmt.Errorf("operator %d: missing", i+1)
+			}
+			if err := r.validate(); err != nil {
+				return fmt.Errorf("operator %d: %w", i+1, err)
+			}
+		}
+	}
+	for i, p := range m.Parts {
+		if p == nil || p.Offset < 0 || p.Size < 0 {
+			return fmt.Errorf("part %d: missing or negative offset or size", i+1)
+		}
+	}
+	return nil
+}
+
+// validate checks the digests and wrapped keys of the RSA recipient.
+func (m *MetadataRsa) validate() error {
+	for _, d := range []string{m.Digest, m.CiphertextDigest, m.RedundantDigest} {
+		if d == "" {
+			continue
+		}
+		if err := checkDigest(d); err != nil {
+			return err
+		}
+	}
+	if m.Ciphertext == "" {
+		return fmt.Errorf("%w: missing ciphertext", ErrCorruptedRecipient)
+	}
+	if _, err := m.KeyWrap.normalize(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptedRecipient, err)
+	}
+	for _, w := range m.wrappedKeys() {
+		if _, _, err := w.decode(); err != nil {
+			return err
+		}
+	}
+	_, err := m.EmbeddedPublicKey()
+	return err
+}
+
+// checkDigest checks the digest is one of utils.ComputeDigest.
+func checkDigest(digest string) error {
+	decoded, err := base64.URLEncoding.DecodeString(digest)
+	if err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+	if len(decoded) != sha512.Size {
+		return fmt.Errorf("digest of %d bytes, not %d", len(decoded), sha512.Size)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testMetadataJSON returns the JSON metadata of files fortified for RSA
+// recipients, sealed or not, and with SSS.
+func testMetadataJSON(t testing.TB) [][]byte {
+	pub, _ := testRsaPem(t)
+	var out [][]byte
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		f.SetRedundantWrap(true)
+		f.SetEmbedPublicKey(true)
+		if err := f.SetTags(map[string]string{"env": "prod"}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		in, layout := testReadLayout(t, testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data")))
+		_ = in.Close()
+		out = append(out, layout.metadataPlain)
+	}
+	in, layout := testReadLayout(t, testEncryptFile(t, NewFortifierWithSss(false, false, testSssParts(t)), CipherModeAes256CTR, t.TempDir(), []byte("data")))
+	_ = in.Close()
+	return append(out, layout.metadataPlain)
+}
+
+func TestUnmarshalMetadata(t *testing.T) {
+	for _, b := range testMetadataJSON(t) {
+		meta, err := UnmarshalMetadata(b)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var expected Metadata
+		if err = json.Unmarshal(b, &expected); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		expected.migrate()
+		if actual, _ := json.Marshal(meta); !bytes.Equal(actual, testMarshal(t, &expected)) {
+			t.Fatalf("bad: %s", actual)
+		}
+		// every truncation is rejected
+		for i := range b {
+			if _, err = UnmarshalMetadata(b[:i]); !errors.Is(err, ErrInvalidMetadata) {
+				t.Fatalf("truncated at %d: expect invalid metadata, got %v", i, err)
+			}
+		}
+	}
+
+	rsa := testMetadataJSON(t)[0]
+	for name, edit := range map[string]func(m map[string]any){
+		"timestamp":  func(m map[string]any) { m["timestamp"] = "yesterday" },
+		"chunk type": func(m map[string]any) { m["chunk"] = "4096" },
+		"chunk size": func(m map[string]any) { m["chunk"] = 4095 },
+		"segment":    func(m map[string]any) { m["segment"] = -1 },
+		"tags":       func(m map[string]any) { m["tags"] = map[string]any{"note": strings.Repeat("x", maxLabelsSize)} },
+		"sealed":     func(m map[string]any) { m["sealed"] = "not base64!" },
+		"aad":        func(m map[string]any) { m["aad"] = "c2hvcnQ=" },
+		"suite":      func(m map[string]any) { m["suite"] = "ROT13" },
+		"digest": func(m map[string]any) {
+			m["recipients"].([]any)[0].(map[string]any)["digest"] = "c2hvcnQ="
+		},
+		"ciphertext": func(m map[string]any) {
+			m["recipients"].([]any)[0].(map[string]any)["ciphertext"] = "AAAA" + strings.Repeat("A", 1<<16)
+		},
+		"missing ciphertext": func(m map[string]any) {
+			delete(m["recipients"].([]any)[0].(map[string]any), "ciphertext")
+		},
+		"public key": func(m map[string]any) {
+			m["recipients"].([]any)[0].(map[string]any)["public_key"] = "AAAA"
+		},
+		"null recipient": func(m map[string]any) { m["recipients"] = []any{nil} },
+	} {
+		var m map[string]any
+		if err := json.Unmarshal(rsa, &m); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		edit(m)
+		if _, err := UnmarshalMetadata(testMarshal(t, m)); !errors.Is(err, ErrInvalidMetadata) {
+			t.Fatalf("%s: expect invalid metadata, got %v", name, err)
+		}
+	}
+
+	oversized := append(append([]byte(`{"description":"`), bytes.Repeat([]byte("x"), maxMetadataSize)...), `"}`...)
+	if _, err := UnmarshalMetadata(oversized); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Fatalf("expect metadata too large, got %v", err)
+	}
+}
+
+func TestReadHeadInValidates(t *testing.T) {
+	for name, edit := range map[string]func(m *Metadata){
+		"segment": func(m *Metadata) { m.Segment = 16 },
+		"sealed":  func(m *Metadata) { m.Sealed = "not base64!" },
+		"digest":  func(m *Metadata) { m.Recipients[0].Digest = "c2hvcnQ=" },
+	} {
+		meta, err := UnmarshalMetadata(testMetadataJSON(t)[0])
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		edit(meta)
+		var head bytes.Buffer
+		if err = (&FileLayout{metadata: meta}).WriteHeadOut(&head); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err = (&FileLayout{}).ReadHeadIn(&head); !errors.Is(err, ErrInvalidMetadata) {
+			t.Fatalf("%s: expect invalid metadata, got %v", name, err)
+		}
+	}
+}
+
+func testMarshal(t testing.TB, v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return b
+}
+
+func FuzzUnmarshalMetadata(f *testing.F) {
+	for _, b := range testMetadataJSON(f) {
+		f.Add(b)
+	}
+	f.Add([]byte(`{"key":"rsa","recipients":[null]}`))
+	f.Add([]byte(`{"key":"sss","sss":{"digest":""},"chunk":-1}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		meta, err := UnmarshalMetadata(b)
+		if err != nil {
+			return
+		}
+		// accepted metadata stays accepted once re-encoded
+		if _, err = UnmarshalMetadata(testMarshal(t, meta)); err != nil {
+			t.Fatalf("re-encoded metadata rejected: %v", err)
+		}
+	})
+}
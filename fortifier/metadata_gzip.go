@@ -0,0 +1,51 @@
+package fortifier
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxMetadataSize caps the decompressed metadata against gzip bombs.
+const maxMetadataSize = 16 * 1024 * 1024
+
+var ErrMetadataTooLarge = errors.New("metadata too large")
+
+// SetCompressMetadata enables storing the metadata gzip compressed, which
+// shrinks the head of small files, those with embedded public keys above all.
+// The head checksum still covers the uncompressed metadata.
+func (f *Fortifier) SetCompressMetadata(b bool) {
+	f.compressMeta = b
+}
+
+func compressMetadata(plain []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(plain); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressMetadata(stored []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(stored))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed metadata: %v", err)
+	}
+	plain, err := io.ReadAll(io.LimitReader(r, maxMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed metadata: %v", err)
+	}
+	if len(plain) > maxMetadataSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrMetadataTooLarge, maxMetadataSize)
+	}
+	return plain, nil
+}
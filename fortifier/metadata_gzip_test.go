@@ -0,0 +1,82 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCompressMetadata(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("tiny")
+	sizes := make(map[bool]int64)
+	for _, compress := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetEmbedPublicKey(true)
+		f.SetCompressMetadata(compress)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		stat, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		sizes[compress] = stat.Size()
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		if expect := map[bool]rune{false: '1', true: '2'}[compress]; layout.Version() != expect {
+			t.Fatalf("expect version %c, actual %c", expect, layout.Version())
+		}
+		if actual, err := testDecryptRsaFile(t, path, pri); err != nil || !bytes.Equal(actual, plaintext) {
+			t.Fatalf("compress %v bad: %q, err: %v", compress, actual, err)
+		}
+	}
+	if sizes[true] >= sizes[false] {
+		t.Fatalf("expect compressed metadata smaller: %v", sizes)
+	}
+}
+
+func TestCompressMetadataIdentical(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	var loaded []*FileLayout
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		written := &FileLayout{metadata: layout.Metadata(), compress: compress}
+		if err := written.WriteHeadOut(&buf); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		read := &FileLayout{}
+		if err := read.ReadHeadIn(&buf); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		loaded = append(loaded, read)
+	}
+	if !reflect.DeepEqual(loaded[0].Metadata(), loaded[1].Metadata()) {
+		t.Fatalf("expect identical metadata: %+v, %+v", loaded[0].Metadata(), loaded[1].Metadata())
+	}
+	if !bytes.Equal(loaded[0].metadataPlain, loaded[1].metadataPlain) || bytes.Equal(loaded[0].metadataRaw, loaded[1].metadataRaw) {
+		t.Fatalf("expect the same plain metadata stored differently")
+	}
+}
+
+func TestReadHeadInLimits(t *testing.T) {
+	head := func(version byte, metadataLength uint32) *bytes.Buffer {
+		var buf bytes.Buffer
+		_ = binary.Write(&buf, layoutByteOrder, FileMagicNumber|uint32(version))
+		buf.Write(make([]byte, 32+8+32))
+		_ = binary.Write(&buf, layoutByteOrder, metadataLength)
+		return &buf
+	}
+	// the length is rejected before the metadata is allocated or read
+	if err := (&FileLayout{}).ReadHeadIn(head(layoutVersion, math.MaxUint32)); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Fatalf("expect ErrMetadataTooLarge, got %v", err)
+	}
+	if err := (&FileLayout{}).ReadHeadIn(head('3', 2)); !errors.Is(err, ErrUnsupportedLayout) {
+		t.Fatalf("expect ErrUnsupportedLayout, got %v", err)
+	}
+}
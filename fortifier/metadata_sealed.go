@@ -0,0 +1,129 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const metadataSealLabel = "fortify sealed metadata"
+
+// SetSealMetadata enables encrypting the metadata under the cipher key, leaving
+// only the fields needed to recover the key, the labels and the producer readable
+// in the file head.
+func (f *Fortifier) SetSealMetadata(b bool) {
+	f.sealMeta = b
+}
+
+// sealMetadata returns the public locator metadata with the full metadata
+// encrypted into its Sealed field.
+func (f *Fortifier) sealMetadata() (outer *Metadata, err error) {
+	var inner []byte
+	if inner, err = json.Marshal(f.meta); err != nil {
+		return
+	}
+	var aead cipher.AEAD
+	if aead, err = f.newMetadataAEAD(); err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	sealed := aead.Seal(nonce, nonce, inner, nil)
+	outer = &Metadata{Key: f.meta.Key, Sealed: base64.URLEncoding.EncodeToString(sealed),
+		Description: f.meta.Description, Tags: f.meta.Tags, Producer: f.meta.Producer, Passphrase: f.meta.Passphrase,
+		KeyHintSalt: f.meta.KeyHintSalt, Age: f.meta.Age, Index: f.meta.Index}
+	if m := f.meta.Sss; m != nil {
+		outer.Sss = &MetadataSss{Digest: m.Digest}
+	}
+	if m := f.meta.Mnemonic; m != nil {
+		outer.Mnemonic = &MetadataMnemonic{Digest: m.Digest, Path: m.Path, Ciphertext: m.Ciphertext}
+	}
+	if m := f.meta.Dpapi; m != nil {
+		outer.Dpapi = &MetadataDpapi{Digest: m.Digest, Scope: m.Scope, Blob: m.Blob, Entropy: m.Entropy}
+	}
+	if m := f.meta.Oidc; m != nil {
+		outer.Oidc = &MetadataOidc{OidcIdentity: m.OidcIdentity, Digest: m.Digest, Ciphertext: m.Ciphertext,
+			Fingerprint: m.Fingerprint}
+	}
+	if m := f.meta.Rsa; m != nil {
+		outer.Rsa = m.locator()
+	}
+	for _, m := range f.meta.Recipients {
+		outer.Recipients = append(outer.Recipients, m.locator())
+	}
+	if j := f.meta.Joint; j != nil {
+		outer.Joint = &MetadataJoint{Scheme: j.Scheme}
+		for _, s := range j.Shares {
+			outer.Joint.Shares = append(outer.Joint.Shares, &MetadataJointShare{Name: s.Name, MetadataRsa: *s.locator()})
+		}
+	}
+	return
+}
+
+// OpenMetadata decrypts the sealed metadata of the layout, if any, and replaces
+// the layout metadata with it. It is a no-op for files without sealed metadata.
+func (f *Fortifier) OpenMetadata(layout *FileLayout) (err error) {
+	meta := layout.Metadata()
+	if err = f.checkKeyKind(meta); err != nil {
+		return
+	}
+	if meta == nil || len(meta.Sealed) == 0 {
+		return
+	}
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	var sealed []byte
+	if sealed, err = base64.URLEncoding.DecodeString(meta.Sealed); err != nil {
+		return fmt.Errorf("invalid sealed metadata: %v", err)
+	}
+	var aead cipher.AEAD
+	if aead, err = f.newMetadataAEAD(); err != nil {
+		return
+	}
+	size := aead.NonceSize()
+	if len(sealed) < size {
+		return errors.New("invalid sealed metadata: too short")
+	}
+	var inner []byte
+	if inner, err = aead.Open(nil, sealed[:size], sealed[size:], nil); err != nil {
+		return errors.New("invalid sealed metadata: authentication failed")
+	}
+	opened := &Metadata{}
+	if err = json.Unmarshal(inner, opened); err != nil {
+		return
+	}
+	opened.migrate()
+	if err = opened.validate(); err != nil {
+		return fmt.Errorf("sealed metadata: %w: %w", ErrInvalidMetadata, err)
+	}
+	if opened.Key != meta.Key {
+		return fmt.Errorf("sealed metadata key kind %q mismatches %q", opened.Key, meta.Key)
+	}
+	layout.metadata = opened
+	return
+}
+
+func (f *Fortifier) newMetadataAEAD() (cipher.AEAD, error) {
+	h := f.key.NewSha256()
+	h.Write([]byte(metadataSealLabel))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// locator returns the fields of the RSA recipient needed to unwrap the cipher key.
+func (m *MetadataRsa) locator() *MetadataRsa {
+	return &MetadataRsa{Digest: m.Digest, Ciphertext: m.Ciphertext,
+		CiphertextDigest: m.CiphertextDigest, Redundant: m.Redundant, RedundantDigest: m.RedundantDigest,
+		Fingerprint: m.Fingerprint, KeyHint: m.KeyHint, Scheme: m.Scheme, PublicKey: m.PublicKey,
+		KDFInfo: m.KDFInfo, KeyWrap: m.KeyWrap}
+}
@@ -0,0 +1,43 @@
+package fortifier
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrAlreadyFortified means the input to fortify is a fortified file itself,
+// without SetNested declaring the nesting intentional.
+var ErrAlreadyFortified = errors.New("input is already fortified")
+
+// SetNested declares the input a fortified file to be wrapped again as an
+// opaque payload, such as an RSA fortified file protected again for another
+// trust domain. The metadata records the nesting, so that the recovered
+// payload is known to be the inner container, which is then decrypted with the
+// key of the inner layer: the layers are peeled from the outside in. Without
+// it, an input starting with the fortified magic number or the armor header is
+// refused with ErrAlreadyFortified as processed twice by accident; with it, an
+// input that is not fortified is refused.
+func (f *Fortifier) SetNested(b bool) {
+	f.meta.Nested = b
+}
+
+// checkNesting peeks at the magic number or armor header of the input to tell
+// an intentionally nested container from one fortified twice by accident.
+func (f *Fortifier) checkNesting(in *bufio.Reader) error {
+	head, err := in.Peek(len(armorBegin))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	fortified := len(head) >= 4 && isFortifiedMagic(layoutByteOrder.Uint32(head)) ||
+		bytes.HasPrefix(head, []byte(armorBegin))
+	if fortified && !f.meta.Nested {
+		return fmt.Errorf("%w: set nesting to wrap it again", ErrAlreadyFortified)
+	}
+	if !fortified && f.meta.Nested {
+		return fmt.Errorf("%w: nesting requires a fortified input", ErrNotFortified)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNested(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	otherPri := testOtherRsaPem(t)
+	otherPub := testPublicPem(t, otherPri)
+	plaintext := []byte("two trust domains")
+	inner := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	container, err := os.ReadFile(inner)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	f := NewFortifierWithRsa(false, nil, otherPub)
+	f.SetNested(true)
+	f.SetSealMetadata(true)
+	outer := testEncryptFile(t, f, CipherModeAes256OFB, t.TempDir(), container)
+
+	in, layout := testReadLayout(t, outer)
+	_ = in.Close()
+	g := NewFortifierWithRsa(false, layout.Metadata(), otherPri)
+	if err = g.OpenMetadata(layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !layout.Metadata().Nested {
+		t.Fatalf("expect nested metadata")
+	}
+	peeled, err := testDecryptRsaFile(t, outer, otherPri)
+	if err != nil || !bytes.Equal(peeled, container) {
+		t.Fatalf("bad: outer layer, err: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "inner.data")
+	if err = os.WriteFile(path, peeled, 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	actual, err := testDecryptRsaFile(t, path, pri)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	if _, err = testDecryptRsaFile(t, outer, pri); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestNestedAccidental(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	dir := t.TempDir()
+	inner := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, dir, []byte("data"))
+	content, err := os.ReadFile(inner)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var armored bytes.Buffer
+	w := NewArmorWriter(&armored)
+	_, _ = w.Write(content)
+	_ = w.Close()
+	for _, c := range []struct {
+		input  string
+		nested bool
+		err    error
+	}{
+		{inner, false, ErrAlreadyFortified},
+		{testWriteFile(t, dir, "inner.asc", armored.Bytes()), false, ErrAlreadyFortified},
+		{testWriteFile(t, dir, "plain.data", []byte("plain")), true, ErrNotFortified},
+	} {
+		in, err := os.Open(c.input)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		out, err := os.Create(filepath.Join(t.TempDir(), "twice.data"))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetNested(c.nested)
+		if err = NewEncrypter(CipherModeAes256CTR, f).EncryptFile(in, out); !errors.Is(err, c.err) {
+			t.Fatalf("expect %v, got %v", c.err, err)
+		}
+		_, _ = in.Close(), out.Close()
+	}
+}
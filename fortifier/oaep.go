@@ -0,0 +1,122 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// wrapRawKey encrypts the raw cipher key with RSA-OAEP, SHA-256 as both the
+// hash and the MGF1 hash and an empty label, as openssl pkeyutl does with
+// -pkeyopt rsa_padding_mode:oaep -pkeyopt rsa_oaep_md:sha256 -pkeyopt rsa_mgf1_md:sha256.
+func wrapRawKey(pub *rsa.PublicKey, raw []byte, random io.Reader) ([]byte, error) {
+	return rsa.EncryptOAEP(sha256.New(), random, pub, raw, nil)
+}
+
+func unwrapRawKey(pri *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, pri, ciphertext, nil)
+}
+
+// EncryptOAEPWithSeed wraps raw for pub as the RSA cipher key kind does, but
+// with the given OAEP seed instead of a random one. It exists to reproduce
+// test vectors only; never use a fixed seed to fortify files.
+func EncryptOAEPWithSeed(pub *rsa.PublicKey, raw, seed []byte) ([]byte, error) {
+	if len(seed) != sha256.Size {
+		return nil, fmt.Errorf("OAEP seed must be %d bytes, not %d", sha256.Size, len(seed))
+	}
+	return encryptOAEPSeeded(pub, raw, bytes.NewReader(seed))
+}
+
+// SetOAEPSeedReader makes the RSA-OAEP wrapping of the cipher key read its
+// seed from r, such as the RNG of an HSM under test, rather than from
+// crypto/rand. Only the 32 bytes of the OAEP seed are read from r for each
+// wrapping, in recipient order, so the recipients are then wrapped one after
+// the other; the cipher key and the other schemes keep their own randomness.
+// A nil r restores the random seed.
+func (f *Fortifier) SetOAEPSeedReader(r io.Reader) {
+	f.oaepSeed = r
+}
+
+// wrapScheme wraps raw with the scheme, seeding OAEP from SetOAEPSeedReader and
+// deriving the RSA-KEM key with the info of SetKDFInfo if set.
+func (f *Fortifier) wrapScheme(scheme RsaScheme, pub *rsa.PublicKey, raw []byte) ([]byte, error) {
+	if f.oaepSeed != nil && (scheme == "" || scheme == RsaSchemeOAEP) {
+		return encryptOAEPSeeded(pub, raw, f.oaepSeed)
+	}
+	if scheme == RsaSchemeKEM && (f.kdfInfo != "" || f.keyWrap != "") {
+		return kemWrapRawKey(pub, raw, f.kdfInfo, f.keyWrap, rand.Reader)
+	}
+	return scheme.wrap(pub, raw, rand.Reader)
+}
+
+// encryptOAEPSeeded is the RSAES-OAEP encryption of RFC 8017 with SHA-256 and
+// an empty label, as wrapRawKey, reading the seed from seedReader. The
+// standard library takes its seed from the random reader only as long as that
+// is allowed, so the encoding is done here.
+func encryptOAEPSeeded(pub *rsa.PublicKey, msg []byte, seedReader io.Reader) ([]byte, error) {
+	k := pub.Size()
+	if len(msg) > k-2*sha256.Size-2 {
+		return nil, rsa.ErrMessageTooLong
+	}
+	em := make([]byte, k)
+	seed, db := em[1:1+sha256.Size], em[1+sha256.Size:]
+	lHash := sha256.Sum256(nil)
+	copy(db, lHash[:])
+	db[len(db)-len(msg)-1] = 1
+	copy(db[len(db)-len(msg):], msg)
+	if _, err := io.ReadFull(seedReader, seed); err != nil {
+		return nil, fmt.Errorf("OAEP seed: %w", err)
+	}
+	mgf1XOR(db, seed)
+	mgf1XOR(seed, db)
+	m := new(big.Int).SetBytes(em)
+	c := m.Exp(m, big.NewInt(int64(pub.E)), pub.N)
+	return c.FillBytes(em), nil
+}
+
+// mgf1XOR XORs out with the MGF1-SHA256 mask generated from seed.
+func mgf1XOR(out, seed []byte) {
+	var counter [4]byte
+	for done := 0; done < len(out); {
+		h := sha256.New()
+		h.Write(seed)
+		h.Write(counter[:])
+		for _, b := range h.Sum(nil) {
+			if done == len(out) {
+				break
+			}
+			out[done] ^= b
+			done++
+		}
+		for i := 3; i >= 0; i-- {
+			if counter[i]++; counter[i] != 0 {
+				break
+			}
+		}
+	}
+}
+
+// VerifyVector checks that wrapping raw for the public key of pri with the OAEP
+// seed reproduces ciphertext exactly, and that ciphertext unwraps to raw.
+func VerifyVector(pri *rsa.PrivateKey, raw, seed, ciphertext []byte) error {
+	actual, err := EncryptOAEPWithSeed(&pri.PublicKey, raw, seed)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(actual, ciphertext) {
+		return errors.New("ciphertext mismatch")
+	}
+	var unwrapped []byte
+	if unwrapped, err = unwrapRawKey(pri, ciphertext); err != nil {
+		return fmt.Errorf("unwrapping ciphertext failed. %v", err)
+	}
+	if !bytes.Equal(unwrapped, raw) {
+		return errors.New("unwrapped raw mismatch")
+	}
+	return nil
+}
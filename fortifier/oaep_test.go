@@ -0,0 +1,169 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+// The vector in testdata/oaep was generated with OpenSSL 3:
+//
+//	openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048 -out private.pem
+//	openssl pkey -in private.pem -pubout -out public.pem
+//	openssl pkeyutl -encrypt -pubin -inkey public.pem -pkeyopt rsa_padding_mode:oaep \
+//	  -pkeyopt rsa_oaep_md:sha256 -pkeyopt rsa_mgf1_md:sha256 -in raw.bin -out ciphertext.bin
+func testOaepVector(t testing.TB) (pri *rsa.PrivateKey, raw, ciphertext []byte) {
+	read := func(name string) []byte {
+		b, err := os.ReadFile("testdata/oaep/" + name)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return b
+	}
+	block, _ := pem.Decode(read("private.pem"))
+	if block == nil {
+		t.Fatalf("no pem block")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return k.(*rsa.PrivateKey), read("raw.bin"), read("ciphertext.bin")
+}
+
+// testOaepSeed recovers the OAEP seed OpenSSL chose by undoing the RSA
+// permutation and the MGF1 mask of the encoded message.
+func testOaepSeed(pri *rsa.PrivateKey, ciphertext []byte) []byte {
+	m := new(big.Int).Exp(new(big.Int).SetBytes(ciphertext), pri.D, pri.N)
+	em := m.FillBytes(make([]byte, pri.Size()))
+	maskedSeed, maskedDB := em[1:1+sha256.Size], em[1+sha256.Size:]
+	h := sha256.New()
+	h.Write(maskedDB)
+	h.Write([]byte{0, 0, 0, 0})
+	seed := h.Sum(nil)
+	for i := range seed {
+		seed[i] ^= maskedSeed[i]
+	}
+	return seed
+}
+
+func TestVerifyVectorOpenSSL(t *testing.T) {
+	pri, raw, ciphertext := testOaepVector(t)
+	seed := testOaepSeed(pri, ciphertext)
+	if err := VerifyVector(pri, raw, seed, ciphertext); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other := bytes.Repeat([]byte{7}, sha256.Size)
+	if err := VerifyVector(pri, raw, other, ciphertext); err == nil {
+		t.Fatalf("expect error")
+	}
+	if _, err := EncryptOAEPWithSeed(&pri.PublicKey, raw, seed[1:]); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestRsaRecoversOpenSSLCiphertext(t *testing.T) {
+	pri, raw, ciphertext := testOaepVector(t)
+	f := NewFortifierWithRsa(false, &Metadata{Rsa: &MetadataRsa{
+		Digest:     utils.ComputeDigest(raw),
+		Ciphertext: base64.URLEncoding.EncodeToString(ciphertext),
+	}}, nil)
+	if err := f.setupRsaPrivateKey(pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(f.key.raw, raw) {
+		t.Fatalf("raw mismatch")
+	}
+}
+
+func TestOAEPSeedReader(t *testing.T) {
+	pri, raw, _ := testOaepVector(t)
+	seed := bytes.Repeat([]byte{7}, sha256.Size)
+	wrap := func(seedReader io.Reader) []byte {
+		f := NewFortifierWithRsa(false, nil, nil)
+		f.SetOAEPSeedReader(seedReader)
+		m, err := f.wrapRsa(&pri.PublicKey, raw, "")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ciphertext, err := base64.URLEncoding.DecodeString(m.Ciphertext)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return ciphertext
+	}
+	ciphertext := wrap(bytes.NewReader(seed))
+	if !bytes.Equal(ciphertext, wrap(bytes.NewReader(seed))) {
+		t.Fatalf("ciphertext not reproducible")
+	}
+	if err := VerifyVector(pri, raw, seed, ciphertext); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Equal(ciphertext, wrap(nil)) {
+		t.Fatalf("expect random seed")
+	}
+	f := NewFortifierWithRsa(false, nil, nil)
+	f.SetOAEPSeedReader(bytes.NewReader(seed[1:]))
+	if _, err := f.wrapRsa(&pri.PublicKey, raw, ""); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestOAEPSeedReaderRecipients(t *testing.T) {
+	defer SetConcurrency(0)
+	SetConcurrency(8)
+	pub, _ := testRsaPem(t)
+	extras := make([][]byte, 6)
+	for i := range extras {
+		k, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		extras[i] = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	seeds := make([]byte, (1+len(extras))*sha256.Size)
+	for i := range seeds {
+		seeds[i] = byte(i)
+	}
+	raw := bytes.Repeat([]byte{3}, 32)
+	wrap := func() []string {
+		f := NewFortifierWithRsa(false, nil, pub)
+		for _, kb := range extras {
+			f.AddRecipient(kb)
+		}
+		f.SetOAEPSeedReader(bytes.NewReader(seeds))
+		f.random = bytes.NewReader(raw)
+		if err := f.SetupKey(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var ciphertexts []string
+		for _, m := range f.meta.rsaRecipients() {
+			ciphertexts = append(ciphertexts, m.Ciphertext)
+		}
+		return ciphertexts
+	}
+	expect := wrap()
+	if len(expect) != 1+len(extras) {
+		t.Fatalf("unexpected recipients: %d", len(expect))
+	}
+	for range 3 {
+		if actual := wrap(); !slices.Equal(actual, expect) {
+			t.Fatalf("ciphertexts not reproducible")
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package fortifier
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+)
+
+const partChecksumLabel = "fortify part"
+
+var ErrPartNotFound = errors.New("part not found")
+
+// MetadataPart is the entry of a named part in the index of a multi-part
+// container, locating its content in the plaintext of the payload.
+type MetadataPart struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	// Checksum is the HMAC-SHA256 under the cipher key of the name and content of the part
+	Checksum string `json:"checksum"`
+}
+
+// Part is a named input of EncryptParts.
+type Part struct {
+	Name string
+	Data io.ReadSeeker
+}
+
+// EncryptParts fortifies the parts as one container in AES-256-CTR mode: their
+// contents are concatenated into the payload and indexed in the metadata, which
+// the head checksum covers, so that ExtractPart can decrypt a single part. Each
+// part is read twice, to checksum it before the head is written.
+func (f *Fortifier) EncryptParts(parts []Part, out io.WriteSeeker) (err error) {
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	if f.stable {
+		parts = slices.SortedStableFunc(slices.Values(parts), func(a, b Part) int { return strings.Compare(a.Name, b.Name) })
+	}
+	var index []*MetadataPart
+	var readers []io.Reader
+	var offset int64
+	for _, p := range parts {
+		if p.Name == "" || slices.ContainsFunc(index, func(m *MetadataPart) bool { return m.Name == p.Name }) {
+			return fmt.Errorf("part names must be unique and not empty: %q", p.Name)
+		}
+		var start, size int64
+		if start, err = p.Data.Seek(0, io.SeekCurrent); err != nil {
+			return
+		}
+		check := f.newPartChecksum(p.Name)
+		if size, err = io.Copy(check, p.Data); err != nil {
+			return
+		}
+		if _, err = p.Data.Seek(start, io.SeekStart); err != nil {
+			return
+		}
+		index = append(index, &MetadataPart{Name: p.Name, Offset: offset, Size: size,
+			Checksum: base64.URLEncoding.EncodeToString(check.Sum(nil))})
+		readers = append(readers, p.Data)
+		offset += size
+	}
+	f.meta.Parts = index
+	f.meta.Mode = CipherModeAes256CTR
+	var layout *FileLayout
+	if layout, err = f.newLayout(); err != nil {
+		return
+	}
+	enc := &Aes256StreamEncrypter{f}
+	return enc.Encrypt(io.MultiReader(readers...), out, layout, CipherMode{Name: CipherModeAes256CTR, SteamMaker: cipher.NewCTR})
+}
+
+// ExtractPart decrypts the named part of a container made by EncryptParts into
+// w, reading only that part of the payload, the IV and ciphertext following the
+// head. f must be made with the metadata of the layout. The part is checked
+// against its checksum in the index rather than the file checksum.
+func (f *Fortifier) ExtractPart(layout *FileLayout, payload io.ReadSeeker, name string, w io.Writer) (err error) {
+	if err = f.AuthenticateMetadata(layout); err != nil {
+		return
+	}
+	meta := layout.Metadata()
+	if meta.Mode != CipherModeAes256CTR {
+		return fmt.Errorf("parts require cipher mode %s, not %s", CipherModeAes256CTR, meta.Mode)
+	}
+	i := slices.IndexFunc(meta.Parts, func(m *MetadataPart) bool { return m.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrPartNotFound, name)
+	}
+	part := meta.Parts[i]
+	if part.Offset < 0 || part.Size < 0 || uint64(part.Offset+part.Size) > layout.dataLength {
+		return fmt.Errorf("%w: part %s out of the payload", ErrPayloadAuthFailed, name)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err = io.ReadFull(payload, iv); err != nil {
+		return fmt.Errorf("%w: missing iv", ErrPayloadAuthFailed)
+	}
+	if _, err = payload.Seek(part.Offset, io.SeekCurrent); err != nil {
+		return
+	}
+	var stream cipher.Stream
+	if stream, err = f.newCTRStreamAt(iv, meta.Segment, part.Offset); err != nil {
+		return
+	}
+	check := f.newPartChecksum(name)
+	ow := bufio.NewWriterSize(w, defaultWriterBufferSize)
+	reader := cipher.StreamReader{S: stream, R: io.LimitReader(payload, part.Size)}
+	var cnt int64
+	if cnt, err = io.Copy(io.MultiWriter(check, ow), reader); err != nil {
+		return
+	}
+	if cnt != part.Size {
+		return fmt.Errorf("%w: expect part length is %d, not %d", ErrPayloadAuthFailed, part.Size, cnt)
+	}
+	if !hmac.Equal([]byte(part.Checksum), []byte(base64.URLEncoding.EncodeToString(check.Sum(nil)))) {
+		return fmt.Errorf("%w: invalid checksum of part %s", ErrPayloadAuthFailed, name)
+	}
+	return ow.Flush()
+}
+
+func (f *Fortifier) newPartChecksum(name string) hash.Hash {
+	check := f.key.NewSha256()
+	check.Write([]byte(partChecksumLabel))
+	check.Write([]byte(name))
+	check.Write([]byte{0})
+	return check
+}
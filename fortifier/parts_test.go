@@ -0,0 +1,103 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEncryptParts(t *testing.T, f *Fortifier, parts map[string][]byte, names ...string) string {
+	path := filepath.Join(t.TempDir(), "parts.enc")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	var inputs []Part
+	for _, name := range names {
+		inputs = append(inputs, Part{Name: name, Data: bytes.NewReader(parts[name])})
+	}
+	if err = f.EncryptParts(inputs, out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return path
+}
+
+func TestEncryptParts(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	parts := map[string][]byte{
+		"db.password": []byte("hunter2"),
+		"tls.key":     bytes.Repeat([]byte("0123456789abcdef-"), 4001),
+		"api.token":   []byte(strings.Repeat("token", 13)),
+	}
+	names := []string{"db.password", "tls.key", "api.token"}
+	// the second part spans the first segment boundary
+	for _, segment := range []int64{0, minSegmentSize} {
+		for _, seal := range []bool{false, true} {
+			f := NewFortifierWithRsa(false, nil, pub)
+			f.SetSealMetadata(seal)
+			if err := f.SetSegmentSize(segment); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			path := testEncryptParts(t, f, parts, names...)
+
+			for _, name := range names {
+				in, layout := testReadLayout(t, path)
+				var out bytes.Buffer
+				err := NewFortifierWithRsa(false, layout.Metadata(), pri).ExtractPart(layout, in, name, &out)
+				_ = in.Close()
+				if err != nil {
+					t.Fatalf("segment %d seal %v %s err: %v", segment, seal, name, err)
+				}
+				if !bytes.Equal(out.Bytes(), parts[name]) {
+					t.Fatalf("segment %d seal %v %s bad: %q", segment, seal, name, out.Bytes())
+				}
+			}
+			whole := bytes.Join([][]byte{parts[names[0]], parts[names[1]], parts[names[2]]}, nil)
+			if actual, err := testDecryptRsaFile(t, path, pri); err != nil || !bytes.Equal(actual, whole) {
+				t.Fatalf("bad: %q, err: %v", actual, err)
+			}
+		}
+	}
+}
+
+func TestExtractPartInvalid(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	parts := map[string][]byte{"a": []byte("first"), "b": []byte("second"), "c": []byte("third")}
+	path := testEncryptParts(t, NewFortifierWithRsa(false, nil, pub), parts, "a", "b", "c")
+	extract := func(name string) error {
+		in, layout := testReadLayout(t, path)
+		defer func() { _ = in.Close() }()
+		return NewFortifierWithRsa(false, layout.Metadata(), pri).ExtractPart(layout, in, name, &bytes.Buffer{})
+	}
+	if err := extract("d"); !errors.Is(err, ErrPartNotFound) {
+		t.Fatalf("expect ErrPartNotFound, got %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	content[len(content)-len("third")-1] ^= 1 // the last byte of part b
+	testWriteFile(t, filepath.Dir(path), filepath.Base(path), content)
+	if err = extract("b"); !errors.Is(err, ErrPayloadAuthFailed) {
+		t.Fatalf("expect ErrPayloadAuthFailed, got %v", err)
+	}
+	if err = extract("c"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	f := NewFortifierWithRsa(false, nil, pub)
+	out, err := os.Create(filepath.Join(t.TempDir(), "dup.enc"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	err = f.EncryptParts([]Part{{"a", bytes.NewReader(nil)}, {"a", bytes.NewReader(nil)}}, out)
+	if err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,113 @@
+package fortifier
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/deatil/go-cryptobin/pkcs8"
+	"github.com/deatil/go-cryptobin/pkcs8/pbes2"
+)
+
+// PassphraseProvider supplies the passphrase of an encrypted private key.
+type PassphraseProvider interface {
+	Passphrase(prompt string) ([]byte, error)
+}
+
+// PassphraseFunc adapts a function to a PassphraseProvider.
+type PassphraseFunc func(prompt string) ([]byte, error)
+
+func (fn PassphraseFunc) Passphrase(prompt string) ([]byte, error) {
+	return fn(prompt)
+}
+
+// TerminalPassphrase reads passphrases from the terminal without echo.
+var TerminalPassphrase PassphraseProvider = PassphraseFunc(readPassphrase)
+
+// SetPassphraseProvider replaces the terminal as the source of passphrases.
+func (f *Fortifier) SetPassphraseProvider(p PassphraseProvider) {
+	f.passphrase = p
+}
+
+func (f *Fortifier) enterPassphrase(prompt string) ([]byte, error) {
+	p := f.passphrase
+	if p == nil {
+		p = TerminalPassphrase
+	}
+	passphrase, err := p.Passphrase(prompt)
+	if err == nil {
+		f.recovered.Passphrase = true
+	}
+	return passphrase, err
+}
+
+var pkcs8Ciphers = map[string]pbes2.Cipher{
+	"aes128-cbc": pkcs8.AES128CBC,
+	"aes192-cbc": pkcs8.AES192CBC,
+	"aes256-cbc": pkcs8.AES256CBC,
+	"aes128-gcm": pkcs8.AES128GCM,
+	"aes256-gcm": pkcs8.AES256GCM,
+}
+
+// NewPkcs8Opts makes options to encrypt a PKCS #8 private key from the cipher
+// name, options: [aes128-cbc|aes192-cbc|aes256-cbc|aes128-gcm|aes256-gcm],
+// and the key derivation function name, options: [pbkdf2|scrypt].
+func NewPkcs8Opts(cipher, kdf string) (opts pkcs8.Opts, err error) {
+	var ok bool
+	if opts.Cipher, ok = pkcs8Ciphers[cipher]; !ok {
+		return opts, fmt.Errorf("unsupported PKCS #8 cipher: %s", cipher)
+	}
+	switch kdf {
+	case "pbkdf2":
+		kdfOpts := pkcs8.DefaultPBKDF2Opts
+		kdfOpts.HMACHash = pkcs8.GetHashFromName("SHA256")
+		opts.KDFOpts = kdfOpts
+	case "scrypt":
+		kdfOpts := pkcs8.DefaultScryptOpts
+		kdfOpts.CostParameter = 1 << 15
+		opts.KDFOpts = kdfOpts
+	default:
+		return opts, fmt.Errorf("unsupported key derivation function: %s", kdf)
+	}
+	return
+}
+
+// ChangePassphrase decrypts the first ENCRYPTED PRIVATE KEY block of the PEM
+// bytes with the old passphrase and encrypts it again with the new one.
+func ChangePassphrase(pemBytes []byte, oldPass, newPass PassphraseProvider, opts pkcs8.Opts) ([]byte, error) {
+	var block *pem.Block
+	for rest := pemBytes; ; {
+		if block, rest = pem.Decode(rest); block == nil || block.Type == "ENCRYPTED PRIVATE KEY" {
+			break
+		}
+	}
+	if block == nil {
+		return nil, errors.New("no ENCRYPTED PRIVATE KEY block found")
+	}
+	var passphrase secretBytes
+	passphrase, err := oldPass.Passphrase("Enter old passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	var der secretBytes
+	if der, err = pkcs8.DecryptPEMBlock(block, passphrase); err != nil {
+		return nil, errors.New("decrypt PKCS #8 private key failed")
+	}
+	defer clear(der)
+	if _, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+		return nil, errors.New("decrypt PKCS #8 private key failed")
+	}
+	if passphrase, err = newPass.Passphrase("Enter new passphrase: "); err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty new passphrase")
+	}
+	var encrypted *pem.Block
+	if encrypted, err = pkcs8.EncryptPEMBlock(rand.Reader, block.Type, der, passphrase, opts); err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(encrypted), nil
+}
@@ -0,0 +1,91 @@
+package fortifier
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	passphraseFactorKdf = "scrypt"
+	passphraseFactorN   = 1 << 15
+	passphraseFactorR   = 8
+	passphraseFactorP   = 1
+	// maxPassphraseFactorN bounds the work a crafted head can demand
+	maxPassphraseFactorN = 1 << 20
+)
+
+// MetadataPassphrase derives the passphrase share of a cipher key split by
+// SetPassphraseFactor. The RSA recipients then wrap only the key share, the
+// XOR of the cipher key and the passphrase share.
+type MetadataPassphrase struct {
+	Kdf  string `json:"kdf"`
+	Salt string `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// SetPassphraseFactor enables requiring both a private key and a passphrase to
+// recover the cipher key: it is split into a share wrapped for the RSA
+// recipients and a share derived from a passphrase, neither sufficing alone.
+// The passphrase is read from the passphrase provider on encryption and
+// recovery alike.
+func (f *Fortifier) SetPassphraseFactor(b bool) {
+	f.passFactor = b
+}
+
+// splitPassphraseFactor derives the passphrase share of raw from a new
+// passphrase and returns the key share for the RSA recipients.
+func (f *Fortifier) splitPassphraseFactor(raw []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	m := &MetadataPassphrase{Kdf: passphraseFactorKdf, Salt: base64.StdEncoding.EncodeToString(salt),
+		N: passphraseFactorN, R: passphraseFactorR, P: passphraseFactorP}
+	share, err := f.passphraseShare(m)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(share)
+	f.meta.Passphrase = m
+	keyShare := make([]byte, len(raw))
+	subtle.XORBytes(keyShare, raw, share)
+	return keyShare, nil
+}
+
+// combinePassphraseFactor recovers the cipher key from the key share unwrapped
+// for an RSA recipient. A wrong passphrase yields a key failing the head checksum.
+func (f *Fortifier) combinePassphraseFactor(keyShare []byte) ([]byte, error) {
+	share, err := f.passphraseShare(f.meta.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(share)
+	raw := make([]byte, len(keyShare))
+	subtle.XORBytes(raw, keyShare, share)
+	return raw, nil
+}
+
+func (f *Fortifier) passphraseShare(m *MetadataPassphrase) ([]byte, error) {
+	if m.Kdf != passphraseFactorKdf {
+		return nil, fmt.Errorf("unsupported passphrase kdf: %q", m.Kdf)
+	}
+	if m.N <= 1 || m.N > maxPassphraseFactorN || m.R <= 0 || m.P <= 0 {
+		return nil, fmt.Errorf("invalid passphrase kdf parameters: n=%d r=%d p=%d", m.N, m.R, m.P)
+	}
+	salt, err := base64.StdEncoding.DecodeString(m.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid passphrase salt: %v", err)
+	}
+	passphrase, err := f.enterPassphrase("Enter passphrase of the second factor: ")
+	if err != nil {
+		return nil, err
+	}
+	defer clear(passphrase)
+	return scrypt.Key(passphrase, salt, m.N, m.R, m.P, 32)
+}
@@ -0,0 +1,78 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPassphraseFactor(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("two factors")
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		f.SetPassphraseFactor(true)
+		f.SetPassphraseProvider(testPassphrase("second factor"))
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		if m := layout.Metadata().Passphrase; m == nil || m.Kdf != "scrypt" || m.Salt == "" {
+			t.Fatalf("seal %v: unexpected passphrase metadata %+v", seal, m)
+		}
+
+		// both factors
+		f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+		f.SetPassphraseProvider(testPassphrase("second factor"))
+		if err := f.AuthenticateMetadata(layout); err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+		// the key with a wrong passphrase
+		in, layout = testReadLayout(t, path)
+		_ = in.Close()
+		f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+		f.SetPassphraseProvider(testPassphrase("guess"))
+		if err := f.AuthenticateMetadata(layout); !errors.Is(err, ErrDecryptFailed) {
+			t.Fatalf("seal %v: expect ErrDecryptFailed, got %v", seal, err)
+		}
+		// the key without any passphrase
+		f = NewFortifierWithRsa(false, layout.Metadata(), pri)
+		f.SetPassphraseProvider(PassphraseFunc(func(string) ([]byte, error) { return nil, errors.New("no passphrase") }))
+		if err := f.AuthenticateMetadata(layout); !errors.Is(err, ErrDecryptFailed) {
+			t.Fatalf("seal %v: expect ErrDecryptFailed, got %v", seal, err)
+		}
+		// the passphrase without the key
+		f = NewFortifierWithRsa(false, layout.Metadata(), nil)
+		f.SetPassphraseProvider(testPassphrase("second factor"))
+		if err := f.AuthenticateMetadata(layout); !errors.Is(err, ErrNoRecipients) {
+			t.Fatalf("seal %v: expect ErrNoRecipients, got %v", seal, err)
+		}
+	}
+}
+
+func TestPassphraseFactorKeyShare(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetPassphraseFactor(true)
+	f.SetPassphraseProvider(testPassphrase("second factor"))
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+
+	// the key share alone, as unwrapped for the recipient, is not the cipher key
+	meta := *layout.Metadata()
+	meta.Passphrase = nil
+	g := NewFortifierWithRsa(false, &meta, pri)
+	if err := g.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Equal(g.key.raw, f.key.raw) || bytes.Equal(layout.makeChecksumHead(g.key), layout.headChecksum) {
+		t.Fatalf("expect the key share to differ from the cipher key")
+	}
+
+	s := NewFortifierWithSss(false, false, testSssParts(t))
+	s.SetPassphraseFactor(true)
+	if err := s.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,65 @@
+package fortifier
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/deatil/go-cryptobin/pkcs8"
+)
+
+func testPassphrase(s string) PassphraseProvider {
+	return PassphraseFunc(func(string) ([]byte, error) { return []byte(s), nil })
+}
+
+func testEncryptedRsaPem(t testing.TB, passphrase string) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(testRsaPrivateKey(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	block, err := pkcs8.EncryptPEMBlock(rand.Reader, "ENCRYPTED PRIVATE KEY", der, []byte(passphrase), pkcs8.DefaultOpts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return pem.EncodeToMemory(block)
+}
+
+func TestChangePassphrase(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	pri := testEncryptedRsaPem(t, "old secret")
+	opts, err := NewPkcs8Opts("aes256-gcm", "pbkdf2")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err = ChangePassphrase(pri, testPassphrase("wrong"), testPassphrase("new secret"), opts); err == nil {
+		t.Fatalf("expect error")
+	}
+	changed, err := ChangePassphrase(pri, testPassphrase("old secret"), testPassphrase("new secret"), opts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	f := NewFortifierWithRsa(false, layout.Metadata(), changed)
+	f.SetPassphraseProvider(testPassphrase("old secret"))
+	if err = f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+	f = NewFortifierWithRsa(false, layout.Metadata(), changed)
+	f.SetPassphraseProvider(testPassphrase("new secret"))
+	if err = f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestNewPkcs8OptsInvalid(t *testing.T) {
+	if _, err := NewPkcs8Opts("des-cbc", "pbkdf2"); err == nil {
+		t.Fatalf("expect error")
+	}
+	if _, err := NewPkcs8Opts("aes256-cbc", "md5"); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,39 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RecoverRaw recovers the cipher key from the head of a fortified file alone,
+// without reading its payload, once AuthenticateMetadata succeeds.
+func (f *Fortifier) RecoverRaw(layout *FileLayout) ([]byte, error) {
+	if err := f.AuthenticateMetadata(layout); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(f.key.raw), nil
+}
+
+// DecryptPayload decrypts the payload, the IV and ciphertext following the head
+// of a fortified file, with the cipher key recovered by RecoverRaw. The layout
+// is the head of the same file, as the payload is checked against it.
+func DecryptPayload(raw []byte, layout *FileLayout, payload io.Reader, w io.Writer) error {
+	meta := layout.Metadata()
+	if meta == nil {
+		return errors.New("layout without metadata")
+	}
+	f := &Fortifier{meta: &Metadata{Key: meta.Key}, key: &CipherKeyData{kind: meta.Key, raw: bytes.Clone(raw)},
+		aadChecked: true}
+	defer func() { clear(f.key.raw) }()
+	if err := f.OpenMetadata(layout); err != nil {
+		return err
+	}
+	mode := layout.Metadata().Mode
+	dec := NewDecrypter(mode, f)
+	if dec == nil {
+		return fmt.Errorf("unknown cipher mode name: %s", mode)
+	}
+	return dec.Decrypt(payload, w, layout)
+}
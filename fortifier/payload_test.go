@@ -0,0 +1,68 @@
+package fortifier
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestRecoverRawThenDecryptPayload(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := bytes.Repeat([]byte("payload stored apart "), 100)
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		path := testEncryptFile(t, f, CipherModeAes256OFB, t.TempDir(), plaintext)
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		head, err := layout.MarshalBinary()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !bytes.HasPrefix(content, head) {
+			t.Fatalf("head mismatch")
+		}
+		payload := content[len(head):]
+
+		stored := &FileLayout{}
+		if err = stored.UnmarshalBinary(head); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		raw, err := NewFortifierWithRsa(false, stored.Metadata(), pri).RecoverRaw(stored)
+		if err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+
+		stored = &FileLayout{}
+		if err = stored.UnmarshalBinary(head); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var out bytes.Buffer
+		if err = DecryptPayload(raw, stored, bytes.NewReader(payload), &out); err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+		if !bytes.Equal(out.Bytes(), plaintext) {
+			t.Fatalf("seal %v bad payload", seal)
+		}
+		raw[0] ^= 1
+		if err = DecryptPayload(raw, stored, bytes.NewReader(payload), &out); err == nil {
+			t.Fatalf("expect error")
+		}
+	}
+}
+
+func TestUnmarshalBinaryTrailing(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = (&FileLayout{}).UnmarshalBinary(content); err == nil {
+		t.Fatalf("expect error")
+	}
+}
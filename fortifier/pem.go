@@ -0,0 +1,53 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxPemBlocks = 64
+	maxPemBytes  = 1024 * 1024
+)
+
+var ErrTooManyPemBlocks = errors.New("too many pem blocks")
+
+// ErrTruncatedPem means a PEM header was found but its block is incomplete or
+// corrupt, as in a truncated file.
+var ErrTruncatedPem = errors.New("truncated or corrupt pem block")
+
+const pemBegin = "-----BEGIN "
+
+// decodePemFile decodes the PEM blocks of the key bytes.
+func (f *Fortifier) decodePemFile() ([]pem.Block, error) {
+	return decodePem(f.key.bytes)
+}
+
+// decodePem decodes the PEM blocks of kb, refusing inputs with more than
+// maxPemBlocks blocks or maxPemBytes decoded bytes, or with a PEM header left
+// over after the last block decoded. Other text around the blocks, such as a
+// comment after the key, is ignored.
+func decodePem(kb []byte) (blocks []pem.Block, err error) {
+	size := 0
+	for {
+		var blk *pem.Block
+		blk, kb = pem.Decode(kb)
+		if blk == nil || blk.Type == "" {
+			break
+		}
+		if len(blocks) == maxPemBlocks {
+			return nil, fmt.Errorf("%w: more than %d", ErrTooManyPemBlocks, maxPemBlocks)
+		}
+		if size += len(blk.Bytes); size > maxPemBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrTooManyPemBlocks, maxPemBytes)
+		}
+		blocks = append(blocks, *blk)
+	}
+	if i := bytes.Index(kb, []byte(pemBegin)); i >= 0 {
+		typ, _, _ := bytes.Cut(kb[i+len(pemBegin):], []byte("-----"))
+		return nil, fmt.Errorf("%w: %s", ErrTruncatedPem, bytes.TrimSpace(typ))
+	}
+	return
+}
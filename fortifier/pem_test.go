@@ -0,0 +1,88 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodePemFileTooManyBlocks(t *testing.T) {
+	var b bytes.Buffer
+	for i := 0; i < 5000; i++ {
+		_ = pem.Encode(&b, &pem.Block{Type: "X", Bytes: []byte{byte(i)}})
+	}
+	f := NewFortifierWithRsa(false, nil, b.Bytes())
+	if _, err := f.decodePemFile(); !errors.Is(err, ErrTooManyPemBlocks) {
+		t.Fatalf("expect ErrTooManyPemBlocks, got: %v", err)
+	}
+	if _, err := f.parseRsaPublicKey(); !errors.Is(err, ErrTooManyPemBlocks) {
+		t.Fatalf("expect ErrTooManyPemBlocks, got: %v", err)
+	}
+
+	b.Reset()
+	for i := 0; i < 3; i++ {
+		_ = pem.Encode(&b, &pem.Block{Type: "X", Bytes: make([]byte, maxPemBytes/2)})
+	}
+	f = NewFortifierWithRsa(false, nil, b.Bytes())
+	if _, err := f.decodePemFile(); !errors.Is(err, ErrTooManyPemBlocks) {
+		t.Fatalf("expect ErrTooManyPemBlocks, got: %v", err)
+	}
+}
+
+func TestDecodePemFileTruncated(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	key := testRsaPrivateKey(t)
+	full := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	for name, kb := range map[string][]byte{
+		"mid-block":    full[:len(full)/2],
+		"no end":       full[:bytes.Index(full, []byte("-----END"))],
+		"second block": append(bytes.Clone(pub), full[:len(full)/2]...),
+	} {
+		f := NewFortifierWithRsa(false, nil, kb)
+		_, err := f.parsePrivateKey()
+		if !errors.Is(err, ErrTruncatedPem) || !strings.HasSuffix(err.Error(), ": RSA PRIVATE KEY") {
+			t.Fatalf("%s: expect ErrTruncatedPem, got: %v", name, err)
+		}
+	}
+
+	f := NewFortifierWithRsa(false, nil, []byte("no header at all"))
+	if _, err := f.parsePrivateKey(); err == nil || errors.Is(err, ErrTruncatedPem) {
+		t.Fatalf("expect another error, got: %v", err)
+	}
+	f = NewFortifierWithRsa(false, nil, full)
+	if _, err := f.parsePrivateKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestDecodePemTrailingText(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	for _, footer := range []string{
+		"\nThis key belongs to the backup service.\nRotate it yearly -- ops@example.com\n",
+		"trailing: no newline",
+		"\n\n\x00\x01\x02 binary junk",
+		"\n-----END PUBLIC KEY-----\n",
+	} {
+		kb := append(bytes.Clone(pub), footer...)
+		blocks, err := decodePem(kb)
+		if err != nil || len(blocks) != 1 || blocks[0].Type != "PUBLIC KEY" {
+			t.Fatalf("%q: unexpected blocks %d, err: %v", footer, len(blocks), err)
+		}
+		k, info, err := ParsePublicKey(kb)
+		if err != nil {
+			t.Fatalf("%q: err: %v", footer, err)
+		}
+		if info.Format != KeyFormatPKIX || !k.(interface{ Equal(crypto.PublicKey) bool }).Equal(&testRsaPrivateKey(t).PublicKey) {
+			t.Fatalf("%q: misclassified as %s", footer, info.Format)
+		}
+	}
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, append(bytes.Clone(pub), "\n-- the ops team\n"...)),
+		CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	if actual, err := testDecryptRsaFile(t, path, pri); err != nil || string(actual) != "data" {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+}
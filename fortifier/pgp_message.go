@@ -0,0 +1,130 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// RsaSchemePGP wraps the cipher key into an OpenPGP message for the RSA
+// recipient: a session key packet with a wildcard key ID, as gpg
+// --throw-keyids writes, then the cipher key as literal data encrypted and
+// integrity protected under AES-256. The ciphertext of the recipient entry is
+// the message armored as "BEGIN PGP MESSAGE" rather than base64, readable by
+// gpg --list-packets and recovered by gpg --decrypt with an OpenPGP key of the
+// same RSA key material.
+const RsaSchemePGP RsaScheme = "pgp"
+
+const pgpMessageType = "PGP MESSAGE"
+
+// pgpCreationTime stands in for the creation time of the OpenPGP key, which a
+// bare RSA key lacks. It goes into the key ID only, and the key ID is a wildcard.
+var pgpCreationTime = time.Unix(0, 0)
+
+func pgpWrapRawKey(pub *rsa.PublicKey, raw []byte, random io.Reader) ([]byte, error) {
+	config := &packet.Config{Rand: random, DefaultCipher: packet.CipherAES256}
+	session := make([]byte, packet.CipherAES256.KeySize())
+	if _, err := io.ReadFull(random, session); err != nil {
+		return nil, err
+	}
+	defer clear(session)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, pgpMessageType, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = packet.SerializeEncryptedKeyAEADwithHiddenOption(w, packet.NewRSAPublicKey(pgpCreationTime, pub),
+		packet.CipherAES256, false, session, true, config)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := packet.SerializeSymmetricallyEncrypted(w, packet.CipherAES256, false, packet.CipherSuite{}, session, config)
+	if err != nil {
+		return nil, err
+	}
+	literal, err := packet.SerializeLiteral(contents, true, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = literal.Write(raw); err != nil {
+		return nil, err
+	}
+	if err = literal.Close(); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func pgpUnwrapRawKey(pri *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	block, err := armor.Decode(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	if block.Type != pgpMessageType {
+		return nil, fmt.Errorf("pgp message: unexpected armor %s", block.Type)
+	}
+	packets := packet.NewReader(block.Body)
+	p, err := packets.Next()
+	if err != nil {
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	key, ok := p.(*packet.EncryptedKey)
+	if !ok {
+		return nil, fmt.Errorf("pgp message: unexpected %T, expect an encrypted session key", p)
+	}
+	if err = key.Decrypt(packet.NewRSAPrivateKey(pgpCreationTime, pri), nil); err != nil {
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	defer clear(key.Key)
+	if p, err = packets.Next(); err != nil {
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	data, ok := p.(*packet.SymmetricallyEncrypted)
+	if !ok {
+		return nil, fmt.Errorf("pgp message: unexpected %T, expect encrypted data", p)
+	}
+	// the legacy packet without MDC would let the integrity protection be stripped
+	if !data.IntegrityProtected {
+		return nil, errors.New("pgp message: encrypted data without integrity protection")
+	}
+	contents, err := data.Decrypt(key.CipherFunc, key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	if p, err = packet.Read(contents); err != nil {
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	literal, ok := p.(*packet.LiteralData)
+	if !ok {
+		return nil, fmt.Errorf("pgp message: unexpected %T, expect literal data", p)
+	}
+	raw, err := io.ReadAll(io.LimitReader(literal.Body, 1024))
+	if err == nil {
+		// the integrity of the data is checked once it is read to the end
+		_, err = io.Copy(io.Discard, contents)
+	}
+	if err == nil {
+		err = contents.Close()
+	}
+	if err != nil {
+		clear(raw)
+		return nil, fmt.Errorf("pgp message: %w", err)
+	}
+	return raw, nil
+}
+
+// isArmoredPGPMessage reports whether the ciphertext of a recipient entry is an
+// armored OpenPGP message of RsaSchemePGP rather than base64.
+func isArmoredPGPMessage(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, "-----BEGIN "+pgpMessageType+"-----")
+}
@@ -0,0 +1,115 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+func TestPGPMessage(t *testing.T) {
+	entity, err := openpgp.NewEntity("Alice", "", "alice@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoRSA, RSABits: 2048})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	key := entity.Subkeys[0].PrivateKey.PrivateKey.(*rsa.PrivateKey)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pri := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	f := NewFortifierWithRsa(false, nil, testPublicPem(t, pri))
+	if err = f.SetRsaScheme(RsaSchemePGP); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("inspectable with gpg")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	m := layout.Metadata().Recipients[0]
+	if m.Scheme != RsaSchemePGP || !strings.HasPrefix(m.Ciphertext, "-----BEGIN PGP MESSAGE-----") {
+		t.Fatalf("unexpected recipient: %+v", m)
+	}
+
+	// as gpg --decrypt does with the OpenPGP key of the recipient
+	block, err := armor.Decode(strings.NewReader(m.Ciphertext))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if utils.ComputeDigest(raw) != m.Digest {
+		t.Fatalf("openpgp recovered another key")
+	}
+
+	actual, err := testDecryptRsaFile(t, path, pri)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	if _, err = pgpUnwrapRawKey(testRsaPrivateKey(t), []byte(m.Ciphertext)); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestPGPMessageWithoutMDC(t *testing.T) {
+	pri := testRsaPrivateKey(t)
+	raw := bytes.Repeat([]byte{7}, 32)
+	session := bytes.Repeat([]byte{9}, packet.CipherAES256.KeySize())
+	var literal bytes.Buffer
+	w, err := packet.SerializeLiteral(nopWriteCloser{&literal}, true, "", 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, _ = w.Write(raw)
+	_ = w.Close()
+	// the legacy symmetrically encrypted data packet, tag 9, has no MDC
+	block, err := aes.NewCipher(session)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stream, prefix := packet.NewOCFBEncrypter(block, make([]byte, block.BlockSize()), packet.OCFBResync)
+	data := make([]byte, literal.Len())
+	stream.XORKeyStream(data, literal.Bytes())
+	var msg bytes.Buffer
+	aw, err := armor.Encode(&msg, pgpMessageType, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	err = packet.SerializeEncryptedKeyAEADwithHiddenOption(aw, packet.NewRSAPublicKey(pgpCreationTime, &pri.PublicKey),
+		packet.CipherAES256, false, session, true, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, _ = aw.Write([]byte{0xC0 | 9, byte(len(prefix) + len(data))})
+	_, _ = aw.Write(prefix)
+	_, _ = aw.Write(data)
+	_ = aw.Close()
+	if unwrapped, err := pgpUnwrapRawKey(pri, msg.Bytes()); err == nil {
+		t.Fatalf("expect error, unwrapped %x", unwrapped)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,228 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const pkcs11Scheme = "pkcs11:"
+
+// ErrInvalidPkcs11URI means a PKCS #11 URI does not follow RFC 7512.
+var ErrInvalidPkcs11URI = errors.New("invalid pkcs11 uri")
+
+// Types of the objects a PKCS #11 URI may refer to
+const (
+	Pkcs11TypePublic    = "public"
+	Pkcs11TypePrivate   = "private"
+	Pkcs11TypeCert      = "cert"
+	Pkcs11TypeSecretKey = "secret-key"
+	Pkcs11TypeData      = "data"
+)
+
+// Pkcs11URI is a reference to a key of a PKCS #11 token, parsed by
+// ParsePkcs11URI. Empty fields match any value, as RFC 7512 specifies.
+type Pkcs11URI struct {
+	// Token, Manufacturer, Serial and Model identify the token
+	Token        string
+	Manufacturer string
+	Serial       string
+	Model        string
+	// LibraryManufacturer, LibraryDescription and LibraryVersion identify the module
+	LibraryManufacturer string
+	LibraryDescription  string
+	LibraryVersion      string
+	// SlotID, if not nil, SlotDescription and SlotManufacturer identify the slot
+	SlotID           *uint64
+	SlotDescription  string
+	SlotManufacturer string
+	// Object is the label of the object, ID its binary identifier
+	Object string
+	ID     []byte
+	Type   string
+	// PinSource and PinValue supply the PIN, at most one of them
+	PinSource string
+	PinValue  string
+	// ModuleName and ModulePath locate the PKCS #11 module to load
+	ModuleName string
+	ModulePath string
+	// Vendor holds the vendor specific attributes, named with an x- prefix
+	Vendor map[string]string
+}
+
+// ParsePkcs11URI parses the PKCS #11 URI of RFC 7512, such as
+// pkcs11:token=Fortify;object=backup;type=private?module-path=/usr/lib/softhsm/libsofthsm2.so,
+// so that configurations refer to keys of hardware tokens portably. Attribute
+// values are percent decoded. Unknown attributes, other than vendor specific
+// ones, and attributes given twice are refused.
+func ParsePkcs11URI(s string) (*Pkcs11URI, error) {
+	rest, ok := strings.CutPrefix(s, pkcs11Scheme)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s scheme", ErrInvalidPkcs11URI, pkcs11Scheme)
+	}
+	path, query, _ := strings.Cut(rest, "?")
+	u := &Pkcs11URI{}
+	seen := make(map[string]bool)
+	for _, part := range []struct {
+		attrs, sep string
+		set        func(name, value string) (bool, error)
+	}{
+		{path, ";", u.setPathAttr},
+		{query, "&", u.setQueryAttr},
+	} {
+		if part.attrs == "" {
+			continue
+		}
+		for _, attr := range strings.Split(part.attrs, part.sep) {
+			name, raw, ok := strings.Cut(attr, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("%w: attribute %q without a name or a value", ErrInvalidPkcs11URI, attr)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("%w: attribute %s given twice", ErrInvalidPkcs11URI, name)
+			}
+			seen[name] = true
+			value, err := url.PathUnescape(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: attribute %s -- %v", ErrInvalidPkcs11URI, name, err)
+			}
+			if vendor, ok := strings.CutPrefix(name, "x-"); ok && vendor != "" {
+				if u.Vendor == nil {
+					u.Vendor = make(map[string]string)
+				}
+				u.Vendor[name] = value
+				continue
+			}
+			if known, err := part.set(name, value); err != nil {
+				return nil, fmt.Errorf("%w: attribute %s -- %v", ErrInvalidPkcs11URI, name, err)
+			} else if !known {
+				return nil, fmt.Errorf("%w: unknown attribute %s", ErrInvalidPkcs11URI, name)
+			}
+		}
+	}
+	if u.PinSource != "" && u.PinValue != "" {
+		return nil, fmt.Errorf("%w: both pin-source and pin-value", ErrInvalidPkcs11URI)
+	}
+	return u, nil
+}
+
+func (u *Pkcs11URI) setPathAttr(name, value string) (bool, error) {
+	switch name {
+	case "token":
+		u.Token = value
+	case "manufacturer":
+		u.Manufacturer = value
+	case "serial":
+		u.Serial = value
+	case "model":
+		u.Model = value
+	case "library-manufacturer":
+		u.LibraryManufacturer = value
+	case "library-description":
+		u.LibraryDescription = value
+	case "library-version":
+		major, minor, dotted := strings.Cut(value, ".")
+		if !isVersionNumber(major) || dotted && !isVersionNumber(minor) {
+			return true, fmt.Errorf("version %q is not M or M.N", value)
+		}
+		u.LibraryVersion = value
+	case "slot-id":
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("slot id %q is not a decimal number", value)
+		}
+		u.SlotID = &id
+	case "slot-description":
+		u.SlotDescription = value
+	case "slot-manufacturer":
+		u.SlotManufacturer = value
+	case "object":
+		u.Object = value
+	case "id":
+		u.ID = []byte(value)
+	case "type":
+		switch value {
+		case Pkcs11TypePublic, Pkcs11TypePrivate, Pkcs11TypeCert, Pkcs11TypeSecretKey, Pkcs11TypeData:
+			u.Type = value
+		default:
+			return true, fmt.Errorf("unknown object type %q", value)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (u *Pkcs11URI) setQueryAttr(name, value string) (bool, error) {
+	switch name {
+	case "pin-source":
+		u.PinSource = value
+	case "pin-value":
+		u.PinValue = value
+	case "module-name":
+		u.ModuleName = value
+	case "module-path":
+		u.ModulePath = value
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// String returns the URI without the PIN value and the vendor specific
+// attributes, so that it can be logged.
+func (u *Pkcs11URI) String() string {
+	var path, query []string
+	add := func(attrs *[]string, name, value string) {
+		if value != "" {
+			*attrs = append(*attrs, name+"="+pkcs11Escape(value))
+		}
+	}
+	add(&path, "token", u.Token)
+	add(&path, "manufacturer", u.Manufacturer)
+	add(&path, "serial", u.Serial)
+	add(&path, "model", u.Model)
+	add(&path, "library-manufacturer", u.LibraryManufacturer)
+	add(&path, "library-description", u.LibraryDescription)
+	add(&path, "library-version", u.LibraryVersion)
+	if u.SlotID != nil {
+		add(&path, "slot-id", strconv.FormatUint(*u.SlotID, 10))
+	}
+	add(&path, "slot-description", u.SlotDescription)
+	add(&path, "slot-manufacturer", u.SlotManufacturer)
+	add(&path, "object", u.Object)
+	add(&path, "id", string(u.ID))
+	add(&path, "type", u.Type)
+	add(&query, "pin-source", u.PinSource)
+	add(&query, "module-name", u.ModuleName)
+	add(&query, "module-path", u.ModulePath)
+	s := pkcs11Scheme + strings.Join(path, ";")
+	if len(query) > 0 {
+		s += "?" + strings.Join(query, "&")
+	}
+	return s
+}
+
+// isVersionNumber reports whether v is a version number of a PKCS #11 module,
+// at most 255.
+func isVersionNumber(v string) bool {
+	_, err := strconv.ParseUint(v, 10, 8)
+	return err == nil
+}
+
+// pkcs11Escape percent encodes every byte of the value but the unreserved
+// characters of RFC 3986.
+func pkcs11Escape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package fortifier
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePkcs11URI(t *testing.T) {
+	slot := uint64(3)
+	for input, expect := range map[string]*Pkcs11URI{
+		"pkcs11:": {},
+		"pkcs11:token=Fortify;object=backup%20key;type=private?module-path=/usr/lib/softhsm/libsofthsm2.so": {
+			Token: "Fortify", Object: "backup key", Type: Pkcs11TypePrivate,
+			ModulePath: "/usr/lib/softhsm/libsofthsm2.so"},
+		"pkcs11:slot-id=3;id=%01%02%ff;library-version=2.40?pin-source=file:/run/pin&module-name=softhsm2": {
+			SlotID: &slot, ID: []byte{1, 2, 0xff}, LibraryVersion: "2.40", PinSource: "file:/run/pin",
+			ModuleName: "softhsm2"},
+		"pkcs11:manufacturer=SoftHSM%20project;serial=a1b2;model=SoftHSM%20v2;x-vendor=on?pin-value=1234": {
+			Manufacturer: "SoftHSM project", Serial: "a1b2", Model: "SoftHSM v2", PinValue: "1234",
+			Vendor: map[string]string{"x-vendor": "on"}},
+	} {
+		t.Run(input, func(t *testing.T) {
+			u, err := ParsePkcs11URI(input)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !reflect.DeepEqual(u, expect) {
+				t.Fatalf("expect %+v, actual %+v", expect, u)
+			}
+			again, err := ParsePkcs11URI(u.String())
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			expect.PinValue, expect.Vendor = "", nil
+			if !reflect.DeepEqual(again, expect) {
+				t.Fatalf("expect %+v, actual %+v from %s", expect, again, u)
+			}
+		})
+	}
+}
+
+func TestParsePkcs11URIInvalid(t *testing.T) {
+	for _, input := range []string{
+		"token=Fortify",
+		"pkcs11://token=Fortify",
+		"pkcs11:token",
+		"pkcs11:=Fortify",
+		"pkcs11:token=a;token=b",
+		"pkcs11:label=backup",
+		"pkcs11:type=secret",
+		"pkcs11:slot-id=first",
+		"pkcs11:library-version=2.x",
+		"pkcs11:object=%zz",
+		"pkcs11:object=backup?pin-value=1234&pin-source=file:/run/pin",
+		"pkcs11:object=backup?token=Fortify",
+	} {
+		if _, err := ParsePkcs11URI(input); !errors.Is(err, ErrInvalidPkcs11URI) {
+			t.Fatalf("%s: expect an invalid uri error, got %v", input, err)
+		}
+	}
+}
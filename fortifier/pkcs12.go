@@ -0,0 +1,33 @@
+package fortifier
+
+import (
+	"errors"
+
+	"github.com/deatil/go-cryptobin/pkcs12"
+)
+
+// looksLikeDer reports whether the key bytes may be a DER encoded structure,
+// such as a PKCS #12 (.p12/.pfx) bundle.
+func looksLikeDer(b []byte) bool {
+	return len(b) > 1 && b[0] == 0x30
+}
+
+// loadPkcs12 decodes the key bytes as a PKCS #12 bundle, trying an empty
+// password first and prompting for the passphrase if that is incorrect.
+func (f *Fortifier) loadPkcs12() (*pkcs12.PKCS12, error) {
+	return openPkcs12(f.key.bytes, PassphraseFunc(f.enterPassphrase))
+}
+
+// openPkcs12 decodes b as a PKCS #12 bundle with an empty password, asking p
+// for the passphrase if that is incorrect. A nil p allows no other password.
+func openPkcs12(b []byte, p PassphraseProvider) (*pkcs12.PKCS12, error) {
+	p12, err := pkcs12.LoadFromBytes(b, "")
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) && p != nil {
+		var passphrase secretBytes
+		if passphrase, err = p.Passphrase("Enter PKCS #12 passphrase: "); err != nil {
+			return nil, err
+		}
+		p12, err = pkcs12.LoadFromBytes(b, string(passphrase))
+	}
+	return p12, err
+}
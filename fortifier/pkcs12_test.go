@@ -0,0 +1,87 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deatil/go-cryptobin/pkcs12"
+)
+
+func testCertificate(t testing.TB, key crypto.Signer, usage x509.KeyUsage) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fortify test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     usage,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return cert
+}
+
+func testPkcs12(t testing.TB, key crypto.Signer, password string) []byte {
+	cert := testCertificate(t, key, x509.KeyUsageKeyEncipherment)
+	pfx, err := pkcs12.Encode(rand.Reader, key, cert, password)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return pfx
+}
+
+func TestPkcs12Rsa(t *testing.T) {
+	pfx := testPkcs12(t, testRsaPrivateKey(t), "pfx secret")
+	plaintext := []byte("pkcs12 payload")
+	f := NewFortifierWithRsa(false, nil, pfx)
+	f.SetPassphraseProvider(testPassphrase("pfx secret"))
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f = NewFortifierWithRsa(false, layout.Metadata(), pfx)
+	f.SetPassphraseProvider(testPassphrase("pfx secret"))
+	var out bytes.Buffer
+	if err := NewDecrypter(CipherModeAes256CTR, f).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plaintext) {
+		t.Fatalf("bad: %q", out.Bytes())
+	}
+
+	f = NewFortifierWithRsa(false, layout.Metadata(), pfx)
+	f.SetPassphraseProvider(testPassphrase("wrong"))
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestPkcs12Ecdsa(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pfx := testPkcs12(t, key, "")
+	f := NewFortifierWithRsa(false, &Metadata{Rsa: &MetadataRsa{}}, pfx)
+	if err = f.SetupKey(); err == nil || !strings.Contains(err.Error(), "ecdsa") {
+		t.Fatalf("expect error naming the key type, got: %v", err)
+	}
+	f = NewFortifierWithRsa(false, nil, pfx)
+	if _, err = f.parseRsaPublicKey(); err == nil || !strings.Contains(err.Error(), "ecdsa") {
+		t.Fatalf("expect error naming the key type, got: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package fortifier
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+var ErrPolicyViolation = errors.New("policy violation")
+
+// Policy rejects files which are valid but use legacy or weak parameters on
+// recovery. The zero Policy is permissive and the default.
+type Policy struct {
+	// MinRsaBits rejects RSA private keys with a shorter modulus
+	MinRsaBits int
+	// RequireDigest rejects RSA recipients of the older ciphertext-only format,
+	// whose recovered cipher key is not checked against a digest
+	RequireDigest bool
+	// RequireKeyEncipherment rejects, rather than warns about, recipients from
+	// certificates whose key usage does not permit key encipherment when encrypting
+	RequireKeyEncipherment bool
+}
+
+// StrictPolicy is the policy of security-conscious deployments. RSA-OAEP with
+// SHA-1, PKCS #1 v1.5 wrapping and AES-128 are never accepted, whatever the policy.
+var StrictPolicy = Policy{MinRsaBits: 2048, RequireDigest: true, RequireKeyEncipherment: true}
+
+// SetPolicy sets the policy checked when recovering the cipher key, and when
+// wrapping it for recipients from certificates.
+func (f *Fortifier) SetPolicy(p Policy) {
+	f.policy = p
+}
+
+// checkRsa checks the private key and the RSA recipients against the policy.
+func (p Policy) checkRsa(pri *rsa.PrivateKey, recipients []*MetadataRsa) error {
+	if bits := pri.N.BitLen(); bits < p.MinRsaBits {
+		return fmt.Errorf("%w: rsa key of %d bits, requiring at least %d", ErrPolicyViolation, bits, p.MinRsaBits)
+	}
+	return p.checkDigests(recipients)
+}
+
+// checkKeyUsage checks the certificate of a recipient key, if any, permits key
+// encipherment, only warning in verbose mode unless RequireKeyEncipherment is set.
+func (f *Fortifier) checkKeyUsage(info KeyInfo) error {
+	err := checkKeyEncipherment(info)
+	if err == nil {
+		return nil
+	}
+	if f.policy.RequireKeyEncipherment {
+		return fmt.Errorf("%w: %w", ErrPolicyViolation, err)
+	}
+	if f.verbose {
+		fmt.Printf("warning: %s: %s key %s: %v\n", rsaFortifier, info.Kind, info.Fingerprint, err)
+	}
+	return nil
+}
+
+// checkDigests checks the recipients against RequireDigest.
+func (p Policy) checkDigests(recipients []*MetadataRsa) error {
+	if p.RequireDigest {
+		for _, m := range recipients {
+			if m.Digest == "" {
+				return fmt.Errorf("%w: rsa recipient without digest", ErrPolicyViolation)
+			}
+		}
+	}
+	return nil
+}
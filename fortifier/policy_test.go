@@ -0,0 +1,46 @@
+package fortifier
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicyRsaBits(t *testing.T) {
+	pri := testOtherRsaPem(t)
+	pub := testPublicPem(t, pri)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+
+	if err := NewFortifierWithRsa(false, layout.Metadata(), pri).SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetPolicy(StrictPolicy)
+	if err := f.SetupKey(); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("expect ErrPolicyViolation, got %v", err)
+	}
+}
+
+func TestPolicyRequireDigest(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetPolicy(StrictPolicy)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	meta := layout.Metadata()
+	meta.Rsa, meta.Recipients = &MetadataRsa{Ciphertext: meta.Recipients[0].Ciphertext}, nil
+	if err := NewFortifierWithRsa(false, meta, pri).SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f = NewFortifierWithRsa(false, meta, pri)
+	f.SetPolicy(StrictPolicy)
+	if err := f.SetupKey(); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("expect ErrPolicyViolation, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package fortifier
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/deatil/go-cryptobin/pkcs12"
+	"github.com/deatil/go-cryptobin/pkcs8"
+	"golang.org/x/crypto/ssh"
+)
+
+// setupPrivateKey parses the private key and dispatches it to the recovery of
+// the cipher key kind matching its type.
+func (f *Fortifier) setupPrivateKey() error {
+	if len(f.keyChain) > 0 && f.privateKey == nil {
+		if err := f.loadKeyChain(); err != nil {
+			return err
+		}
+	}
+	if f.privateKey != nil {
+		return f.recoverWithPrivateKey(f.privateKey)
+	}
+	if len(f.keyring) > 0 {
+		return f.setupKeyring()
+	}
+	k, err := f.parsePrivateKey()
+	if err != nil {
+		return err
+	}
+	return f.recoverWithPrivateKey(k)
+}
+
+func (f *Fortifier) recoverWithPrivateKey(k crypto.PrivateKey) error {
+	switch key := k.(type) {
+	case *rsa.PrivateKey:
+		if f.key.kind != CipherKeyKindRSA {
+			return fmt.Errorf("rsa private key cannot recover cipher key kind %s", f.key.kind)
+		}
+		return f.setupRsaPrivateKey(key)
+	case ed25519.PrivateKey:
+		if f.key.kind != CipherKeyKindRSA {
+			return fmt.Errorf("ed25519 private key cannot recover cipher key kind %s", f.key.kind)
+		}
+		return f.setupEd25519PrivateKey(key)
+	default:
+		return fmt.Errorf("no cipher key kind supports %s private keys", privateKeyAlgorithm(k))
+	}
+}
+
+// parsePrivateKey parses the key bytes as a PKCS #12 bundle, a DER PKCS #8 or
+// PKCS #1 private key, an OpenSSH or PEM private key, or an encrypted PKCS #8
+// private key.
+func (f *Fortifier) parsePrivateKey() (crypto.PrivateKey, error) {
+	var k any
+	var err error
+	bytes, copied := normalizeKeyText(f.key.bytes)
+	if copied {
+		defer clear(bytes)
+	}
+	if looksLikeDer(bytes) {
+		var p12 *pkcs12.PKCS12
+		if p12, err = f.loadPkcs12(); err == nil {
+			if k, _, err = p12.GetPrivateKey(); err != nil {
+				return nil, errors.New("PKCS #12 private key missing")
+			}
+			return normalizePrivateKey(k), nil
+		} else if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, err
+		}
+		if k, err = x509.ParsePKCS8PrivateKey(bytes); err == nil {
+			return normalizePrivateKey(k), nil
+		}
+		if k, err = x509.ParsePKCS1PrivateKey(bytes); err == nil {
+			return k, nil
+		}
+	}
+	if k, err = ssh.ParseRawPrivateKey(bytes); err != nil {
+		var passphraseMissingError *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseMissingError) {
+			var passphrase secretBytes
+			if passphrase, err = f.enterPassphrase("Enter passphrase: "); err == nil {
+				k, err = ssh.ParseRawPrivateKeyWithPassphrase(bytes, passphrase)
+			}
+		}
+	}
+	if err != nil {
+		blocks, x := decodePem(bytes)
+		if x != nil {
+			return nil, x
+		}
+		if len(blocks) == 0 {
+			return nil, err
+		}
+		block := &blocks[0]
+		switch block.Type {
+		case "ENCRYPTED PRIVATE KEY":
+			var passphrase, decrypted secretBytes
+			if passphrase, err = f.enterPassphrase("Enter passphrase: "); err != nil {
+				return nil, err
+			}
+			decrypted, err = pkcs8.DecryptPEMBlock(block, passphrase)
+			if err != nil {
+				return nil, errors.New("decrypt PKCS #8 private key failed")
+			}
+			if k, err = x509.ParsePKCS8PrivateKey(decrypted); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizePrivateKey(k), nil
+}
+
+// normalizePrivateKey returns Ed25519 keys by value, as x509 does, since
+// ssh.ParseRawPrivateKey returns them by pointer.
+func normalizePrivateKey(k any) crypto.PrivateKey {
+	if key, ok := k.(*ed25519.PrivateKey); ok && key != nil {
+		return *key
+	}
+	return k
+}
+
+func privateKeyAlgorithm(k crypto.PrivateKey) string {
+	switch k.(type) {
+	case *rsa.PrivateKey:
+		return "rsa"
+	case *ecdsa.PrivateKey:
+		return "ecdsa"
+	case ed25519.PrivateKey:
+		return "ed25519"
+	case *dsa.PrivateKey:
+		return "dsa"
+	default:
+		return fmt.Sprint(reflect.TypeOf(k))
+	}
+}
@@ -0,0 +1,65 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const maxPrivateKeySize = 1024 * 1024
+
+var ErrPrivateKeyTooLarge = errors.New("private key too large")
+
+// LoadPrivateKey reads a private key of at most 1 MiB from r, detecting its
+// format as the key bytes of NewFortifierWithRsa are: PEM, OpenSSH, DER
+// PKCS #8 or PKCS #1, or PKCS #12. The parsed key then recovers the cipher key
+// in place of the key bytes. The bytes read are zeroized once parsed.
+func (f *Fortifier) LoadPrivateKey(r io.Reader) error {
+	buf, err := readSecret(r, maxPrivateKeySize)
+	defer clear(buf)
+	if err != nil {
+		return err
+	}
+	kept := f.key.bytes
+	f.key.bytes = buf
+	k, err := f.parsePrivateKey()
+	f.key.bytes = kept
+	if err != nil {
+		return err
+	}
+	f.privateKey = k
+	return nil
+}
+
+// readSecret reads at most limit bytes from r, clearing every buffer it
+// outgrows so that no stray copy of the content is left behind. No more than
+// limit+1 bytes are read or allocated to tell that r holds more.
+func readSecret(r io.Reader, limit int) ([]byte, error) {
+	r = io.LimitReader(r, int64(limit)+1)
+	buf := make([]byte, 0, min(4096, limit+1))
+	for {
+		if len(buf) == cap(buf) {
+			if len(buf) > limit {
+				clear(buf)
+				return nil, fmt.Errorf("%w: more than %d bytes", ErrPrivateKeyTooLarge, limit)
+			}
+			grown := make([]byte, len(buf), min(2*cap(buf), limit+1))
+			copy(grown, buf)
+			clear(buf)
+			buf = grown
+		}
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if errors.Is(err, io.EOF) {
+			if len(buf) > limit {
+				clear(buf)
+				return nil, fmt.Errorf("%w: more than %d bytes", ErrPrivateKeyTooLarge, limit)
+			}
+			return buf, nil
+		}
+		if err != nil {
+			clear(buf)
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testRecordingReader reads from r, recording the buffers it is given.
+type testRecordingReader struct {
+	r    io.Reader
+	bufs [][]byte
+}
+
+func (r *testRecordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p[:min(len(p), 1000)])
+	r.bufs = append(r.bufs, p[:n])
+	return n, err
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	pub, pri := testRsaPem(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	openssh, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	plaintext := []byte("piped key")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+
+	for name, input := range map[string][]byte{
+		"pem pkcs8": pri,
+		"pem pkcs1": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"openssh":   pem.EncodeToMemory(openssh),
+		"der pkcs8": pkcs8,
+		"der pkcs1": x509.MarshalPKCS1PrivateKey(key),
+		"pkcs12":    testPkcs12(t, key, ""),
+	} {
+		t.Run(name, func(t *testing.T) {
+			in, layout := testReadLayout(t, path)
+			defer func() { _ = in.Close() }()
+			f := NewFortifierWithRsa(false, layout.Metadata(), nil)
+			r := &testRecordingReader{r: bytes.NewReader(input)}
+			if err := f.LoadPrivateKey(r); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			for _, buf := range r.bufs {
+				if slices.ContainsFunc(buf, func(b byte) bool { return b != 0 }) {
+					t.Fatalf("expect the read buffers zeroized")
+				}
+			}
+			var out bytes.Buffer
+			if err := NewDecrypter(CipherModeAes256CTR, f).Decrypt(in, &out, layout); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), plaintext) {
+				t.Fatalf("bad: %q", out.Bytes())
+			}
+		})
+	}
+}
+
+func TestLoadPrivateKeyTooLarge(t *testing.T) {
+	f := NewFortifierWithRsa(false, nil, nil)
+	err := f.LoadPrivateKey(strings.NewReader(strings.Repeat("A", maxPrivateKeySize+1)))
+	if !errors.Is(err, ErrPrivateKeyTooLarge) {
+		t.Fatalf("expect ErrPrivateKeyTooLarge, got %v", err)
+	}
+	if err = f.LoadPrivateKey(strings.NewReader("not a key")); err == nil {
+		t.Fatalf("expect error")
+	}
+	// an endless input is read no further than the limit
+	endless := &countingReader{r: zeroReader{}}
+	if _, err = readSecret(endless, 100); !errors.Is(err, ErrPrivateKeyTooLarge) || endless.n != 101 {
+		t.Fatalf("expect ErrPrivateKeyTooLarge after 101 bytes, got %v after %d", err, endless.n)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
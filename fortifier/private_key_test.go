@@ -0,0 +1,44 @@
+package fortifier
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSetupPrivateKeyEd25519(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	openssh, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	inputs := map[string][]byte{
+		"pkcs8":   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		"openssh": pem.EncodeToMemory(openssh),
+	}
+	for name, input := range inputs {
+		f := NewFortifierWithRsa(false, &Metadata{Rsa: &MetadataRsa{}}, input)
+		k, err := f.parsePrivateKey()
+		if err != nil {
+			t.Fatalf("%s err: %v", name, err)
+		}
+		if _, ok := k.(ed25519.PrivateKey); !ok {
+			t.Fatalf("%s bad: %T", name, k)
+		}
+		if err = f.SetupKey(); err == nil || !strings.Contains(err.Error(), "ed25519") {
+			t.Fatalf("%s expect error naming ed25519, got: %v", name, err)
+		}
+	}
+}
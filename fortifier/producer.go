@@ -0,0 +1,20 @@
+package fortifier
+
+import "github.com/i3ash/fortify/pkg/build"
+
+// DefaultProducer names the tool and version recorded as the producer of the
+// files fortified without SetProducer. Programs embedding the package may
+// override it with their own name.
+var DefaultProducer = "fortify/" + build.Version
+
+// SetProducer overrides the producer recorded in the file head, e.g. to keep
+// the producer of a file being rotated. It is a support hint only: recovery
+// never depends on it.
+func (f *Fortifier) SetProducer(producer string) {
+	f.meta.Producer = producer
+}
+
+// Producer returns the producer of the file encrypted or decrypted last.
+func (f *Fortifier) Producer() string {
+	return f.meta.Producer
+}
@@ -0,0 +1,51 @@
+package fortifier
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProducer(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+		in, layout := testReadLayout(t, path)
+		if producer := layout.Metadata().Producer; producer != DefaultProducer {
+			t.Fatalf("seal %v: unexpected producer %q", seal, producer)
+		}
+		old := NewFortifierWithRsa(false, layout.Metadata(), pri)
+		var out bytes.Buffer
+		err := NewDecrypter(CipherModeAes256CTR, old).Decrypt(in, &out, layout)
+		_ = in.Close()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if old.Producer() != DefaultProducer {
+			t.Fatalf("seal %v: unexpected recovered producer %q", seal, old.Producer())
+		}
+	}
+}
+
+func TestProducerRotation(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetProducer("legacy-tool/0.9")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	old := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	var out bytes.Buffer
+	if err := NewDecrypter(CipherModeAes256CTR, old).Decrypt(in, &out, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rotated := NewFortifierWithSss(false, false, testSssParts(t))
+	rotated.SetProducer(old.Producer())
+	path = testEncryptFile(t, rotated, CipherModeAes256OFB, t.TempDir(), out.Bytes())
+	in, layout = testReadLayout(t, path)
+	_ = in.Close()
+	if producer := layout.Metadata().Producer; producer != "legacy-tool/0.9" {
+		t.Fatalf("unexpected producer %q", producer)
+	}
+}
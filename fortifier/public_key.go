@@ -0,0 +1,402 @@
+package fortifier
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	ssh2PublicKeyBegin = "---- BEGIN SSH2 PUBLIC KEY ----"
+	ssh2PublicKeyEnd   = "---- END SSH2 PUBLIC KEY ----"
+)
+
+// Formats of the representations a public key is parsed from
+const (
+	KeyFormatAuthorizedKey  = "authorized-key"
+	KeyFormatKnownHosts     = "known-hosts"
+	KeyFormatSSHCertificate = "ssh-certificate"
+	KeyFormatSSH2           = "ssh2"
+	KeyFormatPKCS1          = "pkcs1"
+	KeyFormatPKIX           = "pkix"
+	KeyFormatCertificate    = "certificate"
+	KeyFormatPKCS12         = "pkcs12"
+)
+
+// KeyInfo describes a public key parsed by ParsePublicKey.
+type KeyInfo struct {
+	Kind        string // key algorithm: rsa, ecdsa, ed25519 or dsa
+	Format      string // representation the key was first found in
+	Fingerprint string // fingerprint of the key whatever its format, see PublicKeyFingerprint
+	Comment     string // comment of an authorized key, known_hosts entry or SSH2 public key
+	// Options of an authorized key, such as command or no-pty, see AuthorizedKeyOptions
+	Options map[string]string
+	// KeyUsage and ExtKeyUsage of the X.509 certificate the key was found in, if any
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+	// Principals of the SSH certificate the key was found in, if any
+	Principals []string
+}
+
+// ParsePublicKey tries the known_hosts entry, authorized key, SSH2, PEM and PKCS #12
+// representations of bytes in order, after stripping a byte order mark and
+// surrounding whitespace and normalizing line endings. Representations
+// describing the same key are reconciled. Only PKCS #12 bundles with an empty password are supported.
+func ParsePublicKey(bytes []byte) (crypto.PublicKey, KeyInfo, error) {
+	return parsePublicKey(bytes, nil)
+}
+
+func parsePublicKey(bytes []byte, passphrase PassphraseProvider) (crypto.PublicKey, KeyInfo, error) {
+	bytes, _ = normalizeKeyText(bytes)
+	type candidate struct {
+		key     crypto.PublicKey
+		format  string
+		comment string
+		options map[string]string
+		cert    *x509.Certificate
+		// principals of an SSH certificate
+		principals []string
+	}
+	var found []candidate
+	var errs []error
+	collect := func(format, comment string) func(k any, err error) {
+		return func(k any, err error) {
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				found = append(found, candidate{key: k, format: format, comment: comment})
+			}
+		}
+	}
+	if marker, hosts, parsed, comment, _, err := ssh.ParseKnownHosts(bytes); err == nil && isKnownHosts(marker, hosts) {
+		if err = checkKnownHosts(marker, hosts); err != nil {
+			errs = append(errs, err)
+		} else {
+			collect(KeyFormatKnownHosts, comment)(cryptoPublicKey(parsed))
+		}
+	} else if parsed, comment, options, _, err := ssh.ParseAuthorizedKey(bytes); err == nil {
+		format := KeyFormatAuthorizedKey
+		cert, isCert := parsed.(*ssh.Certificate)
+		if isCert {
+			format = KeyFormatSSHCertificate
+		}
+		collect(format, comment)(cryptoPublicKey(parsed))
+		if len(options) > 0 && len(found) > 0 {
+			found[len(found)-1].options = AuthorizedKeyOptions(options)
+		}
+		if isCert && len(found) > 0 {
+			found[len(found)-1].principals = cert.ValidPrincipals
+		}
+	}
+	if strings.Contains(string(bytes), ssh2PublicKeyBegin) {
+		if parsed, comment, err := parseSSH2PublicKey(string(bytes)); err != nil {
+			errs = append(errs, err)
+		} else {
+			collect(KeyFormatSSH2, comment)(cryptoPublicKey(parsed))
+		}
+	}
+	blocks, err := decodePem(bytes)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	for _, block := range blocks {
+		switch block.Type {
+		case "RSA PUBLIC KEY":
+			if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+				errs = append(errs, fmt.Errorf("not public key in PKCS #1, ASN.1 DER form -- %v", err))
+			} else {
+				collect(KeyFormatPKCS1, "")(k, nil)
+			}
+		case "PUBLIC KEY":
+			if k, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				errs = append(errs, fmt.Errorf("error parsing PKCS#8 public key -- %v", err))
+			} else {
+				collect(KeyFormatPKIX, "")(k, nil)
+			}
+		case "CERTIFICATE":
+			if cert, err := x509.ParseCertificate(block.Bytes); err != nil {
+				errs = append(errs, fmt.Errorf("error parsing certificate -- %v", err))
+			} else {
+				collect(KeyFormatCertificate, "")(cert.PublicKey, nil)
+				found[len(found)-1].cert = cert
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unsupported key type %q", block.Type))
+		}
+	}
+	if len(found) == 0 && len(errs) == 0 && looksLikeDer(bytes) {
+		if p12, err := openPkcs12(bytes, passphrase); err != nil {
+			errs = append(errs, fmt.Errorf("error parsing PKCS #12 -- %v", err))
+		} else if cert, _, err := p12.GetCert(); err != nil {
+			errs = append(errs, fmt.Errorf("error parsing PKCS #12 certificate -- %v", err))
+		} else {
+			collect(KeyFormatPKCS12, "")(cert.PublicKey, nil)
+			found[len(found)-1].cert = cert
+		}
+	}
+	if len(found) == 0 {
+		if len(errs) == 0 {
+			return nil, KeyInfo{}, errors.New("no public key found")
+		}
+		return nil, KeyInfo{}, fmt.Errorf("no public key found -- %w", errors.Join(errs...))
+	}
+	first := found[0]
+	info := KeyInfo{Kind: publicKeyAlgorithm(first.key), Format: first.format}
+	for _, c := range found {
+		if k, ok := first.key.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(c.key) {
+			return nil, KeyInfo{}, errors.New("input contains different public keys")
+		}
+		if info.Comment == "" {
+			info.Comment = c.comment
+		}
+		if info.Options == nil {
+			info.Options = c.options
+		}
+		if c.cert != nil && info.KeyUsage == 0 && info.ExtKeyUsage == nil {
+			info.KeyUsage, info.ExtKeyUsage = c.cert.KeyUsage, c.cert.ExtKeyUsage
+		}
+		if info.Principals == nil {
+			info.Principals = c.principals
+		}
+	}
+	info.Fingerprint = PublicKeyFingerprint(first.key)
+	return first.key, info, nil
+}
+
+// PublicKeyFingerprint returns "SHA256:" followed by the unpadded base64 of the
+// SHA-256 digest of the DER SubjectPublicKeyInfo of the key, so that the same
+// key has the same fingerprint whichever format it was parsed from. It returns
+// an empty string if the key has no SubjectPublicKeyInfo.
+func PublicKeyFingerprint(k crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(k)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// sshFingerprint returns the OpenSSH SHA256 fingerprint of the key, recorded
+// as the recipient fingerprint by earlier versions.
+func sshFingerprint(k crypto.PublicKey) string {
+	pub, err := ssh.NewPublicKey(k)
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(pub)
+}
+
+// AuthorizedKeyOptions maps the options of an authorized key, as returned by
+// ssh.ParseAuthorizedKey, by name: command="..." to its unquoted value and flags
+// such as no-pty to an empty value. Values of repeated options, such as
+// permitopen, are joined with commas.
+func AuthorizedKeyOptions(options []string) map[string]string {
+	m := make(map[string]string, len(options))
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		if unquoted, ok := strings.CutPrefix(value, `"`); ok {
+			value = strings.ReplaceAll(strings.TrimSuffix(unquoted, `"`), `\"`, `"`)
+		}
+		if prev, ok := m[name]; ok && value != "" {
+			value = prev + "," + value
+		}
+		m[name] = value
+	}
+	return m
+}
+
+var ErrHashedKnownHost = errors.New("hashed known_hosts entry")
+
+// authorizedKeyFlags are the authorized_keys options without a value, which
+// would otherwise be taken for the host names of a known_hosts entry.
+var authorizedKeyFlags = map[string]bool{
+	"agent-forwarding": true, "cert-authority": true, "no-agent-forwarding": true,
+	"no-port-forwarding": true, "no-pty": true, "no-user-rc": true, "no-X11-forwarding": true,
+	"port-forwarding": true, "pty": true, "restrict": true, "user-rc": true, "X11-forwarding": true,
+}
+
+// isKnownHosts reports whether a line parsed by ssh.ParseKnownHosts is a
+// known_hosts entry rather than an authorized key with options.
+func isKnownHosts(marker string, hosts []string) bool {
+	if marker != "" {
+		return true
+	}
+	for _, host := range hosts {
+		if strings.HasPrefix(host, "|1|") {
+			return true
+		}
+		if strings.Contains(host, "=") || authorizedKeyFlags[host] {
+			return false
+		}
+	}
+	return len(hosts) > 0
+}
+
+func checkKnownHosts(marker string, hosts []string) error {
+	if marker != "" {
+		return fmt.Errorf("unsupported known_hosts marker @%s", marker)
+	}
+	for _, host := range hosts {
+		if strings.HasPrefix(host, "|1|") {
+			return fmt.Errorf("%w: provide the host key directly, e.g. the output of ssh-keyscan <host>", ErrHashedKnownHost)
+		}
+	}
+	return nil
+}
+
+func publicKeyAlgorithm(k crypto.PublicKey) string {
+	switch k.(type) {
+	case *rsa.PublicKey:
+		return "rsa"
+	case *ecdsa.PublicKey:
+		return "ecdsa"
+	case ed25519.PublicKey:
+		return "ed25519"
+	case *dsa.PublicKey:
+		return "dsa"
+	default:
+		return reflect.TypeOf(k).String()
+	}
+}
+
+func cryptoPublicKey(k ssh.PublicKey) (any, error) {
+	if cert, ok := k.(*ssh.Certificate); ok {
+		k = cert.Key
+	}
+	if ck, ok := k.(ssh.CryptoPublicKey); ok {
+		return ck.CryptoPublicKey(), nil
+	}
+	return nil, fmt.Errorf("unsupported ssh key type %q", k.Type())
+}
+
+// ErrKeyAlgorithm means a public key is not of the algorithm required.
+var ErrKeyAlgorithm = errors.New("unexpected key algorithm")
+
+func ParseSSH2PublicKey(keyData string) (ssh.PublicKey, error) {
+	k, _, err := parseSSH2PublicKey(keyData)
+	return k, err
+}
+
+// requireRsaPublicKey returns the key if it is an RSA key, or an error naming
+// the algorithm and format of the key described by info.
+func requireRsaPublicKey(k crypto.PublicKey, info KeyInfo) (*rsa.PublicKey, error) {
+	if pub, ok := k.(*rsa.PublicKey); ok {
+		return pub, nil
+	}
+	return nil, fmt.Errorf("%w: %s key in %s format, requiring rsa", ErrKeyAlgorithm, info.Kind, info.Format)
+}
+
+// ErrKeyUsage means the certificate of a recipient restricts its key to other
+// uses than key encipherment, such as signing.
+var ErrKeyUsage = errors.New("certificate does not permit key encipherment")
+
+// signingExtKeyUsages are the extended key usages of signing keys only.
+var signingExtKeyUsages = map[x509.ExtKeyUsage]bool{
+	x509.ExtKeyUsageCodeSigning:                    true,
+	x509.ExtKeyUsageTimeStamping:                   true,
+	x509.ExtKeyUsageOCSPSigning:                    true,
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: true,
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     true,
+}
+
+// checkKeyEncipherment checks that the certificate the key was found in, if
+// any, permits wrapping a cipher key for it: a KeyUsage must include
+// keyEncipherment, and an ExtKeyUsage must not restrict the key to signing.
+func checkKeyEncipherment(info KeyInfo) error {
+	if info.KeyUsage != 0 && info.KeyUsage&x509.KeyUsageKeyEncipherment == 0 {
+		return fmt.Errorf("%w: key usage lacks keyEncipherment", ErrKeyUsage)
+	}
+	if len(info.ExtKeyUsage) > 0 && !slices.ContainsFunc(info.ExtKeyUsage, func(u x509.ExtKeyUsage) bool {
+		return !signingExtKeyUsages[u]
+	}) {
+		return fmt.Errorf("%w: extended key usage restricted to signing", ErrKeyUsage)
+	}
+	return nil
+}
+
+// SSHToPEM converts the SSH public key, an authorized key, known_hosts entry,
+// SSH certificate or SSH2 public key, into the PEM of its SubjectPublicKeyInfo.
+// The comment and options of the SSH key are dropped.
+func SSHToPEM(sshKey []byte) ([]byte, error) {
+	k, info, err := ParsePublicKey(sshKey)
+	if err != nil {
+		return nil, err
+	}
+	switch info.Format {
+	case KeyFormatAuthorizedKey, KeyFormatKnownHosts, KeyFormatSSHCertificate, KeyFormatSSH2:
+	default:
+		return nil, fmt.Errorf("%s key in %s format, not an ssh public key", info.Kind, info.Format)
+	}
+	der, err := x509.MarshalPKIXPublicKey(k)
+	if err != nil {
+		return nil, fmt.Errorf("%s key -- %w", info.Kind, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// PEMToSSH converts the PEM public key, in PKCS #1 or PKIX form or as an X.509
+// certificate, into an authorized key line.
+func PEMToSSH(pemKey []byte) ([]byte, error) {
+	k, info, err := ParsePublicKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	switch info.Format {
+	case KeyFormatPKCS1, KeyFormatPKIX, KeyFormatCertificate:
+	default:
+		return nil, fmt.Errorf("%s key in %s format, not a pem public key", info.Kind, info.Format)
+	}
+	sshKey, err := ssh.NewPublicKey(k)
+	if err != nil {
+		return nil, fmt.Errorf("%s key -- %w", info.Kind, err)
+	}
+	return ssh.MarshalAuthorizedKey(sshKey), nil
+}
+
+func parseSSH2PublicKey(keyData string) (ssh.PublicKey, string, error) {
+	lines := strings.Split(keyData, "\n")
+	var base64Data, comment string
+	inKey := false
+	for _, line := range lines {
+		if line == ssh2PublicKeyBegin {
+			inKey = true
+			continue
+		}
+		if line == ssh2PublicKeyEnd {
+			break
+		}
+		if !inKey {
+			continue
+		}
+		if c, ok := strings.CutPrefix(line, "Comment:"); ok {
+			comment = strings.Trim(strings.TrimSpace(c), `"`)
+		} else {
+			base64Data += line
+		}
+	}
+	decodedData, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return nil, "", fmt.Errorf("base64 decoding error: %v", err)
+	}
+	if len(decodedData) == 0 {
+		return nil, "", errors.New("ssh2 public key without key blob")
+	}
+	k, err := ssh.ParsePublicKey(decodedData)
+	if err != nil {
+		return nil, "", fmt.Errorf("ssh2 public key -- %w", err)
+	}
+	return k, comment, nil
+}
@@ -0,0 +1,261 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	fingerprint := PublicKeyFingerprint(&key.PublicKey)
+	pkix, _ := testRsaPem(t)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: testCertificate(t, key, x509.KeyUsageKeyEncipherment).Raw})
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey))))
+	sshCert := ssh.MarshalAuthorizedKey(testSshCertificate(t, testSshSigner(t), time.Now().Add(time.Hour)))
+
+	for name, tc := range map[string]struct {
+		input   []byte
+		format  string
+		comment string
+		usage   x509.KeyUsage
+	}{
+		"authorized": {[]byte(authorized + " alice@example\n"), KeyFormatAuthorizedKey, "alice@example", 0},
+		"ssh-cert":   {sshCert, KeyFormatSSHCertificate, "", 0},
+		"ssh2":       {testSsh2PublicKey(t, &key.PublicKey), KeyFormatSSH2, "test key", 0},
+		"pkcs1":      {pkcs1, KeyFormatPKCS1, "", 0},
+		"pkix":       {pkix, KeyFormatPKIX, "", 0},
+		"cert":       {cert, KeyFormatCertificate, "", x509.KeyUsageKeyEncipherment},
+		"pkcs12":     {testPkcs12(t, key, ""), KeyFormatPKCS12, "", x509.KeyUsageKeyEncipherment},
+		"combined":   {append(testSsh2PublicKey(t, &key.PublicKey), pkix...), KeyFormatSSH2, "test key", 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pub, info, err := ParsePublicKey(tc.input)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !key.PublicKey.Equal(pub) {
+				t.Fatalf("public key mismatch")
+			}
+			expect := KeyInfo{Kind: "rsa", Format: tc.format, Fingerprint: fingerprint, Comment: tc.comment,
+				KeyUsage: tc.usage}
+			if !reflect.DeepEqual(info, expect) {
+				t.Fatalf("expect %+v, actual %+v", expect, info)
+			}
+		})
+	}
+}
+
+func TestPublicKeyFingerprint(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sum := sha256.Sum256(der)
+	expect := "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+	pkix, _ := testRsaPem(t)
+	for name, input := range map[string][]byte{
+		"authorized": ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey)),
+		"ssh2":       testSsh2PublicKey(t, &key.PublicKey),
+		"pem":        pkix,
+	} {
+		_, info, err := ParsePublicKey(input)
+		if err != nil {
+			t.Fatalf("%s err: %v", name, err)
+		}
+		if info.Fingerprint != expect {
+			t.Fatalf("%s: expect %s, actual %s", name, expect, info.Fingerprint)
+		}
+	}
+}
+
+func TestParsePublicKeyEd25519(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, info, err := ParsePublicKey(ssh.MarshalAuthorizedKey(testSshPublicKey(t, pub)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Kind != "ed25519" || info.Format != KeyFormatAuthorizedKey {
+		t.Fatalf("unexpected key info %+v", info)
+	}
+	if _, err = NewFortifierWithRsa(false, nil, ssh.MarshalAuthorizedKey(testSshPublicKey(t, pub))).parseRsaPublicKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestParseSSH2PublicKeyEcdsa(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ssh2 := testSsh2PublicKey(t, &key.PublicKey)
+	if k, err := ParseSSH2PublicKey(string(ssh2)); err != nil || k.Type() != ssh.KeyAlgoECDSA256 {
+		t.Fatalf("bad: %v, err: %v", k, err)
+	}
+	f := NewFortifierWithRsa(false, nil, ssh2)
+	err = f.SetupKey()
+	if !errors.Is(err, ErrKeyAlgorithm) || !strings.Contains(err.Error(), "ecdsa key in ssh2 format") {
+		t.Fatalf("expect ErrKeyAlgorithm naming ecdsa, got %v", err)
+	}
+	f = NewFortifierWithRsa(false, nil, nil)
+	f.AddRecipient(ssh2)
+	if err = f.SetupKey(); !errors.Is(err, ErrKeyAlgorithm) {
+		t.Fatalf("expect ErrKeyAlgorithm, got %v", err)
+	}
+	empty := ssh2PublicKeyBegin + "\n" + ssh2PublicKeyEnd + "\n"
+	if _, err = ParseSSH2PublicKey(empty); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestParsePublicKeyPkcs12Password(t *testing.T) {
+	if _, _, err := ParsePublicKey(testPkcs12(t, testRsaPrivateKey(t), "pfx secret")); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestParsePublicKeyKnownHosts(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	pub := testSshPublicKey(t, &key.PublicKey)
+	plain := knownhosts.Line([]string{"example.com", "10.0.0.1"}, pub) + " host key\n"
+	_, info, err := ParsePublicKey([]byte(plain))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Format != KeyFormatKnownHosts || info.Comment != "host key" {
+		t.Fatalf("unexpected key info %+v", info)
+	}
+	plaintext := []byte("host config")
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, []byte(plain)), CipherModeAes256CTR, t.TempDir(), plaintext)
+	_, pri := testRsaPem(t)
+	if actual, err := testDecryptRsaFile(t, path, pri); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+
+	hashed := knownhosts.Line([]string{knownhosts.HashHostname("example.com")}, pub)
+	if _, _, err = ParsePublicKey([]byte(hashed)); !errors.Is(err, ErrHashedKnownHost) {
+		t.Fatalf("expect ErrHashedKnownHost, got %v", err)
+	}
+
+	options := "no-pty,command=\"true\" " + string(ssh.MarshalAuthorizedKey(pub))
+	if _, info, err = ParsePublicKey([]byte(options)); err != nil || info.Format != KeyFormatAuthorizedKey {
+		t.Fatalf("unexpected key info %+v, err: %v", info, err)
+	}
+}
+
+func TestParsePublicKeyAuthorizedOptions(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey))))
+	line := `command="echo \"backup\" only",no-pty,permitopen="db:5432",permitopen="cache:6379" ` +
+		authorized + " backup@example\n"
+	pub, info, err := ParsePublicKey([]byte(line))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !key.PublicKey.Equal(pub) || info.Format != KeyFormatAuthorizedKey || info.Comment != "backup@example" {
+		t.Fatalf("unexpected key info %+v", info)
+	}
+	expect := map[string]string{"command": `echo "backup" only`, "no-pty": "", "permitopen": "db:5432,cache:6379"}
+	if !reflect.DeepEqual(info.Options, expect) {
+		t.Fatalf("expect options %v, actual %v", expect, info.Options)
+	}
+	if _, info, err = ParsePublicKey([]byte(authorized)); err != nil || info.Options != nil {
+		t.Fatalf("unexpected options %v, err: %v", info.Options, err)
+	}
+}
+
+func TestSSHToPEM(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	fingerprint := PublicKeyFingerprint(&key.PublicKey)
+	pkix, _ := testRsaPem(t)
+	authorized := ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey))
+
+	converted, err := PEMToSSH(pkix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(converted, authorized) {
+		t.Fatalf("expect %s, actual %s", authorized, converted)
+	}
+	back, err := SSHToPEM(converted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(back, pkix) {
+		t.Fatalf("expect %s, actual %s", pkix, back)
+	}
+	for _, input := range [][]byte{converted, back, testSsh2PublicKey(t, &key.PublicKey)} {
+		if _, info, err := ParsePublicKey(input); err != nil || info.Fingerprint != fingerprint {
+			t.Fatalf("fingerprint %q, expect %q, err: %v", info.Fingerprint, fingerprint, err)
+		}
+	}
+	if ssh2, err := SSHToPEM(testSsh2PublicKey(t, &key.PublicKey)); err != nil || !bytes.Equal(ssh2, pkix) {
+		t.Fatalf("bad: %s, err: %v", ssh2, err)
+	}
+
+	if _, err = SSHToPEM(pkix); err == nil {
+		t.Fatalf("expect error")
+	}
+	if _, err = PEMToSSH(authorized); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestKeyEncipherment(t *testing.T) {
+	key := testRsaPrivateKey(t)
+	signing := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: testCertificate(t, key, x509.KeyUsageDigitalSignature).Raw})
+	pub, _ := testRsaPem(t)
+
+	// warned about only, unless strict
+	if err := NewFortifierWithRsa(false, nil, signing).SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, f := range []*Fortifier{NewFortifierWithRsa(false, nil, signing), NewFortifierWithRsa(false, nil, pub)} {
+		f.AddRecipient(signing)
+		f.SetPolicy(StrictPolicy)
+		if err := f.SetupKey(); !errors.Is(err, ErrKeyUsage) || !errors.Is(err, ErrPolicyViolation) {
+			t.Fatalf("expect a key usage violation, got %v", err)
+		}
+	}
+	f := NewFortifierWithRsa(false, nil, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: testCertificate(t, key, x509.KeyUsageKeyEncipherment|x509.KeyUsageDigitalSignature).Raw}))
+	f.SetPolicy(StrictPolicy)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, tc := range []struct {
+		info   KeyInfo
+		permit bool
+	}{
+		{KeyInfo{}, true},
+		{KeyInfo{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}}, false},
+		{KeyInfo{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageEmailProtection}}, true},
+		{KeyInfo{KeyUsage: x509.KeyUsageKeyEncipherment, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}, true},
+	} {
+		if err := checkKeyEncipherment(tc.info); (err == nil) != tc.permit {
+			t.Fatalf("%+v: expect permit %t, got %v", tc.info, tc.permit, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package fortifier
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const rawSeedInfo = "fortify unsafe deterministic raw"
+
+// UnsafeDeterministicRaw must be set to true before SetRawSeed is called. It
+// exists only for the test fixtures of downstream systems: a cipher key derived
+// from a known seed protects nothing, so never set it outside of tests.
+var UnsafeDeterministicRaw bool
+
+// ErrDeterministicRaw means SetRawSeed was used while UnsafeDeterministicRaw is not set.
+var ErrDeterministicRaw = errors.New("deterministic cipher key requires UnsafeDeterministicRaw")
+
+// SetRawSeed makes the cipher key generated for every kind the HKDF-SHA256
+// derivation of the seed, rather than random bytes, so that a test fixture
+// fortified from the same seed always has the same cipher key. With
+// SetDeterministicIv, the IV and the payload are the same too; the key
+// wrapping of most kinds stays randomized, and the SeenStore does not record
+// seeded cipher keys. Unlike a fixed random source, the seed fixes the cipher
+// key itself. Test only: it fails with ErrDeterministicRaw unless
+// UnsafeDeterministicRaw is set, and so does SetupKey if UnsafeDeterministicRaw
+// is unset again in between. A nil seed restores random cipher keys.
+func (f *Fortifier) SetRawSeed(seed []byte) error {
+	if seed != nil && !UnsafeDeterministicRaw {
+		return ErrDeterministicRaw
+	}
+	f.rawSeed = seed
+	return nil
+}
+
+// seededRaw derives the cipher key from the seed of SetRawSeed.
+func (f *Fortifier) seededRaw() ([]byte, error) {
+	if !UnsafeDeterministicRaw {
+		return nil, ErrDeterministicRaw
+	}
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, f.rawSeed, nil, []byte(rawSeedInfo)), raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,50 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const testRawSeedDigest = "d7fa_O2a5c0TeZ6dR8hFdGES28A1yTI5oiuUiZ9rser-ryN7wWHFimPdkARrnLBlfGSUwft-3TNr0qJfy099Qw=="
+
+func TestRawSeed(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetRawSeed([]byte("fortify fixture")); !errors.Is(err, ErrDeterministicRaw) {
+		t.Fatalf("expect ErrDeterministicRaw, got %v", err)
+	}
+	UnsafeDeterministicRaw = true
+	defer func() { UnsafeDeterministicRaw = false }()
+
+	plaintext := []byte("stable fixture")
+	fixture := func() (string, []byte) {
+		f := NewFortifierWithRsa(false, nil, pub)
+		if err := f.SetRawSeed([]byte("fortify fixture")); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		f.SetDeterministicIv(true)
+		path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+		actual, err := testDecryptRsaFile(t, path, pri)
+		if err != nil || !bytes.Equal(actual, plaintext) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		return layout.Metadata().Recipients[0].Digest, testPayload(t, path)
+	}
+	digest, payload := fixture()
+	if digest != testRawSeedDigest {
+		t.Fatalf("unexpected cipher key digest: %s", digest)
+	}
+	if _, again := fixture(); !bytes.Equal(again, payload) {
+		t.Fatalf("payload of the same seed differs")
+	}
+
+	f = NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+	_ = f.SetRawSeed([]byte("fortify fixture"))
+	UnsafeDeterministicRaw = false
+	if err := f.SetupKey(); !errors.Is(err, ErrDeterministicRaw) {
+		t.Fatalf("expect ErrDeterministicRaw, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PayloadReaderAt decrypts arbitrary ranges of the payload of a fortified file
+// in CTR mode, made by NewPayloadReaderAt. The stream is positioned at the
+// offset of each read, segments included, so nothing before it is decrypted.
+//
+// The file checksum covers the whole plaintext, and no part of the payload is
+// authenticated alone: ranges read are not checked until Verify has decrypted
+// the whole payload once. Only payloads which cannot change after Verify, such
+// as a file opened read-only on a trusted disk, are then safe to read from.
+type PayloadReaderAt struct {
+	f       *Fortifier
+	layout  *FileLayout
+	payload io.ReaderAt
+	iv      []byte
+}
+
+// NewPayloadReaderAt authenticates the head of the layout with the cipher key
+// of f, which must be made with its metadata, and returns a reader of the
+// plaintext of the payload read from payload: the IV and ciphertext following
+// the head, such as an io.SectionReader of the file from layout.HeadLength.
+func (f *Fortifier) NewPayloadReaderAt(layout *FileLayout, payload io.ReaderAt) (*PayloadReaderAt, error) {
+	if err := f.AuthenticateMetadata(layout); err != nil {
+		return nil, err
+	}
+	if mode := layout.Metadata().Mode; mode != CipherModeAes256CTR {
+		return nil, fmt.Errorf("random access requires cipher mode %s, not %s", CipherModeAes256CTR, mode)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := payload.ReadAt(iv, 0); err != nil {
+		return nil, fmt.Errorf("%w: missing iv", ErrPayloadAuthFailed)
+	}
+	return &PayloadReaderAt{f: f, layout: layout, payload: payload, iv: iv}, nil
+}
+
+// Size returns the length of the plaintext.
+func (r *PayloadReaderAt) Size() int64 {
+	return int64(r.layout.dataLength)
+}
+
+// ReadAt decrypts len(p) bytes of the plaintext from offset off into p, as
+// io.ReaderAt. It returns io.EOF when fewer bytes are left to read.
+func (r *PayloadReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	size := r.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	want := p
+	if int64(len(p)) > size-off {
+		want = p[:size-off]
+	}
+	if n, err = r.payload.ReadAt(want, aes.BlockSize+off); n < len(want) {
+		// the bytes read are still enciphered
+		if err == nil || errors.Is(err, io.EOF) {
+			err = fmt.Errorf("%w: payload shorter than %d bytes", ErrPayloadAuthFailed, size)
+		}
+		return 0, err
+	}
+	stream, err := r.f.newCTRStreamAt(r.iv, r.layout.Metadata().Segment, off)
+	if err != nil {
+		return 0, err
+	}
+	stream.XORKeyStream(want, want)
+	if len(want) < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Verify decrypts the whole payload once, checking it against the file checksum.
+func (r *PayloadReaderAt) Verify() error {
+	payload := io.NewSectionReader(r.payload, 0, aes.BlockSize+r.Size())
+	return NewDecrypter(CipherModeAes256CTR, r.f).Decrypt(payload, io.Discard, r.layout)
+}
@@ -0,0 +1,86 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func testPayloadReaderAt(t testing.TB, path string, pri []byte) *PayloadReaderAt {
+	in, layout := testReadLayout(t, path)
+	t.Cleanup(func() { _ = in.Close() })
+	if offset, err := in.Seek(0, io.SeekCurrent); err != nil || offset != layout.HeadLength() {
+		t.Fatalf("head length %d, expect %d, err: %v", layout.HeadLength(), offset, err)
+	}
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	r, err := f.NewPayloadReaderAt(layout, io.NewSectionReader(in, layout.HeadLength(), 1<<62))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return r
+}
+
+func TestPayloadReaderAt(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext, err := io.ReadAll(testStream(t, 4<<20+123))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, segment := range []int64{0, 64 << 10} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		if err = f.SetSegmentSize(segment); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		r := testPayloadReaderAt(t, testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext), pri)
+		if r.Size() != int64(len(plaintext)) {
+			t.Fatalf("size %d, expect %d", r.Size(), len(plaintext))
+		}
+		// a middle range across segments, unaligned to the AES block
+		p := make([]byte, 200<<10)
+		off := int64(2<<20 - 1001)
+		if n, err := r.ReadAt(p, off); err != nil || n != len(p) || !bytes.Equal(p, plaintext[off:off+int64(n)]) {
+			t.Fatalf("segment %d: bad read of %d bytes at %d, err: %v", segment, n, off, err)
+		}
+		off = int64(len(plaintext) - 100)
+		if n, err := r.ReadAt(p, off); err != io.EOF || n != 100 || !bytes.Equal(p[:n], plaintext[off:]) {
+			t.Fatalf("segment %d: bad read of %d bytes at %d, err: %v", segment, n, off, err)
+		}
+		if n, err := r.ReadAt(p, int64(len(plaintext))); err != io.EOF || n != 0 {
+			t.Fatalf("expect EOF, got %d bytes, err: %v", n, err)
+		}
+		if err = r.Verify(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+}
+
+func TestPayloadReaderAtTampered(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := bytes.Repeat([]byte("tamper me "), 1000)
+	encrypted := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), plaintext)
+	b, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b[len(b)-500] ^= 1
+	tampered := testWriteFile(t, t.TempDir(), "tampered.data", b)
+	r := testPayloadReaderAt(t, tampered, pri)
+	// the range is not authenticated alone, only Verify detects the change
+	p := make([]byte, 10)
+	if _, err = r.ReadAt(p, 0); err != nil || !bytes.Equal(p, plaintext[:10]) {
+		t.Fatalf("bad: %q, err: %v", p, err)
+	}
+	if err = r.Verify(); !errors.Is(err, ErrPayloadAuthFailed) {
+		t.Fatalf("expect a payload integrity error, got %v", err)
+	}
+
+	ofb := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256OFB, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, ofb)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	if _, err = f.NewPayloadReaderAt(layout, io.NewSectionReader(in, layout.HeadLength(), 1<<62)); err == nil {
+		t.Fatalf("expect error")
+	}
+}
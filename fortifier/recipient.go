@@ -0,0 +1,217 @@
+package fortifier
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrRecipientNotFound = errors.New("no public key matches the fingerprint")
+
+var ErrAmbiguousRecipient = errors.New("several public keys match the fingerprint")
+
+// SSHPublicKeyEnv names the environment variable holding the operator's own
+// public key, or the path of its file, for SetEncryptToSelf.
+const SSHPublicKeyEnv = "SSH_PUBLIC_KEY"
+
+// AddRecipient adds the public key bytes as an additional RSA recipient of the
+// cipher key, parsed like the key bytes of NewFortifierWithRsa.
+func (f *Fortifier) AddRecipient(bytes []byte) {
+	f.AddLabeledRecipient(bytes, "")
+}
+
+// AddLabeledRecipient adds the public key bytes as AddRecipient, recording the
+// label with the recipient instead of the comment of its authorized key, if any.
+func (f *Fortifier) AddLabeledRecipient(bytes []byte, label string) {
+	f.recipients = append(f.recipients, bytes)
+	f.recipientGroups = append(f.recipientGroups, "")
+	f.recipientLabels = append(f.recipientLabels, label)
+}
+
+// SetStableMetadata enables ordering the metadata by content rather than by the
+// order recipients and parts are added in: the RSA recipients by fingerprint,
+// then scheme, and the parts of EncryptParts by name. Maps such as the tags are
+// always written with sorted keys. Re-encrypting or rewrapping for the same
+// recipients then changes only timestamps, digests and ciphertexts, not the
+// structure, keeping the diffs of fortified files kept under version control
+// minimal. With SetLegacyRsaField, the first RSA recipient stays in the legacy
+// field and only the others are ordered.
+func (f *Fortifier) SetStableMetadata(b bool) {
+	f.stable = b
+}
+
+// sortRecipients orders RSA recipients as SetStableMetadata.
+func sortRecipients(recipients []*MetadataRsa) {
+	slices.SortStableFunc(recipients, func(a, b *MetadataRsa) int {
+		return cmp.Or(strings.Compare(a.Fingerprint, b.Fingerprint), strings.Compare(string(a.Scheme), string(b.Scheme)))
+	})
+}
+
+// SetEncryptToSelf enables adding the operator's own public key, found by
+// SelfPublicKey, as an additional RSA recipient when encrypting, so that the
+// operator can always recover what they fortify for others.
+func (f *Fortifier) SetEncryptToSelf(b bool) {
+	f.toSelf = b
+}
+
+// SelfPublicKey returns the operator's own public key: the content or file named
+// by SSHPublicKeyEnv, or else the first RSA key of ~/.ssh/id_*.pub, preferring
+// ~/.ssh/id_rsa.pub.
+func SelfPublicKey() ([]byte, error) {
+	if v := strings.TrimSpace(os.Getenv(SSHPublicKeyEnv)); v != "" {
+		if _, _, err := ParsePublicKey([]byte(v)); err == nil {
+			return []byte(v), nil
+		}
+		b, err := os.ReadFile(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s is neither a public key nor a readable file: %v", SSHPublicKeyEnv, err)
+		}
+		return b, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(home, ".ssh")
+	paths, err := filepath.Glob(filepath.Join(dir, "id_*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	preferred := filepath.Join(dir, "id_rsa.pub")
+	if i := slices.Index(paths, preferred); i > 0 {
+		paths = append([]string{preferred}, slices.Delete(paths, i, i+1)...)
+	}
+	var errs []error
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_, info, err := ParsePublicKey(b)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if info.Kind != "rsa" {
+			errs = append(errs, fmt.Errorf("%s: %s key, not rsa", path, info.Kind))
+			continue
+		}
+		return b, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no public key found in %s, set %s", dir, SSHPublicKeyEnv)
+	}
+	return nil, fmt.Errorf("no RSA public key found in %s -- %w", dir, errors.Join(errs...))
+}
+
+// ResolveRecipient returns the content of the only .pub file in dir holding the
+// public key of the fingerprint, either "SHA256:..." as printed by ssh-keygen -l
+// or as returned by PublicKeyFingerprint. Files that do not parse are skipped.
+func ResolveRecipient(fingerprint, dir string) ([]byte, error) {
+	fingerprint = strings.TrimRight(strings.TrimSpace(fingerprint), "=")
+	if !strings.HasPrefix(fingerprint, "SHA256:") {
+		return nil, fmt.Errorf("invalid fingerprint %q: expect SHA256:...", fingerprint)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	var kb []byte
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		key, info, err := ParsePublicKey(b)
+		if err != nil {
+			continue
+		}
+		if info.Fingerprint == fingerprint || sshFingerprint(key) == fingerprint {
+			found = append(found, filepath.Base(path))
+			kb = b
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w %s in %s", ErrRecipientNotFound, fingerprint, dir)
+	case 1:
+		return kb, nil
+	default:
+		return nil, fmt.Errorf("%w %s: %s", ErrAmbiguousRecipient, fingerprint, strings.Join(found, ", "))
+	}
+}
+
+// ReadRecipientsFile reads the RSA recipients listed in the file, see ParseRecipients.
+func ReadRecipientsFile(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	recipients, err := ParseRecipients(file, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return recipients, nil
+}
+
+// ParseRecipients reads an age-style recipients list with one recipient per
+// line: a public key in one of the single-line formats of ParsePublicKey, the
+// path of a public key file, relative to dir unless absolute, or a SHA256
+// fingerprint resolved by ResolveRecipient among the .pub files of dir. Blank lines
+// and lines starting with # are skipped. It returns the key bytes of each
+// recipient for AddRecipient.
+func ParseRecipients(r io.Reader, dir string) ([][]byte, error) {
+	var recipients [][]byte
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), string(utf8BOM)))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kb, err := parseRecipientLine(line, dir)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		recipients = append(recipients, kb)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipient listed")
+	}
+	return recipients, nil
+}
+
+func parseRecipientLine(line, dir string) ([]byte, error) {
+	if scheme, _, ok := strings.Cut(line, ":"); ok && strings.EqualFold(scheme, "kms") {
+		return nil, fmt.Errorf("unsupported recipient %q: no KMS recipient kind", line)
+	}
+	if _, _, err := ParsePublicKey([]byte(line)); err == nil {
+		return []byte(line), nil
+	}
+	if strings.HasPrefix(line, "SHA256:") {
+		return ResolveRecipient(line, dir)
+	}
+	path := line
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	kb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("neither a public key nor a readable file: %v", err)
+	}
+	if _, _, err = ParsePublicKey(kb); err != nil {
+		return nil, fmt.Errorf("%s: %w", line, err)
+	}
+	return kb, nil
+}
@@ -0,0 +1,77 @@
+package fortifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnknownGroup = errors.New("unknown recipient group")
+
+// RecipientGroups maps group aliases, such as @platform-team, to their members,
+// each a line of a recipients list, see ParseRecipients.
+type RecipientGroups struct {
+	groups map[string][]string
+	dir    string
+}
+
+// NewRecipientGroups makes RecipientGroups of the mapping from aliases to
+// members, resolving member paths and fingerprints relative to dir. Aliases are
+// given with or without the leading @.
+func NewRecipientGroups(groups map[string][]string, dir string) *RecipientGroups {
+	g := &RecipientGroups{groups: make(map[string][]string, len(groups)), dir: dir}
+	for alias, members := range groups {
+		g.groups[strings.TrimPrefix(alias, "@")] = members
+	}
+	return g
+}
+
+// ReadRecipientGroups reads RecipientGroups from a JSON file of an object mapping
+// each alias to the list of its members, resolved relative to the file.
+func ReadRecipientGroups(path string) (*RecipientGroups, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var groups map[string][]string
+	if err = json.Unmarshal(b, &groups); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewRecipientGroups(groups, filepath.Dir(path)), nil
+}
+
+// Expand returns the key bytes of the members of the group alias for
+// AddRecipientGroup.
+func (g *RecipientGroups) Expand(alias string) ([][]byte, error) {
+	name := strings.TrimPrefix(alias, "@")
+	members, ok := g.groups[name]
+	if !ok {
+		return nil, fmt.Errorf("%w @%s", ErrUnknownGroup, name)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("recipient group @%s: no recipient listed", name)
+	}
+	recipients := make([][]byte, 0, len(members))
+	for _, member := range members {
+		kb, err := parseRecipientLine(strings.TrimSpace(member), g.dir)
+		if err != nil {
+			return nil, fmt.Errorf("recipient group @%s: %w", name, err)
+		}
+		recipients = append(recipients, kb)
+	}
+	return recipients, nil
+}
+
+// AddRecipientGroup adds the members of the group, as expanded by Expand, as
+// additional RSA recipients, recording the group name in their metadata for audit.
+func (f *Fortifier) AddRecipientGroup(alias string, members [][]byte) {
+	name := "@" + strings.TrimPrefix(alias, "@")
+	for _, kb := range members {
+		f.recipients = append(f.recipients, kb)
+		f.recipientGroups = append(f.recipientGroups, name)
+		f.recipientLabels = append(f.recipientLabels, "")
+	}
+}
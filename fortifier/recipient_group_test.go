@@ -0,0 +1,66 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRecipientGroups(t *testing.T) {
+	dir := t.TempDir()
+	key := testRsaPrivateKey(t)
+	bob, carol := testOtherRsaPem(t), testOtherRsaPem(t)
+	testWriteFile(t, dir, "alice.pub", ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey)))
+	testWriteFile(t, dir, "bob.pem", testPublicPem(t, bob))
+	testWriteFile(t, dir, "carol.pub", testPublicPem(t, carol))
+	_, carolInfo, err := ParsePublicKey(testPublicPem(t, carol))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testWriteFile(t, dir, "groups.json",
+		[]byte(`{"@platform-team": ["alice.pub", " bob.pem", "`+carolInfo.Fingerprint+`"], "empty": []}`))
+
+	groups, err := ReadRecipientGroups(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	members, err := groups.Expand("platform-team")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("expect 3 members, not %d", len(members))
+	}
+	f := NewFortifierWithRsa(false, nil, nil)
+	f.AddRecipientGroup("platform-team", members)
+	plaintext := []byte("for the platform team")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, dir, plaintext)
+	in, layout := testReadLayout(t, encrypted)
+	_ = in.Close()
+	recipients := layout.Metadata().Recipients
+	if len(recipients) != 3 {
+		t.Fatalf("expect 3 recipients, not %d", len(recipients))
+	}
+	for _, m := range recipients {
+		if m.Group != "@platform-team" {
+			t.Fatalf("unexpected group: %q", m.Group)
+		}
+	}
+	for _, pri := range [][]byte{bob, carol} {
+		if actual, err := testDecryptRsaFile(t, encrypted, pri); err != nil || !bytes.Equal(actual, plaintext) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+	}
+
+	if _, err = groups.Expand("@ops"); !errors.Is(err, ErrUnknownGroup) {
+		t.Fatalf("expect ErrUnknownGroup, got %v", err)
+	}
+	if _, err = groups.Expand("@empty"); err == nil {
+		t.Fatalf("expect error")
+	}
+	if _, err = NewRecipientGroups(map[string][]string{"@bad": {"missing.pub"}}, dir).Expand("bad"); err == nil {
+		t.Fatalf("expect error")
+	}
+}
@@ -0,0 +1,289 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testFakeHome makes a home directory holding an Ed25519 and an RSA SSH public
+// key, and returns the PEM of the RSA private key.
+func testFakeHome(t *testing.T) []byte {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(SSHPublicKeyEnv, "")
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	dir := filepath.Join(home, ".ssh")
+	if err = os.Mkdir(dir, 0700); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	testWriteFile(t, dir, "id_ed25519.pub", ssh.MarshalAuthorizedKey(testSshPublicKey(t, edPub)))
+	testWriteFile(t, dir, "id_rsa.pub", ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey)))
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestEncryptToSelf(t *testing.T) {
+	self := testFakeHome(t)
+	pub, pri := testRsaPem(t)
+	plaintext := []byte("for others and myself")
+
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetEncryptToSelf(true)
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if n := len(layout.Metadata().Recipients); n != 2 {
+		t.Fatalf("expect 2 recipients, not %d", n)
+	}
+	if err := layout.Verify(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, key := range [][]byte{pri, self} {
+		if actual, err := testDecryptRsaFile(t, path, key); err != nil || !bytes.Equal(actual, plaintext) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+	}
+
+	f = NewFortifierWithRsa(false, nil, pub)
+	f.SetEncryptToSelf(false)
+	path = testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	if _, err := testDecryptRsaFile(t, path, self); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestSelfPublicKeyEnv(t *testing.T) {
+	testFakeHome(t)
+	pub, _ := testRsaPem(t)
+	t.Setenv(SSHPublicKeyEnv, string(pub))
+	if actual, err := SelfPublicKey(); err != nil || !bytes.Equal(actual, bytes.TrimSpace(pub)) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	path := testWriteFile(t, t.TempDir(), "self.pub", pub)
+	t.Setenv(SSHPublicKeyEnv, path)
+	if actual, err := SelfPublicKey(); err != nil || !bytes.Equal(actual, pub) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+
+	t.Setenv(SSHPublicKeyEnv, "")
+	if err := os.Remove(filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa.pub")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := SelfPublicKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestAddRecipientRequiresRsaKind(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	f := NewFortifierWithSss(false, false, testSssParts(t))
+	f.AddRecipient(pub)
+	if err := f.SetupKey(); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestReadRecipientsFile(t *testing.T) {
+	dir := t.TempDir()
+	key := testRsaPrivateKey(t)
+	other := testOtherRsaPem(t)
+	testWriteFile(t, dir, "bob.pem", testPublicPem(t, other))
+	authorized := ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey))
+	testWriteFile(t, dir, "notes.txt", []byte("not a key"))
+	path := testWriteFile(t, dir, "recipients.txt", []byte("# team\n\n"+string(authorized)+"  # bob\n  bob.pem\n"))
+
+	recipients, err := ReadRecipientsFile(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("unexpected number of recipients: %d", len(recipients))
+	}
+	f := NewFortifierWithRsa(false, nil, recipients[0])
+	for _, r := range recipients[1:] {
+		f.AddRecipient(r)
+	}
+	plaintext := []byte("for the team")
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, dir, plaintext)
+	if actual, err := testDecryptRsaFile(t, encrypted, other); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+
+	for content, expect := range map[string]string{
+		"# kms\n" + string(authorized) + "kms:aws:alias/backup\n": "line 3: unsupported recipient",
+		"\nnot-a-key\n": "line 2: neither a public key nor a readable file",
+		"# empty\n\n":   "no recipient listed",
+		"notes.txt":     "line 1: notes.txt:",
+	} {
+		_, err := ParseRecipients(strings.NewReader(content), dir)
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Fatalf("expect %q, got %v", expect, err)
+		}
+	}
+}
+
+func TestResolveRecipient(t *testing.T) {
+	dir := t.TempDir()
+	key := testRsaPrivateKey(t)
+	other := testOtherRsaPem(t)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	authorized := ssh.MarshalAuthorizedKey(testSshPublicKey(t, &key.PublicKey))
+	testWriteFile(t, dir, "alice.pub", authorized)
+	testWriteFile(t, dir, "bob.pub", testPublicPem(t, other))
+	testWriteFile(t, dir, "carol.pub", ssh.MarshalAuthorizedKey(testSshPublicKey(t, edPub)))
+	testWriteFile(t, dir, "notes.pub", []byte("not a key"))
+	testWriteFile(t, dir, "alice.txt", authorized)
+
+	_, bobInfo, err := ParsePublicKey(testPublicPem(t, other))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, fingerprint := range []string{
+		ssh.FingerprintSHA256(testSshPublicKey(t, &key.PublicKey)),
+		PublicKeyFingerprint(&key.PublicKey),
+	} {
+		actual, err := ResolveRecipient(fingerprint, dir)
+		if err != nil || !bytes.Equal(actual, authorized) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+	}
+	if actual, err := ResolveRecipient(bobInfo.Fingerprint, dir); err != nil || !bytes.Equal(actual, testPublicPem(t, other)) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+	if _, err = ResolveRecipient("SHA256:"+strings.Repeat("A", 43), dir); !errors.Is(err, ErrRecipientNotFound) {
+		t.Fatalf("expect ErrRecipientNotFound, got %v", err)
+	}
+	if _, err = ResolveRecipient("MD5:00", dir); err == nil {
+		t.Fatalf("expect error")
+	}
+
+	testWriteFile(t, dir, "alice-laptop.pub", authorized)
+	_, err = ResolveRecipient(PublicKeyFingerprint(&key.PublicKey), dir)
+	if !errors.Is(err, ErrAmbiguousRecipient) || !strings.Contains(err.Error(), "alice-laptop.pub, alice.pub") {
+		t.Fatalf("expect ErrAmbiguousRecipient, got %v", err)
+	}
+
+	recipients, err := ParseRecipients(strings.NewReader(bobInfo.Fingerprint+"\n"), dir)
+	if err != nil || len(recipients) != 1 || !bytes.Equal(recipients[0], testPublicPem(t, other)) {
+		t.Fatalf("bad: %q, err: %v", recipients, err)
+	}
+}
+
+// testMetadataStructure returns the JSON of the metadata of the fortified file
+// with the timestamps, digests and ciphertexts, changing on every run, cleared.
+func testMetadataStructure(t testing.TB, path string) []byte {
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	m := layout.Metadata()
+	m.Timestamp = time.Time{}
+	for _, r := range m.Recipients {
+		r.Timestamp, r.Digest, r.Ciphertext, r.CiphertextDigest = time.Time{}, "", "", ""
+	}
+	for _, p := range m.Parts {
+		p.Checksum = ""
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return b
+}
+
+func TestStableMetadata(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	other := testPublicPem(t, testOtherRsaPem(t))
+	encrypt := func(stable bool, first, second []byte) []byte {
+		f := NewFortifierWithRsa(false, nil, nil)
+		f.AddRecipient(first)
+		f.AddRecipient(second)
+		if err := f.SetDualWrap(RsaSchemeKEM); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := f.SetTags(map[string]string{"env": "prod", "app": "api"}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		f.SetStableMetadata(stable)
+		return testMetadataStructure(t, testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("stable")))
+	}
+	a, b := encrypt(true, pub, other), encrypt(true, other, pub)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("metadata structure differs:\n%s\n%s", a, b)
+	}
+	if bytes.Equal(encrypt(false, pub, other), encrypt(false, other, pub)) {
+		t.Fatalf("expect the order of the recipients added without stable metadata")
+	}
+
+	parts := map[string][]byte{"b.txt": []byte("bee"), "a.txt": []byte("ay")}
+	encryptParts := func(names ...string) []byte {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetStableMetadata(true)
+		return testMetadataStructure(t, testEncryptParts(t, f, parts, names...))
+	}
+	if a, b = encryptParts("a.txt", "b.txt"), encryptParts("b.txt", "a.txt"); !bytes.Equal(a, b) {
+		t.Fatalf("metadata structure differs:\n%s\n%s", a, b)
+	}
+}
+
+func TestRecipientLabelFromComment(t *testing.T) {
+	authorized := func(k any, comment string) []byte {
+		line := bytes.TrimSpace(ssh.MarshalAuthorizedKey(testSshPublicKey(t, k)))
+		return append(line, " "+comment+"\n"...)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	const comment = "Zoë Müller @ laptop 🔑"
+	f := NewFortifierWithRsa(false, nil, authorized(&testRsaPrivateKey(t).PublicKey, comment))
+	f.AddLabeledRecipient(authorized(&other.PublicKey, "bob@host"), "ops team")
+	f.AddRecipient(testPublicPem(t, testOtherRsaPem(t)))
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("labeled"))
+
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = in.Close() }()
+	info, err := Inspect(in)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var labels []string
+	for _, r := range info.Recipients {
+		labels = append(labels, r.Label)
+	}
+	if strings.Join(labels, "|") != comment+"|ops team|" {
+		t.Fatalf("bad: %q", labels)
+	}
+	head, layout := testReadLayout(t, path)
+	_ = head.Close()
+	if layout.Metadata().Recipients[0].Label != comment {
+		t.Fatalf("bad: %+v", layout.Metadata().Recipients[0])
+	}
+}
@@ -0,0 +1,40 @@
+package fortifier
+
+import "time"
+
+// RecoverResult describes how Recover recovered the cipher key, for audit logs
+// and reporting.
+type RecoverResult struct {
+	Kind  CipherKeyKind `json:"key"`
+	Suite string        `json:"suite,omitempty"`
+	// Recipient is the index of the RSA recipient which unwrapped the cipher key,
+	// in the order of the metadata with the legacy field first, or -1
+	Recipient int `json:"recipient"`
+	// Fingerprint identifies the private key which unwrapped the cipher key
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Scheme      RsaScheme `json:"scheme,omitempty"`
+	// Redundant reports the cipher key was unwrapped from the redundant wrapping
+	// of the recipient, see SetRedundantWrap
+	Redundant bool `json:"redundant,omitempty"`
+	// Passphrase reports a passphrase was entered, for an encrypted private key,
+	// a passphrase factor or a mnemonic
+	Passphrase bool `json:"passphrase,omitempty"`
+	// Duration is the time taken to recover the cipher key, prompts included
+	Duration time.Duration `json:"duration"`
+}
+
+// Recover recovers the cipher key from the head of a fortified file as
+// RecoverRaw, also describing how it was recovered. f must not have recovered
+// the cipher key before.
+func (f *Fortifier) Recover(layout *FileLayout) (raw []byte, res RecoverResult, err error) {
+	started := time.Now()
+	f.recovered = RecoverResult{Recipient: -1}
+	if raw, err = f.RecoverRaw(layout); err != nil {
+		return
+	}
+	res = f.recovered
+	res.Kind = layout.Metadata().Key
+	res.Suite = layout.Metadata().Suite
+	res.Duration = time.Since(started)
+	return
+}
@@ -0,0 +1,41 @@
+package fortifier
+
+import "testing"
+
+func TestRecoverResult(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	other := testOtherRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, testPublicPem(t, other))
+	f.AddRecipient(pub)
+	if err := f.SetDualWrap(RsaSchemeKEM); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	encrypted := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("audited"))
+
+	recoverWith := func(pri []byte, passphrase string) RecoverResult {
+		in, layout := testReadLayout(t, encrypted)
+		defer func() { _ = in.Close() }()
+		f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+		f.SetPassphraseProvider(testPassphrase(passphrase))
+		if err := f.SetRecoverScheme(RsaSchemeKEM); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		raw, res, err := f.Recover(layout)
+		if err != nil || len(raw) != 32 {
+			t.Fatalf("bad: %d bytes, err: %v", len(raw), err)
+		}
+		return res
+	}
+	// the recipients are other with rsa-oaep and rsa-kem, then pub with both
+	res := recoverWith(testEncryptedRsaPem(t, "secret"), "secret")
+	if res.Recipient != 3 || res.Scheme != RsaSchemeKEM || !res.Passphrase ||
+		res.Fingerprint != PublicKeyFingerprint(&testRsaPrivateKey(t).PublicKey) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Kind != CipherKeyKindRSA || res.Suite == "" || res.Redundant || res.Duration <= 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res = recoverWith(other, ""); res.Recipient != 1 || res.Passphrase {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,107 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+)
+
+// ErrReencryptMismatch means the payload re-encrypted by Reencrypt does not
+// decrypt back to the plaintext of the original file.
+var ErrReencryptMismatch = errors.New("re-encrypted payload mismatch")
+
+// encrypterModes are the cipher modes of the encrypters by name.
+var encrypterModes = map[CipherModeName]CipherMode{
+	CipherModeAes256CTR: {Name: CipherModeAes256CTR, SteamMaker: cipher.NewCTR},
+	CipherModeAes256OFB: {Name: CipherModeAes256OFB, SteamMaker: cipher.NewOFB},
+	CipherModeAes256CFB: {Name: CipherModeAes256CFB, SteamMaker: cipher.NewCFBEncrypter},
+}
+
+// Reencrypt rotates the cipher key of the fortified file of the layout, its
+// payload read from in, into out under the new cipher key of to, in the same
+// cipher mode. from recovers the old cipher key and must be made with the
+// metadata of the layout. The payload is decrypted and re-encrypted through a
+// pipe as it is read, so that only the buffers of both streams are held in
+// memory, whatever the payload size. The old file checksum is only known at the
+// end of the payload: on error out is incomplete and must be discarded. When
+// out is also an io.ReadSeeker, such as an *os.File, it is read back and
+// decrypted with the new cipher key, and fails with ErrReencryptMismatch unless
+// its digest matches the original plaintext. The description and tags of the
+// file are kept, unless to sets its own. A deterministic IV requires
+// seekable plaintext, so to must not enable SetDeterministicIv.
+func Reencrypt(from, to *Fortifier, layout *FileLayout, in io.Reader, out io.WriteSeeker) (err error) {
+	if err = from.AuthenticateMetadata(layout); err != nil {
+		return
+	}
+	mode := layout.Metadata().Mode
+	dec := NewDecrypter(mode, from)
+	encMode, ok := encrypterModes[mode]
+	if dec == nil || !ok {
+		return fmt.Errorf("unknown cipher mode name: %s", mode)
+	}
+	if to.meta.Deterministic {
+		return errors.New("deterministic iv requires a seekable input")
+	}
+	if err = to.SetupKey(); err != nil {
+		return
+	}
+	to.meta.Mode = mode
+	to.meta.Nested = layout.Metadata().Nested
+	// the labels describe the content, not the cipher key, and survive the rotation
+	if to.meta.Description == "" {
+		to.meta.Description = layout.Metadata().Description
+	}
+	if to.meta.Tags == nil {
+		to.meta.Tags = maps.Clone(layout.Metadata().Tags)
+	}
+	var head *FileLayout
+	if head, err = to.newLayout(); err != nil {
+		return
+	}
+	pr, pw := io.Pipe()
+	decrypted := make(chan error, 1)
+	go func() {
+		err := dec.Decrypt(in, pw, layout)
+		_ = pw.CloseWithError(err)
+		decrypted <- err
+	}()
+	plain := sha512.New()
+	enc := &Aes256StreamEncrypter{to}
+	err = enc.Encrypt(io.TeeReader(pr, plain), out, head, encMode)
+	// unblock the decryption if the encryption stopped early
+	_ = pr.CloseWithError(errors.New("re-encryption stopped"))
+	if decErr := <-decrypted; err == nil && decErr != nil {
+		return decErr
+	}
+	if err != nil {
+		return
+	}
+	if rs, ok := out.(io.ReadSeeker); ok {
+		return verifyReencrypted(to, rs, plain.Sum(nil))
+	}
+	return
+}
+
+// verifyReencrypted decrypts the file written to rs with the cipher key of f
+// and compares the digest of its plaintext.
+func verifyReencrypted(f *Fortifier, rs io.ReadSeeker, digest []byte) (err error) {
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(rs); err != nil {
+		return fmt.Errorf("%w: %w", ErrReencryptMismatch, err)
+	}
+	plain := sha512.New()
+	if err = NewDecrypter(layout.Metadata().Mode, f).Decrypt(rs, plain, layout); err != nil {
+		return fmt.Errorf("%w: %w", ErrReencryptMismatch, err)
+	}
+	if !bytes.Equal(plain.Sum(nil), digest) {
+		return fmt.Errorf("%w: plaintext digest", ErrReencryptMismatch)
+	}
+	return
+}
@@ -0,0 +1,129 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// testStream returns a reader of size pseudorandom bytes, the same for every call.
+func testStream(t testing.TB, size int64) io.Reader {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	zeros := io.LimitReader(zeroReader{}, size)
+	return cipher.StreamReader{S: cipher.NewCTR(block, make([]byte, aes.BlockSize)), R: zeros}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestReencrypt(t *testing.T) {
+	const size = 32 << 20
+	pub, pri := testRsaPem(t)
+	dir := t.TempDir()
+	old, err := os.Create(filepath.Join(dir, "old.data"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = old.Close() }()
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err = f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f.meta.Mode = CipherModeAes256CFB
+	layout, err := f.newLayout()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	enc := &Aes256StreamEncrypter{f}
+	if err = enc.Encrypt(testStream(t, size), old, layout, encrypterModes[CipherModeAes256CFB]); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	oldRaw := bytes.Clone(f.key.raw)
+
+	in, layout := testReadLayout(t, old.Name())
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(filepath.Join(dir, "new.data"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	to := NewFortifierWithRsa(false, nil, pub)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err = Reencrypt(NewFortifierWithRsa(false, layout.Metadata(), pri), to, layout, in, out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Fatalf("allocated %d bytes re-encrypting %d bytes", allocated, size)
+	}
+	if bytes.Equal(to.key.raw, oldRaw) {
+		t.Fatalf("cipher key not rotated")
+	}
+
+	in, layout = testReadLayout(t, out.Name())
+	defer func() { _ = in.Close() }()
+	if layout.Metadata().Mode != CipherModeAes256CFB {
+		t.Fatalf("unexpected mode %s", layout.Metadata().Mode)
+	}
+	actual, expect := sha512.New(), sha512.New()
+	if _, err = io.Copy(expect, testStream(t, size)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = NewDecrypter(CipherModeAes256CFB, NewFortifierWithRsa(false, layout.Metadata(), pri)).Decrypt(in, actual, layout); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(actual.Sum(nil), expect.Sum(nil)) {
+		t.Fatalf("re-encrypted plaintext mismatch")
+	}
+}
+
+func TestReencryptCorrupted(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	dir := t.TempDir()
+	encrypted := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, dir, bytes.Repeat([]byte("rotate me "), 1<<12))
+	file, err := os.OpenFile(encrypted, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err = file.WriteAt([]byte{0xff}, stat.Size()-100); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_ = file.Close()
+
+	in, layout := testReadLayout(t, encrypted)
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(filepath.Join(dir, "new.data"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = out.Close() }()
+	err = Reencrypt(NewFortifierWithRsa(false, layout.Metadata(), pri), NewFortifierWithRsa(false, nil, pub), layout, in, out)
+	if !errors.Is(err, ErrPayloadAuthFailed) {
+		t.Fatalf("expect ErrPayloadAuthFailed, got %v", err)
+	}
+
+	to := NewFortifierWithRsa(false, nil, pub)
+	to.SetDeterministicIv(true)
+	if err = Reencrypt(NewFortifierWithRsa(false, layout.Metadata(), pri), to, layout, in, out); err == nil {
+		t.Fatalf("expect error")
+	}
+}
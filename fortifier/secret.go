@@ -0,0 +1,25 @@
+package fortifier
+
+import "fmt"
+
+// secretBytes holds key material. It formats as <redacted:len=N> with every
+// verb, so that verbose logging can never leak its content.
+type secretBytes []byte
+
+func (s secretBytes) String() string {
+	return fmt.Sprintf("<redacted:len=%d>", len(s))
+}
+
+func (s secretBytes) GoString() string {
+	return s.String()
+}
+
+func (s secretBytes) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(s.String()))
+}
+
+// Format prints the key data with its secret fields redacted. fmt does not
+// call methods of unexported fields, so the struct cannot rely on secretBytes alone.
+func (k *CipherKeyData) Format(f fmt.State, _ rune) {
+	_, _ = fmt.Fprintf(f, "{kind:%s raw:%s parts:%d bytes:%s}", k.kind, k.raw, len(k.parts), k.bytes)
+}
@@ -0,0 +1,68 @@
+package fortifier
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Secret holds key material, such as the cipher key returned by RecoverSecret,
+// until Destroy zeroes it. Its memory is locked against swapping where the
+// platform allows, and it formats as <redacted:len=N> like other key material.
+// A Secret dropped without Destroy is zeroed when garbage collected.
+type Secret struct {
+	mu     sync.Mutex
+	b      secretBytes
+	locked bool
+}
+
+func newSecret(b []byte) *Secret {
+	s := &Secret{b: b}
+	s.locked = lockMemory(b) == nil
+	runtime.SetFinalizer(s, (*Secret).Destroy)
+	return s
+}
+
+// Bytes returns the secret, valid until Destroy, or nil once destroyed.
+func (s *Secret) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b
+}
+
+// Destroy zeroes the secret and releases its memory. It is safe to call more than once.
+func (s *Secret) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.b == nil {
+		return
+	}
+	clear(s.b)
+	if s.locked {
+		_ = unlockMemory(s.b)
+	}
+	s.b, s.locked = nil, false
+	runtime.SetFinalizer(s, nil)
+}
+
+func (s *Secret) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func (s *Secret) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(s.String()))
+}
+
+// RecoverSecret is RecoverRaw returning the cipher key as a Secret, to be
+// destroyed by the caller once done with it. The cipher key moves into the
+// Secret, so the Fortifier keeps no copy of it.
+func (f *Fortifier) RecoverSecret(layout *FileLayout) (*Secret, error) {
+	if err := f.AuthenticateMetadata(layout); err != nil {
+		return nil, err
+	}
+	raw := f.key.raw
+	f.key.raw = nil
+	return newSecret(raw), nil
+}
@@ -0,0 +1,52 @@
+package fortifier
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretDestroy(t *testing.T) {
+	s := newSecret([]byte("top secret key material"))
+	b := s.Bytes()
+	if string(b) != "top secret key material" {
+		t.Fatalf("bad: %q", b)
+	}
+	if text := fmt.Sprintf("%v %s %+v %#v", s, s, s, s); strings.Contains(text, "secret") {
+		t.Fatalf("expect redacted, got %s", text)
+	}
+	s.Destroy()
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatalf("expect zeroed, got %q", b)
+	}
+	if s.Bytes() != nil {
+		t.Fatalf("expect nil after destroy")
+	}
+	s.Destroy()
+}
+
+func TestRecoverSecret(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("payload"))
+	in, layout := testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	secret, err := f.RecoverSecret(layout)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer secret.Destroy()
+	if f.key.raw != nil {
+		t.Fatalf("expect the cipher key moved into the secret")
+	}
+	var out bytes.Buffer
+	if err = DecryptPayload(secret.Bytes(), layout, in, &out); err != nil || out.String() != "payload" {
+		t.Fatalf("bad: %q, err: %v", out.String(), err)
+	}
+	raw := secret.Bytes()
+	secret.Destroy()
+	if !bytes.Equal(raw, make([]byte, len(raw))) {
+		t.Fatalf("expect zeroed")
+	}
+}
@@ -0,0 +1,123 @@
+package fortifier
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+// ErrReusedKey means a newly generated cipher key was generated before, as
+// recorded by the SeenStore, which indicates a failing random number generator.
+var ErrReusedKey = errors.New("cipher key generated before")
+
+// SeenStore records the digests of the cipher keys generated, see SetSeenStore.
+type SeenStore interface {
+	// Seen records the digest and reports whether it was recorded before.
+	Seen(digest string) (bool, error)
+}
+
+// SetSeenStore enables recording the digest of every cipher key generated into
+// the store, failing with ErrReusedKey instead of fortifying with a cipher key
+// recorded before. A nil store disables it.
+func (f *Fortifier) SetSeenStore(s SeenStore) {
+	f.seen = s
+}
+
+// newRaw generates a new cipher key, checking it against the SeenStore.
+func (f *Fortifier) newRaw() ([]byte, error) {
+	if f.rawSeed != nil {
+		return f.seededRaw()
+	}
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(f.randomReader(), raw); err != nil {
+		return nil, err
+	}
+	if f.seen == nil {
+		return raw, nil
+	}
+	seen, err := f.seen.Seen(utils.ComputeDigest(raw))
+	if err != nil {
+		return nil, fmt.Errorf("seen store: %w", err)
+	}
+	if seen {
+		clear(raw)
+		return nil, ErrReusedKey
+	}
+	return raw, nil
+}
+
+// randomReader returns the source of the cipher keys, crypto/rand by default.
+func (f *Fortifier) randomReader() io.Reader {
+	if f.random == nil {
+		return rand.Reader
+	}
+	return f.random
+}
+
+// MemorySeenStore is a SeenStore kept in memory, safe for concurrent use.
+type MemorySeenStore struct {
+	mu      sync.Mutex
+	digests map[string]bool
+}
+
+// NewMemorySeenStore makes a MemorySeenStore holding the digests.
+func NewMemorySeenStore(digests ...string) *MemorySeenStore {
+	s := &MemorySeenStore{digests: make(map[string]bool, len(digests))}
+	for _, d := range digests {
+		s.digests[d] = true
+	}
+	return s
+}
+
+func (s *MemorySeenStore) Seen(digest string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.digests[digest] {
+		return true, nil
+	}
+	s.digests[digest] = true
+	return false, nil
+}
+
+// FileSeenStore is a SeenStore appending one digest per line to a file, so that
+// the digests persist across runs of a batch.
+type FileSeenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSeenStore makes a FileSeenStore of the file, created on first use.
+func NewFileSeenStore(path string) *FileSeenStore {
+	return &FileSeenStore{path: path}
+}
+
+func (s *FileSeenStore) Seen(digest string) (seen bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var file *os.File
+	if file, err = os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600); err != nil {
+		return
+	}
+	defer func() {
+		if e := file.Close(); err == nil {
+			err = e
+		}
+	}()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if scanner.Text() == digest {
+			return true, nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	_, err = fmt.Fprintln(file, digest)
+	return
+}
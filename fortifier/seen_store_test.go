@@ -0,0 +1,46 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+func TestSeenStore(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	stuck := bytes.Repeat([]byte{0x5a}, 32)
+	store := NewMemorySeenStore(utils.ComputeDigest(stuck))
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.SetSeenStore(store)
+	f.random = bytes.NewReader(stuck)
+	if err := f.SetupKey(); !errors.Is(err, ErrReusedKey) {
+		t.Fatalf("expect ErrReusedKey, got %v", err)
+	}
+
+	// a fresh key is recorded, and rejected once generated again
+	fresh := bytes.Repeat([]byte{0x01}, 32)
+	for i, expect := range []error{nil, ErrReusedKey} {
+		f = NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+		f.SetSeenStore(store)
+		f.random = bytes.NewReader(fresh)
+		if err := f.SetupKey(); !errors.Is(err, expect) {
+			t.Fatalf("%d: expect %v, got %v", i, expect, err)
+		}
+	}
+	testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("unchecked"))
+}
+
+func TestFileSeenStore(t *testing.T) {
+	store := NewFileSeenStore(filepath.Join(t.TempDir(), "seen.txt"))
+	for i, expect := range []bool{false, true} {
+		if seen, err := store.Seen("digest-a"); err != nil || seen != expect {
+			t.Fatalf("%d: expect %t, got %t, err: %v", i, expect, seen, err)
+		}
+	}
+	if seen, err := NewFileSeenStore(store.path).Seen("digest-b"); err != nil || seen {
+		t.Fatalf("expect unseen, got %t, err: %v", seen, err)
+	}
+}
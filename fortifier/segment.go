@@ -0,0 +1,132 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	segmentKeyLabel = "fortify segment key"
+	minSegmentSize  = 64 << 10
+)
+
+// ErrSegmentSize means a segment size is not a multiple of the AES block size of
+// at least 64 KiB.
+var ErrSegmentSize = errors.New("invalid segment size")
+
+// SetSegmentSize enables deriving a fresh subkey from the cipher key for every
+// size bytes of the stream, so that no single key covers a very large payload.
+// The size must be a multiple of the AES block size of at least 64 KiB, zero
+// disables segments.
+func (f *Fortifier) SetSegmentSize(size int64) error {
+	if err := checkSegmentSize(size); err != nil {
+		return err
+	}
+	f.meta.Segment = size
+	return nil
+}
+
+// checkSegmentSize rejects a segment size out of bounds, such as one recorded by
+// a crafted head to derive a subkey for every block of the payload.
+func checkSegmentSize(size int64) error {
+	if size != 0 && (size < minSegmentSize || size%aes.BlockSize != 0) {
+		return fmt.Errorf("%w: %d, requiring a multiple of %d of at least %d", ErrSegmentSize, size, aes.BlockSize, minSegmentSize)
+	}
+	return nil
+}
+
+// newStream makes the cipher stream of the mode, switching to a subkey derived
+// by HKDF from the cipher key and IV at every segment boundary if enabled.
+func (f *Fortifier) newStream(mode CipherMode, iv []byte, segment int64) (cipher.Stream, error) {
+	if err := checkSegmentSize(segment); err != nil {
+		return nil, err
+	}
+	if segment == 0 {
+		return mode.SteamMaker(f.block, iv), nil
+	}
+	s := &segmentedStream{raw: f.key.raw, iv: iv, maker: mode.SteamMaker, size: segment}
+	s.next()
+	return s, nil
+}
+
+type segmentedStream struct {
+	raw    secretBytes
+	iv     []byte
+	maker  func(block cipher.Block, iv []byte) cipher.Stream
+	size   int64
+	index  uint64
+	left   int64
+	stream cipher.Stream
+}
+
+func (s *segmentedStream) next() {
+	s.stream = s.maker(s.block(), s.iv)
+	s.index++
+	s.left = s.size
+}
+
+// block derives the cipher of the subkey of the current segment.
+func (s *segmentedStream) block() cipher.Block {
+	info := make([]byte, len(segmentKeyLabel)+8)
+	copy(info, segmentKeyLabel)
+	binary.BigEndian.PutUint64(info[len(segmentKeyLabel):], s.index)
+	key := make([]byte, 32)
+	// Neither can fail: HKDF-SHA256 yields up to 8160 bytes and the key is 32 bytes long.
+	_, _ = io.ReadFull(hkdf.New(sha256.New, s.raw, s.iv, info), key)
+	block, _ := aes.NewCipher(key)
+	return block
+}
+
+// newCTRStreamAt makes the CTR stream of newStream as positioned offset bytes
+// into the stream, without generating the keystream before it.
+func (f *Fortifier) newCTRStreamAt(iv []byte, segment, offset int64) (cipher.Stream, error) {
+	if err := checkSegmentSize(segment); err != nil {
+		return nil, err
+	}
+	var stream cipher.Stream
+	if segment == 0 {
+		stream = cipher.NewCTR(f.block, ctrAdd(iv, offset/aes.BlockSize))
+	} else {
+		s := &segmentedStream{raw: f.key.raw, iv: iv, maker: cipher.NewCTR, size: segment, index: uint64(offset / segment)}
+		aligned := offset % segment / aes.BlockSize * aes.BlockSize
+		s.stream = cipher.NewCTR(s.block(), ctrAdd(iv, aligned/aes.BlockSize))
+		s.index++
+		s.left = segment - aligned
+		stream = s
+	}
+	skip := make([]byte, offset%aes.BlockSize)
+	stream.XORKeyStream(skip, skip)
+	return stream, nil
+}
+
+// ctrAdd returns the big-endian counter block iv advanced by n blocks.
+func ctrAdd(iv []byte, n int64) []byte {
+	ctr := bytes.Clone(iv)
+	carry := uint64(n)
+	for i := len(ctr) - 1; i >= 0 && carry > 0; i-- {
+		carry += uint64(ctr[i])
+		ctr[i] = byte(carry)
+		carry >>= 8
+	}
+	return ctr
+}
+
+func (s *segmentedStream) XORKeyStream(dst, src []byte) {
+	for len(src) > 0 {
+		if s.left == 0 {
+			s.next()
+		}
+		n := min(int64(len(src)), s.left)
+		s.stream.XORKeyStream(dst[:n], src[:n])
+		dst, src = dst[n:], src[n:]
+		s.left -= n
+	}
+}
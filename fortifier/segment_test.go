@@ -0,0 +1,83 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestSegmentedStream(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := make([]byte, 3*minSegmentSize+100)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, mode := range []CipherModeName{CipherModeAes256CTR, CipherModeAes256OFB, CipherModeAes256CFB} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		if err := f.SetSegmentSize(minSegmentSize); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		path := testEncryptFile(t, f, mode, t.TempDir(), plaintext)
+		in, layout := testReadLayout(t, path)
+		_ = in.Close()
+		if layout.Metadata().Segment != minSegmentSize {
+			t.Fatalf("bad segment: %d", layout.Metadata().Segment)
+		}
+		actual, err := testDecryptRsaFile(t, path, pri)
+		if err != nil {
+			t.Fatalf("%s err: %v", mode, err)
+		}
+		if !bytes.Equal(actual, plaintext) {
+			t.Fatalf("%s bad: plaintext mismatch", mode)
+		}
+	}
+}
+
+func TestSegmentedStreamSubkeys(t *testing.T) {
+	raw := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	f := &Fortifier{key: &CipherKeyData{raw: raw}}
+	stream, err := f.newStream(CipherMode{Name: CipherModeAes256CTR, SteamMaker: cipher.NewCTR}, iv, minSegmentSize)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	keystream := make([]byte, 3*minSegmentSize)
+	stream.XORKeyStream(keystream, keystream)
+	for index := uint64(0); index < 3; index++ {
+		info := binary.BigEndian.AppendUint64([]byte(segmentKeyLabel), index)
+		key := make([]byte, 32)
+		if _, err = io.ReadFull(hkdf.New(sha256.New, raw, iv, info), key); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		expect := make([]byte, minSegmentSize)
+		cipher.NewCTR(block, iv).XORKeyStream(expect, expect)
+		if !bytes.Equal(expect, keystream[index*minSegmentSize:(index+1)*minSegmentSize]) {
+			t.Fatalf("bad keystream of segment %d", index)
+		}
+	}
+}
+
+func TestSetSegmentSizeInvalid(t *testing.T) {
+	f := NewFortifierWithRsa(false, nil, nil)
+	for _, size := range []int64{-16, 10, 16, 64, 1024, minSegmentSize + 8} {
+		if err := f.SetSegmentSize(size); !errors.Is(err, ErrSegmentSize) {
+			t.Fatalf("expect ErrSegmentSize for %d, got %v", size, err)
+		}
+	}
+	// a crafted head is refused before any subkey is derived
+	if _, err := f.newStream(CipherMode{Name: CipherModeAes256CTR, SteamMaker: cipher.NewCTR}, make([]byte, aes.BlockSize), 16); !errors.Is(err, ErrSegmentSize) {
+		t.Fatalf("expect ErrSegmentSize, got %v", err)
+	}
+}
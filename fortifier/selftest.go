@@ -0,0 +1,110 @@
+package fortifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/i3ash/fortify/sss"
+	"github.com/i3ash/fortify/utils"
+)
+
+// ErrSelfTest means the cipher key was not recovered from the metadata it was
+// just wrapped into, see SetSelfTest.
+var ErrSelfTest = errors.New("self-test failed")
+
+// SetSelfTest enables recovering the cipher key from the metadata right after
+// it is wrapped, before anything is written, to catch a faulty random source
+// or encoding leaving the file unrecoverable. It costs a full recovery, from a
+// copy of the metadata as written: the RSA kind recovers with the private key
+// bytes of one of its recipients, which are required for it, or of both
+// parties of a joint recovery, the mnemonic kind derives the wrapping key
+// again, prompting again for a mnemonic not given, the DPAPI kind unprotects
+// its blob, and the SSS kind recombines threshold shares before they are
+// written. A passphrase factor is prompted for again. The OIDC kind wraps
+// nothing recoverable without the unwrap service, so it does not support it.
+func (f *Fortifier) SetSelfTest(b bool, privateKeys ...[]byte) error {
+	if !b {
+		f.selfTest, f.selfTestKeys = false, nil
+		return nil
+	}
+	privateKeys = slices.DeleteFunc(slices.Clone(privateKeys), func(k []byte) bool { return len(k) == 0 })
+	switch f.key.kind {
+	case CipherKeyKindRSA:
+		if len(privateKeys) == 0 {
+			return fmt.Errorf("self-test of cipher key kind %s requires a private key", f.key.kind)
+		}
+	case CipherKeyKindMnemonic, CipherKeyKindDPAPI, CipherKeyKindSSS:
+	default:
+		return fmt.Errorf("self-test does not support cipher key kind %s", f.key.kind)
+	}
+	f.selfTest, f.selfTestKeys = true, privateKeys
+	return nil
+}
+
+// checkSelfTest recovers the cipher key just wrapped from a copy of the
+// metadata, if SetSelfTest is enabled.
+func (f *Fortifier) checkSelfTest() error {
+	if !f.selfTest {
+		return nil
+	}
+	written, err := json.Marshal(f.meta)
+	if err != nil {
+		return err
+	}
+	meta := &Metadata{}
+	if err = json.Unmarshal(written, meta); err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTest, err)
+	}
+	meta.migrate()
+	var g *Fortifier
+	switch f.key.kind {
+	case CipherKeyKindRSA:
+		g = NewFortifierWithRsa(false, meta, f.selfTestKeys[0])
+		if len(f.selfTestKeys) > 1 {
+			g = NewFortifierWithRsaKeyring(false, meta, f.selfTestKeys)
+		}
+	case CipherKeyKindMnemonic:
+		g = NewFortifierWithMnemonic(false, meta, f.key.bytes)
+	case CipherKeyKindDPAPI:
+		g = NewFortifierWithDpapi(false, meta)
+	default:
+		return fmt.Errorf("self-test does not support cipher key kind %s", f.key.kind)
+	}
+	g.passphrase = f.passphrase
+	if err = g.SetupKey(); err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTest, err)
+	}
+	defer clear(g.key.raw)
+	if !bytes.Equal(g.key.raw, f.key.raw) {
+		return fmt.Errorf("%w: recovered another cipher key", ErrSelfTest)
+	}
+	return nil
+}
+
+// checkSssSelfTest recombines the first and the last threshold shares, if
+// SetSelfTest is enabled, and checks both give the cipher key of the digest
+// recorded.
+func (f *Fortifier) checkSssSelfTest(parts []sss.Part) error {
+	if !f.selfTest {
+		return nil
+	}
+	threshold := int(f.meta.Sss.Threshold)
+	if threshold == 0 || threshold > len(parts) {
+		return fmt.Errorf("%w: %d shares under the threshold of %d", ErrSelfTest, len(parts), threshold)
+	}
+	for _, shares := range [][]sss.Part{parts[:threshold], parts[len(parts)-threshold:]} {
+		raw, err := sss.Combine(shares)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrSelfTest, err)
+		}
+		recovered := bytes.Equal(raw, f.key.raw) && utils.ComputeDigest(raw) == f.meta.Sss.Digest
+		clear(raw)
+		if !recovered {
+			return fmt.Errorf("%w: recovered another cipher key", ErrSelfTest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package fortifier
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+func TestSelfTest(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetSelfTest(true, pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	if _, err := testDecryptRsaFile(t, path, pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	f = NewFortifierWithMnemonic(false, nil, []byte(testMnemonic))
+	if err := f.SetSelfTest(true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	f = NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetSelfTest(true, testOtherRsaPem(t)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("expect self-test error, got %v", err)
+	}
+	if err := NewFortifierWithRsa(false, nil, pub).SetSelfTest(true, nil); err == nil {
+		t.Fatalf("expect error")
+	}
+	if err := NewFortifierWithOidc(false, nil, pub).SetSelfTest(true, nil); err == nil {
+		t.Fatalf("expect error")
+	}
+}
+
+func TestSelfTestSss(t *testing.T) {
+	parts := testSssParts(t)
+	f := NewFortifierWithSss(false, false, parts)
+	if err := f.SetSelfTest(true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f = NewFortifierWithSss(false, false, parts)
+	f.meta.Sss.Digest = utils.ComputeDigest([]byte("another key"))
+	if err := f.SetSelfTest(true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("expect self-test error, got %v", err)
+	}
+
+	// the shares generated are recombined before they are written
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	f = NewFortifierWithSss(false, false, nil)
+	if err = f.SetSelfTest(true, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestSelfTestFaultyEncoder(t *testing.T) {
+	encode := encodeCiphertext
+	t.Cleanup(func() { encodeCiphertext = encode })
+	encodeCiphertext = func(b []byte) string {
+		faulty := []byte(base64.URLEncoding.EncodeToString(b))
+		faulty[len(faulty)/2] ^= 'A' ^ 'B'
+		return string(faulty)
+	}
+	pub, pri := testRsaPem(t)
+	if err := NewFortifierWithRsa(false, nil, pub).SetupKey(); err != nil {
+		t.Fatalf("faulty encoding went unnoticed without self-test, err: %v", err)
+	}
+	f := NewFortifierWithRsa(false, nil, pub)
+	if err := f.SetSelfTest(true, pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := f.SetupKey(); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("expect self-test error, got %v", err)
+	}
+}
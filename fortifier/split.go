@@ -0,0 +1,133 @@
+package fortifier
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+var ErrSplitMismatch = errors.New("sidecar and payload do not match")
+
+// Sidecar is the metadata file (e.g. file.meta.json) of a fortified file split
+// into its head and its payload (e.g. file.enc). PayloadDigest links the two.
+type Sidecar struct {
+	Head          string `json:"head"`
+	PayloadDigest string `json:"payload_digest"`
+}
+
+// Manifest lists the two files of a split fortified file with their digests.
+type Manifest struct {
+	Sidecar       string `json:"sidecar"`
+	SidecarDigest string `json:"sidecar_digest"`
+	Payload       string `json:"payload"`
+	PayloadDigest string `json:"payload_digest"`
+}
+
+// SplitFile reads a fortified file from container, writing its payload to
+// payload and then the sidecar describing its head to sidecar.
+func SplitFile(container io.Reader, sidecar, payload io.Writer) error {
+	layout := &FileLayout{}
+	if err := layout.ReadHeadIn(container); err != nil {
+		return err
+	}
+	head, err := layout.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	digest, err := utils.ComputeReaderDigest(io.TeeReader(container, payload))
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(sidecar).Encode(&Sidecar{
+		Head:          base64.StdEncoding.EncodeToString(head),
+		PayloadDigest: digest,
+	})
+}
+
+// ReadSidecar reads a sidecar written by SplitFile, returning the layout of its head.
+func ReadSidecar(r io.Reader) (*FileLayout, *Sidecar, error) {
+	s := &Sidecar{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, nil, fmt.Errorf("invalid sidecar -- %w", err)
+	}
+	head, err := base64.StdEncoding.DecodeString(s.Head)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid sidecar head -- %w", err)
+	}
+	layout := &FileLayout{}
+	if err = layout.UnmarshalBinary(head); err != nil {
+		return nil, nil, err
+	}
+	return layout, s, nil
+}
+
+// Verify checks that payload is the one the sidecar was split with.
+func (s *Sidecar) Verify(payload io.Reader) error {
+	digest, err := utils.ComputeReaderDigest(payload)
+	if err != nil {
+		return err
+	}
+	if digest != s.PayloadDigest {
+		return fmt.Errorf("%w: payload digest", ErrSplitMismatch)
+	}
+	return nil
+}
+
+// NewManifest makes the manifest of a split fortified file from the names and
+// contents of its sidecar and payload.
+func NewManifest(sidecarName string, sidecar io.Reader, payloadName string, payload io.Reader) (*Manifest, error) {
+	sidecarDigest, err := utils.ComputeReaderDigest(sidecar)
+	if err != nil {
+		return nil, err
+	}
+	payloadDigest, err := utils.ComputeReaderDigest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Sidecar:       sidecarName,
+		SidecarDigest: sidecarDigest,
+		Payload:       payloadName,
+		PayloadDigest: payloadDigest,
+	}, nil
+}
+
+// Verify checks the contents of the sidecar and payload against the manifest.
+func (m *Manifest) Verify(sidecar, payload io.Reader) error {
+	if digest, err := utils.ComputeReaderDigest(sidecar); err != nil {
+		return err
+	} else if digest != m.SidecarDigest {
+		return fmt.Errorf("%w: sidecar digest of %s", ErrSplitMismatch, m.Sidecar)
+	}
+	if digest, err := utils.ComputeReaderDigest(payload); err != nil {
+		return err
+	} else if digest != m.PayloadDigest {
+		return fmt.Errorf("%w: payload digest of %s", ErrSplitMismatch, m.Payload)
+	}
+	return nil
+}
+
+// DecryptSplit decrypts the payload of a split fortified file after checking
+// it against the sidecar read by ReadSidecar. f must be made with the metadata
+// of the layout. The payload is rewound after the check.
+func (f *Fortifier) DecryptSplit(layout *FileLayout, sidecar *Sidecar, payload io.ReadSeeker, w io.Writer) error {
+	if err := sidecar.Verify(payload); err != nil {
+		return err
+	}
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := f.OpenMetadata(layout); err != nil {
+		return err
+	}
+	mode := layout.Metadata().Mode
+	dec := NewDecrypter(mode, f)
+	if dec == nil {
+		return fmt.Errorf("unknown cipher mode name: %s", mode)
+	}
+	return dec.Decrypt(payload, w, layout)
+}
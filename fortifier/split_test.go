@@ -0,0 +1,82 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func testSplitFile(t testing.TB, path string) (sidecar, payload []byte) {
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() { _ = in.Close() }()
+	var s, p bytes.Buffer
+	if err = SplitFile(in, &s, &p); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return s.Bytes(), p.Bytes()
+}
+
+func TestSplitFile(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	plaintext := bytes.Repeat([]byte("split apart "), 100)
+	for _, seal := range []bool{false, true} {
+		f := NewFortifierWithRsa(false, nil, pub)
+		f.SetSealMetadata(seal)
+		sidecar, payload := testSplitFile(t, testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext))
+
+		manifest, err := NewManifest("file.meta.json", bytes.NewReader(sidecar), "file.enc", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err = manifest.Verify(bytes.NewReader(sidecar), bytes.NewReader(payload)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		layout, s, err := ReadSidecar(bytes.NewReader(sidecar))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var out bytes.Buffer
+		if err = NewFortifierWithRsa(false, layout.Metadata(), pri).DecryptSplit(layout, s, bytes.NewReader(payload), &out); err != nil {
+			t.Fatalf("seal %v err: %v", seal, err)
+		}
+		if !bytes.Equal(out.Bytes(), plaintext) {
+			t.Fatalf("seal %v bad payload", seal)
+		}
+	}
+}
+
+func TestSplitFileMismatch(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	dir := t.TempDir()
+	sidecar, payload := testSplitFile(t, testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, dir, []byte("first")))
+	otherSidecar, otherPayload := testSplitFile(t, testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, dir, []byte("other")))
+
+	manifest, err := NewManifest("file.meta.json", bytes.NewReader(sidecar), "file.enc", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err = manifest.Verify(bytes.NewReader(otherSidecar), bytes.NewReader(payload)); !errors.Is(err, ErrSplitMismatch) {
+		t.Fatalf("expect ErrSplitMismatch, got %v", err)
+	}
+	if err = manifest.Verify(bytes.NewReader(sidecar), bytes.NewReader(otherPayload)); !errors.Is(err, ErrSplitMismatch) {
+		t.Fatalf("expect ErrSplitMismatch, got %v", err)
+	}
+
+	layout, s, err := ReadSidecar(bytes.NewReader(sidecar))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out bytes.Buffer
+	err = NewFortifierWithRsa(false, layout.Metadata(), pri).DecryptSplit(layout, s, bytes.NewReader(otherPayload), &out)
+	if !errors.Is(err, ErrSplitMismatch) {
+		t.Fatalf("expect ErrSplitMismatch, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output")
+	}
+}
@@ -0,0 +1,74 @@
+package fortifier
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SSHAuthSockEnv names the environment variable holding the socket path of the SSH agent.
+const SSHAuthSockEnv = "SSH_AUTH_SOCK"
+
+// DialAgent connects to the SSH agent of SSHAuthSockEnv. The caller closes the
+// connection once done with the agent.
+func DialAgent() (agent.ExtendedAgent, net.Conn, error) {
+	sock := os.Getenv(SSHAuthSockEnv)
+	if sock == "" {
+		return nil, nil, fmt.Errorf("no ssh agent: %s is not set", SSHAuthSockEnv)
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh agent: %w", err)
+	}
+	return agent.NewClient(conn), conn, nil
+}
+
+// ResolveAgentRecipient returns, as an authorized key line for AddRecipient,
+// the public key of the only identity listed by the SSH agent matching the
+// query: a fingerprint, either "SHA256:..." as printed by ssh-add -l or as
+// returned by PublicKeyFingerprint, or else a substring of the comment, such as
+// "work@laptop". Only the public half leaves the agent.
+func ResolveAgentRecipient(ag agent.Agent, query string) ([]byte, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("empty ssh agent identity query")
+	}
+	keys, err := ag.List()
+	if err != nil {
+		return nil, fmt.Errorf("ssh agent: %w", err)
+	}
+	byFingerprint := strings.HasPrefix(query, "SHA256:")
+	fingerprint := strings.TrimRight(query, "=")
+	var found []string
+	var kb []byte
+	for _, key := range keys {
+		var match bool
+		if byFingerprint {
+			pub, err := ssh.ParsePublicKey(key.Marshal())
+			if err != nil {
+				continue
+			}
+			cpk, ok := pub.(ssh.CryptoPublicKey)
+			match = ssh.FingerprintSHA256(pub) == fingerprint ||
+				ok && PublicKeyFingerprint(cpk.CryptoPublicKey()) == fingerprint
+		} else {
+			match = strings.Contains(key.Comment, query)
+		}
+		if match {
+			found = append(found, fmt.Sprintf("%s %s", ssh.FingerprintSHA256(key), key.Comment))
+			kb = ssh.MarshalAuthorizedKey(key)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w %s in the ssh agent", ErrRecipientNotFound, query)
+	case 1:
+		return kb, nil
+	default:
+		return nil, fmt.Errorf("%w %s: %s", ErrAmbiguousRecipient, query, strings.Join(found, ", "))
+	}
+}
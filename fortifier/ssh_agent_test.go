@@ -0,0 +1,58 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestResolveAgentRecipient(t *testing.T) {
+	_, pri := testRsaPem(t)
+	_, _, edKey := testEd25519Ssh(t)
+	other, err := NewFortifierWithRsa(false, nil, testOtherRsaPem(t)).parsePrivateKey()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	ag := agent.NewKeyring()
+	for _, k := range []agent.AddedKey{
+		{PrivateKey: testRsaPrivateKey(t), Comment: "alice work@laptop"},
+		{PrivateKey: edKey, Comment: "alice home@desktop"},
+		{PrivateKey: other, Comment: "alice work@server"},
+	} {
+		if err = ag.Add(k); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	kb, err := ResolveAgentRecipient(ag, "work@laptop")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f := NewFortifierWithRsa(false, nil, nil)
+	f.AddRecipient(kb)
+	plaintext := []byte("for the laptop")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), plaintext)
+	actual, err := testDecryptRsaFile(t, path, pri)
+	if err != nil || !bytes.Equal(actual, plaintext) {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+
+	pub := testSshPublicKey(t, &testRsaPrivateKey(t).PublicKey)
+	for _, fingerprint := range []string{ssh.FingerprintSHA256(pub), PublicKeyFingerprint(&testRsaPrivateKey(t).PublicKey)} {
+		if actual, err := ResolveAgentRecipient(ag, fingerprint); err != nil || !bytes.Equal(actual, kb) {
+			t.Fatalf("bad: %q, err: %v", actual, err)
+		}
+	}
+	if _, err = ResolveAgentRecipient(ag, "work@"); !errors.Is(err, ErrAmbiguousRecipient) {
+		t.Fatalf("expect ambiguous, got %v", err)
+	}
+	if _, err = ResolveAgentRecipient(ag, "bob"); !errors.Is(err, ErrRecipientNotFound) {
+		t.Fatalf("expect not found, got %v", err)
+	}
+	if _, err = ResolveAgentRecipient(ag, ""); err == nil {
+		t.Fatalf("expect error")
+	}
+}
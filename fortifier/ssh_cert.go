@@ -0,0 +1,127 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrUntrustedCertificate = errors.New("untrusted ssh certificate")
+
+// SetTrustedCAs restricts the public key for encryption, and those of the
+// additional recipients, to SSH user certificates signed by one of the CA keys
+// and, unless principal is empty, valid for it.
+func (f *Fortifier) SetTrustedCAs(cas []ssh.PublicKey, principal string) {
+	f.sshCAs = cas
+	f.sshPrincipal = principal
+}
+
+// SetRecoverPrincipal restricts the private key recovering the cipher key to one
+// presented with an authorized SSH certificate of its public key among certs,
+// signed by a CA key of SetTrustedCAs and valid for the principal, such as when
+// a server recovers for the client presenting it. Joint recovery requires a
+// certificate for the key of each party. An empty principal lifts the
+// restriction.
+func (f *Fortifier) SetRecoverPrincipal(principal string, certs ...[]byte) {
+	f.recoverPrincipal, f.recoverCerts = principal, certs
+}
+
+// checkRecoverPrincipal checks one of the certificates of SetRecoverPrincipal,
+// if any, certifies the public key of pri for the principal.
+func (f *Fortifier) checkRecoverPrincipal(pri *rsa.PrivateKey) error {
+	if f.recoverPrincipal == "" {
+		return nil
+	}
+	if len(f.recoverCerts) == 0 {
+		return fmt.Errorf("%w: no certificate for principal %s", ErrUntrustedCertificate, f.recoverPrincipal)
+	}
+	var errs []error
+	for _, c := range f.recoverCerts {
+		err := f.checkRecoverCert(pri, c)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (f *Fortifier) checkRecoverCert(pri *rsa.PrivateKey, c []byte) error {
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(c)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedCertificate, err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return fmt.Errorf("%w: %s key is not a certificate", ErrUntrustedCertificate, parsed.Type())
+	}
+	if err = VerifySSHCertificate(cert, ssh.UserCert, f.sshCAs, f.recoverPrincipal, time.Now()); err != nil {
+		return err
+	}
+	certified, err := cryptoPublicKey(cert.Key)
+	if err != nil {
+		return err
+	}
+	if !MatchesPublicKey(pri, certified) {
+		return fmt.Errorf("%w: certificate of another key than the private key", ErrUntrustedCertificate)
+	}
+	return nil
+}
+
+// VerifySSHCertificate checks the certificate is of the type, ssh.UserCert or
+// ssh.HostCert, is signed by one of the trusted CA keys, is within its validity
+// window at now, and is valid for the principal. An empty principal accepts any
+// principal of the certificate.
+func VerifySSHCertificate(cert *ssh.Certificate, certType uint32, cas []ssh.PublicKey, principal string, now time.Time) error {
+	if cert.CertType != certType {
+		return fmt.Errorf("%w: certificate of type %d, requiring %d", ErrUntrustedCertificate, cert.CertType, certType)
+	}
+	trusted := false
+	signer := cert.SignatureKey.Marshal()
+	for _, ca := range cas {
+		if bytes.Equal(signer, ca.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("%w: signed by unknown authority %s", ErrUntrustedCertificate, ssh.FingerprintSHA256(cert.SignatureKey))
+	}
+	if principal == "" && len(cert.ValidPrincipals) > 0 {
+		principal = cert.ValidPrincipals[0]
+	}
+	checker := &ssh.CertChecker{Clock: func() time.Time { return now }}
+	if err := checker.CheckCert(principal, cert); err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedCertificate, err)
+	}
+	return nil
+}
+
+// parseRsaCertificate parses the key bytes as an authorized SSH certificate of
+// an RSA key verified against the trusted CA keys.
+func (f *Fortifier) parseRsaCertificate(kb []byte) (*rsa.PublicKey, error) {
+	parsed, comment, _, _, err := ssh.ParseAuthorizedKey(kb)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUntrustedCertificate, err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s key is not a certificate", ErrUntrustedCertificate, parsed.Type())
+	}
+	if err = VerifySSHCertificate(cert, ssh.UserCert, f.sshCAs, f.sshPrincipal, time.Now()); err != nil {
+		return nil, err
+	}
+	var k any
+	if k, err = cryptoPublicKey(cert.Key); err != nil {
+		return nil, err
+	}
+	if pub, ok := k.(*rsa.PublicKey); ok {
+		f.noteKeyInfo(pub, KeyInfo{Comment: comment, Principals: cert.ValidPrincipals})
+		return pub, nil
+	}
+	return nil, fmt.Errorf("%w: certificate of %s key, requiring rsa", ErrKeyAlgorithm, cert.Key.Type())
+}
@@ -0,0 +1,171 @@
+package fortifier
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testSshSigner(t testing.TB) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return signer
+}
+
+func testSshCertificate(t testing.TB, ca ssh.Signer, validBefore time.Time, principals ...string) *ssh.Certificate {
+	return testSshCertificateOf(t, ca, &testRsaPrivateKey(t).PublicKey, validBefore, principals...)
+}
+
+func testSshCertificateOf(t testing.TB, ca ssh.Signer, pub *rsa.PublicKey, validBefore time.Time, principals ...string) *ssh.Certificate {
+	cert := &ssh.Certificate{
+		Key:             testSshPublicKey(t, pub),
+		CertType:        ssh.UserCert,
+		KeyId:           "fortify test",
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(time.Now().Add(-2 * time.Hour).Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return cert
+}
+
+func TestVerifySSHCertificate(t *testing.T) {
+	ca, other := testSshSigner(t), testSshSigner(t)
+	cas := []ssh.PublicKey{ca.PublicKey()}
+	now := time.Now()
+
+	valid := testSshCertificate(t, ca, now.Add(time.Hour), "alice")
+	if err := VerifySSHCertificate(valid, ssh.UserCert, cas, "alice", now); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := VerifySSHCertificate(valid, ssh.UserCert, cas, "bob", now); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+	wrongCa := testSshCertificate(t, other, now.Add(time.Hour), "alice")
+	if err := VerifySSHCertificate(wrongCa, ssh.UserCert, cas, "alice", now); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+	expired := testSshCertificate(t, ca, now.Add(-time.Hour), "alice")
+	if err := VerifySSHCertificate(expired, ssh.UserCert, cas, "alice", now); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+	host := testSshCertificate(t, ca, now.Add(time.Hour), "alice")
+	host.CertType = ssh.HostCert
+	if err := host.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := VerifySSHCertificate(host, ssh.UserCert, cas, "alice", now); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+	if err := VerifySSHCertificate(valid, ssh.HostCert, cas, "alice", now); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+}
+
+func TestTrustedCAs(t *testing.T) {
+	ca, other := testSshSigner(t), testSshSigner(t)
+	_, pri := testRsaPem(t)
+	cert := ssh.MarshalAuthorizedKey(testSshCertificate(t, ca, time.Now().Add(time.Hour), "alice"))
+
+	f := NewFortifierWithRsa(false, nil, cert)
+	f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "alice")
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("certified"))
+	if _, err := testDecryptRsaFile(t, path, pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	f = NewFortifierWithRsa(false, nil, cert)
+	f.SetTrustedCAs([]ssh.PublicKey{other.PublicKey()}, "")
+	if err := f.SetupKey(); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+	pub, _ := testRsaPem(t)
+	f = NewFortifierWithRsa(false, nil, pub)
+	f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "")
+	if err := f.SetupKey(); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+}
+
+func TestTrustedCAsRecipients(t *testing.T) {
+	ca, other := testSshSigner(t), testSshSigner(t)
+	now := time.Now()
+	trusted := ssh.MarshalAuthorizedKey(testSshCertificate(t, ca, now.Add(time.Hour), "alice"))
+	pub, _ := testRsaPem(t)
+	for name, extra := range map[string][]byte{
+		"untrusted": ssh.MarshalAuthorizedKey(testSshCertificate(t, other, now.Add(-time.Hour), "mallory")),
+		"plain":     pub,
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := NewFortifierWithRsa(false, nil, trusted)
+			f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "alice")
+			f.AddRecipient(extra)
+			if err := f.SetupKey(); !errors.Is(err, ErrUntrustedCertificate) {
+				t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+			}
+		})
+	}
+	f := NewFortifierWithRsa(false, nil, trusted)
+	f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "alice")
+	f.AddRecipient(ssh.MarshalAuthorizedKey(testSshCertificate(t, ca, now.Add(time.Hour), "alice")))
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestCertificatePrincipals(t *testing.T) {
+	ca := testSshSigner(t)
+	_, pri := testRsaPem(t)
+	cert := ssh.MarshalAuthorizedKey(testSshCertificate(t, ca, time.Now().Add(time.Hour), "alice", "ops"))
+	if _, info, err := ParsePublicKey(cert); err != nil {
+		t.Fatalf("err: %v", err)
+	} else if !slices.Equal(info.Principals, []string{"alice", "ops"}) {
+		t.Fatalf("bad: %v", info.Principals)
+	}
+
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, cert), CipherModeAes256CTR, t.TempDir(), []byte("certified"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if got := layout.Metadata().Recipients[0].Principals; !slices.Equal(got, []string{"alice", "ops"}) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	for principal, ok := range map[string]bool{"ops": true, "admin": false} {
+		t.Run(principal, func(t *testing.T) {
+			in, layout := testReadLayout(t, path)
+			defer func() { _ = in.Close() }()
+			f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+			f.SetTrustedCAs([]ssh.PublicKey{ca.PublicKey()}, "")
+			f.SetRecoverPrincipal(principal, cert)
+			err := f.SetupKey()
+			if ok && err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !ok && !errors.Is(err, ErrUntrustedCertificate) {
+				t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+			}
+		})
+	}
+
+	in, layout = testReadLayout(t, path)
+	defer func() { _ = in.Close() }()
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	f.SetRecoverPrincipal("ops", cert)
+	if err := f.SetupKey(); !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("expect ErrUntrustedCertificate, got: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package fortifier
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownSuite = errors.New("unknown cipher suite")
+
+// Suite is the combination of key wrapping and payload cipher of a fortified
+// file, named like "RSA-OAEP-SHA256+AES256-CTR-HMAC-SHA256".
+type Suite struct {
+	Key    CipherKeyKind
+	Scheme RsaScheme // RSA-OAEP if empty, see SetRsaScheme
+	Mode   CipherModeName
+}
+
+type suiteKey struct {
+	kind   CipherKeyKind
+	scheme RsaScheme
+}
+
+var suiteKeyNames = map[suiteKey]string{
+	{CipherKeyKindRSA, ""}:              "RSA-OAEP-SHA256",
+	{CipherKeyKindRSA, RsaSchemeKEM}:    "RSA-KEM-HKDF-SHA256",
+	{CipherKeyKindRSA, RsaSchemeAgeSSH}: "AGE-SSH",
+	{CipherKeyKindRSA, RsaSchemePGP}:    "OPENPGP-RSA-AES256",
+	{CipherKeyKindSSS, ""}:              "SSS-GF256",
+	{CipherKeyKindMnemonic, ""}:         "BIP39-BIP32-AES256-GCM",
+	{CipherKeyKindDPAPI, ""}:            "DPAPI",
+	{CipherKeyKindOIDC, ""}:             "OIDC-RSA-OAEP-SHA256",
+}
+
+var suiteModeNames = map[CipherModeName]string{
+	CipherModeAes256CTR: "AES256-CTR-HMAC-SHA256",
+	CipherModeAes256OFB: "AES256-OFB-HMAC-SHA256",
+	CipherModeAes256CFB: "AES256-CFB-HMAC-SHA256",
+}
+
+// suites registers every supported suite by name.
+var suites = func() map[string]Suite {
+	m := make(map[string]Suite)
+	for key := range suiteKeyNames {
+		for mode := range suiteModeNames {
+			s := Suite{Key: key.kind, Scheme: key.scheme, Mode: mode}
+			m[s.String()] = s
+		}
+	}
+	return m
+}()
+
+func (s Suite) String() string {
+	return suiteKeyNames[suiteKey{s.Key, s.Scheme}] + "+" + suiteModeNames[s.Mode]
+}
+
+// Suites lists the names of the supported suites.
+func Suites() []string {
+	return slices.Sorted(maps.Keys(suites))
+}
+
+// ParseSuite looks the suite name up in the registry of supported suites.
+func ParseSuite(name string) (Suite, error) {
+	s, ok := suites[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return Suite{}, fmt.Errorf("%w: %q", ErrUnknownSuite, name)
+	}
+	return s, nil
+}
+
+// applySuite checks the suite of the metadata, if any, against its key kind and
+// cipher mode, filling in whichever is missing.
+func (m *Metadata) applySuite() error {
+	if m.Suite == "" {
+		return nil
+	}
+	s, err := ParseSuite(m.Suite)
+	if err != nil {
+		return err
+	}
+	if m.Key == "" {
+		m.Key = s.Key
+	}
+	if m.Mode == "" && m.Sealed == "" {
+		m.Mode = s.Mode
+	}
+	if m.Key != s.Key || (m.Mode != "" && m.Mode != s.Mode) {
+		return fmt.Errorf("suite %s mismatches key %s and mode %s", m.Suite, m.Key, m.Mode)
+	}
+	return nil
+}
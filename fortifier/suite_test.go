@@ -0,0 +1,59 @@
+package fortifier
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSuite(t *testing.T) {
+	for name, expect := range map[string]Suite{
+		"RSA-OAEP-SHA256+AES256-CTR-HMAC-SHA256":     {CipherKeyKindRSA, "", CipherModeAes256CTR},
+		"RSA-KEM-HKDF-SHA256+AES256-OFB-HMAC-SHA256": {CipherKeyKindRSA, RsaSchemeKEM, CipherModeAes256OFB},
+		"sss-gf256+aes256-cfb-hmac-sha256":           {CipherKeyKindSSS, "", CipherModeAes256CFB},
+	} {
+		s, err := ParseSuite(name)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if s != expect {
+			t.Fatalf("expect %+v, actual %+v", expect, s)
+		}
+	}
+	for _, name := range []string{"", "X25519-HKDF-SHA256+CHACHA20POLY1305", "RSA-OAEP-SHA256+A256GCM", "RSA-OAEP-SHA256"} {
+		if _, err := ParseSuite(name); !errors.Is(err, ErrUnknownSuite) {
+			t.Fatalf("%q: expect ErrUnknownSuite, got %v", name, err)
+		}
+	}
+	if n := len(Suites()); n != len(suiteKeyNames)*len(suiteModeNames) {
+		t.Fatalf("unexpected number of suites: %d", n)
+	}
+}
+
+func TestMetadataSuite(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256OFB, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if suite := layout.Metadata().Suite; suite != "RSA-OAEP-SHA256+AES256-OFB-HMAC-SHA256" {
+		t.Fatalf("bad suite: %s", suite)
+	}
+	if _, err := testDecryptRsaFile(t, path, pri); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	m := &Metadata{Suite: "SSS-GF256+AES256-CTR-HMAC-SHA256"}
+	if err := m.applySuite(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if m.Key != CipherKeyKindSSS || m.Mode != CipherModeAes256CTR {
+		t.Fatalf("unexpected key %s and mode %s", m.Key, m.Mode)
+	}
+	m.Mode = CipherModeAes256OFB
+	if err := m.applySuite(); err == nil {
+		t.Fatalf("expect error")
+	}
+	m = &Metadata{Key: CipherKeyKindRSA, Suite: "RSA-OAEP-SHA256+A256GCM"}
+	if err := m.applySuite(); !errors.Is(err, ErrUnknownSuite) {
+		t.Fatalf("expect ErrUnknownSuite, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package fortifier
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrCorruptedRecipient = errors.New("corrupted recipient")
+
+// Verify checks the metadata of a layout read by ReadHeadIn without any key,
+// reporting which key entry is damaged.
+func (f *FileLayout) Verify() error {
+	meta := f.Metadata()
+	if meta == nil {
+		return errors.New("missing metadata")
+	}
+	switch meta.Key {
+	case CipherKeyKindRSA:
+		if meta.Rsa == nil && len(meta.Recipients) == 0 && meta.Joint == nil {
+			return fmt.Errorf("rsa recipient: %w: missing", ErrCorruptedRecipient)
+		}
+		if i := slices.Index(meta.Recipients, nil); i >= 0 {
+			if meta.Rsa != nil {
+				i++
+			}
+			return fmt.Errorf("rsa recipient %d: %w: missing", i+1, ErrCorruptedRecipient)
+		}
+		for i, m := range meta.rsaRecipients() {
+			name := "rsa recipient"
+			if i > 0 {
+				name = fmt.Sprintf("rsa recipient %d", i+1)
+			}
+			if m.Label != "" {
+				name += fmt.Sprintf(" (%s)", m.Label)
+			}
+			if _, err := m.EmbeddedPublicKey(); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			for _, w := range m.wrappedKeys() {
+				if _, _, err := w.decode(); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+		if j := meta.Joint; j != nil {
+			if i := slices.Index(j.Shares, nil); i >= 0 {
+				return fmt.Errorf("joint share %d: %w: missing", i+1, ErrCorruptedRecipient)
+			}
+			for i, s := range j.Shares {
+				for _, w := range s.wrappedKeys() {
+					if _, _, err := w.decode(); err != nil {
+						return fmt.Errorf("joint share %d: %w", i+1, err)
+					}
+				}
+			}
+		}
+	case CipherKeyKindMnemonic:
+		if meta.Mnemonic == nil || meta.Mnemonic.Digest == "" {
+			return fmt.Errorf("mnemonic recipient: %w: missing digest", ErrCorruptedRecipient)
+		}
+		if _, err := parseBip32Path(meta.Mnemonic.Path); err != nil {
+			return fmt.Errorf("mnemonic recipient: %w: %v", ErrCorruptedRecipient, err)
+		}
+		if _, err := base64.URLEncoding.DecodeString(meta.Mnemonic.Ciphertext); err != nil {
+			return fmt.Errorf("mnemonic recipient: %w: invalid ciphertext", ErrCorruptedRecipient)
+		}
+	case CipherKeyKindDPAPI:
+		if meta.Dpapi == nil || meta.Dpapi.Digest == "" {
+			return fmt.Errorf("dpapi recipient: %w: missing digest", ErrCorruptedRecipient)
+		}
+		if _, err := base64.StdEncoding.DecodeString(meta.Dpapi.Blob); err != nil || meta.Dpapi.Blob == "" {
+			return fmt.Errorf("dpapi recipient: %w: invalid blob", ErrCorruptedRecipient)
+		}
+	case CipherKeyKindOIDC:
+		if meta.Oidc == nil || meta.Oidc.Digest == "" || meta.Oidc.Service == "" {
+			return fmt.Errorf("oidc recipient: %w: missing digest or service", ErrCorruptedRecipient)
+		}
+		if _, err := base64.URLEncoding.DecodeString(meta.Oidc.Ciphertext); err != nil || meta.Oidc.Ciphertext == "" {
+			return fmt.Errorf("oidc recipient: %w: invalid ciphertext", ErrCorruptedRecipient)
+		}
+	case CipherKeyKindSSS:
+		if meta.Sss == nil || meta.Sss.Digest == "" {
+			return fmt.Errorf("sss recipient: %w: missing digest", ErrCorruptedRecipient)
+		}
+	default:
+		return fmt.Errorf("unknown cipher key kind: %s", meta.Key)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package fortifier
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCorruptedRecipient(t *testing.T) {
+	pub, pri := testRsaPem(t)
+	path := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if err := layout.Verify(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	meta := layout.Metadata()
+	c := []byte(meta.Recipients[0].Ciphertext)
+	if c[10] == 'A' {
+		c[10] = 'B'
+	} else {
+		c[10] = 'A'
+	}
+	meta.Recipients[0].Ciphertext = string(c)
+	err := layout.Verify()
+	if !errors.Is(err, ErrCorruptedRecipient) || !strings.HasPrefix(err.Error(), "rsa recipient") {
+		t.Fatalf("expect corrupted rsa recipient, got: %v", err)
+	}
+	if err = NewFortifierWithRsa(false, meta, pri).SetupKey(); !errors.Is(err, ErrCorruptedRecipient) {
+		t.Fatalf("expect ErrCorruptedRecipient, got: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type VerifyStatus string
+
+const (
+	VerifyStatusValid   VerifyStatus = "valid"
+	VerifyStatusInvalid VerifyStatus = "invalid"
+	VerifyStatusSkipped VerifyStatus = "skipped"
+)
+
+// VerifyResult is the outcome of verifying one file, Err is set when invalid.
+type VerifyResult struct {
+	Status VerifyStatus
+	Err    error
+}
+
+// VerifyTree walks the directory tree of root and verifies every fortified file
+// in it without any key, with at most Concurrency files open at a time. Files
+// without the fortified magic number are reported as skipped. The returned
+// report maps the path of every regular file to its result.
+func VerifyTree(root string) (map[string]VerifyResult, error) {
+	paths := make(chan string)
+	report := make(map[string]VerifyResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range Concurrency() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				result := verifyFile(path)
+				mu.Lock()
+				report[path] = result
+				mu.Unlock()
+			}
+		}()
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths <- path
+		}
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// verifyFile checks the head of the file with Verify and that its size matches
+// the data length recorded in the head.
+func verifyFile(path string) VerifyResult {
+	invalid := func(err error) VerifyResult {
+		return VerifyResult{Status: VerifyStatusInvalid, Err: err}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return invalid(err)
+	}
+	defer func() { _ = file.Close() }()
+	var magic uint32
+	if err = binary.Read(file, layoutByteOrder, &magic); err != nil || !isFortifiedMagic(magic) {
+		return VerifyResult{Status: VerifyStatusSkipped}
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return invalid(err)
+	}
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(file); err != nil {
+		return invalid(err)
+	}
+	if err = layout.Verify(); err != nil {
+		return invalid(err)
+	}
+	var head []byte
+	if head, err = layout.MarshalBinary(); err != nil {
+		return invalid(err)
+	}
+	var stat os.FileInfo
+	if stat, err = file.Stat(); err != nil {
+		return invalid(err)
+	}
+	expect := int64(len(head)) + aes.BlockSize + int64(layout.DataLength())
+	if stat.Size() != expect {
+		return invalid(fmt.Errorf("expect file size %d, not %d", expect, stat.Size()))
+	}
+	return VerifyResult{Status: VerifyStatusValid}
+}
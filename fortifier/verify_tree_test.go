@@ -0,0 +1,48 @@
+package fortifier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyTree(t *testing.T) {
+	pub, _ := testRsaPem(t)
+	root := t.TempDir()
+	valid := testEncryptFile(t, NewFortifierWithRsa(false, nil, pub), CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	content, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	nested := filepath.Join(root, "nested")
+	if err = os.Mkdir(nested, 0700); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	paths := map[string]VerifyStatus{
+		testWriteFile(t, root, "valid.data", content):                        VerifyStatusValid,
+		testWriteFile(t, nested, "truncated.data", content[:len(content)-1]): VerifyStatusInvalid,
+		testWriteFile(t, nested, "head.data", content[:40]):                  VerifyStatusInvalid,
+		testWriteFile(t, root, "notes.txt", []byte("not fortified")):         VerifyStatusSkipped,
+		testWriteFile(t, root, "tiny", []byte{0x40}):                         VerifyStatusSkipped,
+		testWriteFile(t, root, "empty", nil):                                 VerifyStatusSkipped,
+	}
+	report, err := VerifyTree(root)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(report) != len(paths) {
+		t.Fatalf("expect %d results, not %d", len(paths), len(report))
+	}
+	for path, status := range paths {
+		result := report[path]
+		if result.Status != status {
+			t.Fatalf("%s: expect %s, not %s (%v)", path, status, result.Status, result.Err)
+		}
+		if (result.Err != nil) != (status == VerifyStatusInvalid) {
+			t.Fatalf("%s: unexpected err: %v", path, result.Err)
+		}
+	}
+	if _, err = VerifyTree(filepath.Join(root, "missing")); err == nil {
+		t.Fatalf("expect error")
+	}
+}
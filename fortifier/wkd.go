@@ -0,0 +1,147 @@
+package fortifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const (
+	wkdTimeout    = 10 * time.Second
+	maxWKDKeySize = 1 << 20
+	zbase32       = "ybndrfg8ejkmcpqxot1uwisza345h769"
+)
+
+// ErrWKDNotFound means neither WKD method published an OpenPGP key for the address.
+var ErrWKDNotFound = errors.New("no key in web key directory")
+
+// AddWKDRecipient looks up the OpenPGP key of the email address in its Web Key
+// Directory, over HTTPS from the well-known URL of the advanced method, then of
+// the direct method, and adds the RSA public key of its encryption subkey as an
+// additional RSA recipient, like AddRecipient. The lookup gives up after 10
+// seconds unless the context ends first, and goes through the client of
+// SetHTTPClient if set. Only RSA OpenPGP keys are accepted.
+func (f *Fortifier) AddWKDRecipient(ctx context.Context, email string) error {
+	pub, err := f.lookupWKD(ctx, email)
+	if err != nil {
+		return fmt.Errorf("%s: wkd %s: %w", rsaFortifier, email, err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	f.AddRecipient(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	return nil
+}
+
+// lookupWKD fetches the keyring of the address and picks the RSA encryption key
+// of the entity holding the address as an identity.
+func (f *Fortifier) lookupWKD(ctx context.Context, email string) (*rsa.PublicKey, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return nil, fmt.Errorf("invalid email address")
+	}
+	local, domain, _ := strings.Cut(email, "@")
+	domain = strings.ToLower(domain)
+	hash := sha1.Sum([]byte(strings.ToLower(local)))
+	query := "?l=" + url.QueryEscape(local)
+	ctx, cancel := context.WithTimeout(ctx, wkdTimeout)
+	defer cancel()
+	var errs []error
+	for _, u := range []string{
+		"https://openpgpkey." + domain + "/.well-known/openpgpkey/" + domain + "/hu/" + zbase32Encode(hash[:]) + query,
+		"https://" + domain + "/.well-known/openpgpkey/hu/" + zbase32Encode(hash[:]) + query,
+	} {
+		keyring, err := f.fetchWKD(ctx, u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return wkdEncryptionKey(keyring, email)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrWKDNotFound, errors.Join(errs...))
+}
+
+func (f *Fortifier) fetchWKD(ctx context.Context, u string) (openpgp.EntityList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWKDKeySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxWKDKeySize {
+		return nil, fmt.Errorf("%s: keyring of more than %d bytes", u, maxWKDKeySize)
+	}
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	return keyring, nil
+}
+
+func wkdEncryptionKey(keyring openpgp.EntityList, email string) (*rsa.PublicKey, error) {
+	for _, e := range keyring {
+		var match bool
+		for _, id := range e.Identities {
+			match = match || id.UserId != nil && strings.EqualFold(id.UserId.Email, email)
+		}
+		if !match {
+			continue
+		}
+		key, ok := e.EncryptionKey(time.Now())
+		if !ok {
+			return nil, errors.New("openpgp key without a valid encryption key")
+		}
+		pub, ok := key.PublicKey.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: openpgp %s encryption key, requiring rsa", ErrKeyAlgorithm, publicKeyAlgorithm(key.PublicKey.PublicKey))
+		}
+		return pub, nil
+	}
+	return nil, errors.New("no openpgp key for the address")
+}
+
+// zbase32Encode encodes the bytes in z-base-32, as WKD names the hashed local
+// part of an address.
+func zbase32Encode(b []byte) string {
+	var sb strings.Builder
+	var acc, n uint
+	for _, c := range b {
+		acc, n = acc<<8|uint(c), n+8
+		for n >= 5 {
+			n -= 5
+			sb.WriteByte(zbase32[acc>>n&31])
+		}
+	}
+	if n > 0 {
+		sb.WriteByte(zbase32[acc<<(5-n)&31])
+	}
+	return sb.String()
+}
@@ -0,0 +1,85 @@
+package fortifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// testWKDServer serves the keyring of the entities on the direct WKD URL of
+// every address, returning a client that reaches it for any host.
+func testWKDServer(t testing.TB, keyring map[string]*openpgp.Entity) *http.Client {
+	mux := http.NewServeMux()
+	for local, e := range keyring {
+		var buf bytes.Buffer
+		if err := e.Serialize(&buf); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		hash := sha1.Sum([]byte(local))
+		mux.HandleFunc("/.well-known/openpgpkey/hu/"+zbase32Encode(hash[:]), func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(buf.Bytes())
+		})
+	}
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	return client
+}
+
+func TestWKDRecipient(t *testing.T) {
+	alice, err := openpgp.NewEntity("Alice", "", "alice@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoRSA, RSABits: 2048})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	bob, err := openpgp.NewEntity("Bob", "", "bob@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	client := testWKDServer(t, map[string]*openpgp.Entity{"alice": alice, "bob": bob})
+
+	f := NewFortifierWithRsa(false, nil, nil)
+	f.SetHTTPClient(client)
+	if err = f.AddWKDRecipient(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("data"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	key, _ := alice.EncryptionKey(alice.PrimaryKey.CreationTime)
+	if fp := layout.Metadata().Recipients[0].Fingerprint; fp != PublicKeyFingerprint(key.PublicKey.PublicKey) {
+		t.Fatalf("unexpected fingerprint: %s", fp)
+	}
+
+	if err = f.AddWKDRecipient(context.Background(), "bob@example.com"); !errors.Is(err, ErrKeyAlgorithm) {
+		t.Fatalf("expect key algorithm error, got %v", err)
+	}
+	if err = f.AddWKDRecipient(context.Background(), "carol@example.com"); !errors.Is(err, ErrWKDNotFound) {
+		t.Fatalf("expect not found, got %v", err)
+	}
+	if err = f.AddWKDRecipient(context.Background(), "Alice <alice@example.com>"); err == nil {
+		t.Fatalf("expect error")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = f.AddWKDRecipient(ctx, "alice@example.com"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect canceled, got %v", err)
+	}
+}
+
+func TestZbase32Encode(t *testing.T) {
+	hash := sha1.Sum([]byte("joe.doe"))
+	if actual := zbase32Encode(hash[:]); actual != "iy9q119eutrkn8s1mk4r39qejnbu3n5q" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -0,0 +1,59 @@
+package fortifier
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var concurrency atomic.Int64
+
+// SetConcurrency bounds the goroutines of the internal worker pools, which wrap
+// the cipher key for several RSA recipients and verify files in VerifyTree.
+// Zero or less restores the default of runtime.GOMAXPROCS(0), read at each use.
+func SetConcurrency(n int) {
+	concurrency.Store(int64(max(n, 0)))
+}
+
+// Concurrency returns the bound of the internal worker pools, see SetConcurrency.
+func Concurrency() int {
+	if n := concurrency.Load(); n > 0 {
+		return int(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// forEachInOrder calls fn with every index below count in turn, returning the
+// first error.
+func forEachInOrder(count int, fn func(i int) error) error {
+	for i := range count {
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEach calls fn with every index below count on at most Concurrency
+// goroutines at a time, returning the error of the lowest index failing.
+func forEach(count int, fn func(i int) error) error {
+	errs := make([]error, count)
+	sem := make(chan struct{}, Concurrency())
+	var wg sync.WaitGroup
+	for i := range count {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
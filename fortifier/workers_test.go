@@ -0,0 +1,62 @@
+package fortifier
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrency(t *testing.T) {
+	t.Cleanup(func() { SetConcurrency(0) })
+	if n := Concurrency(); n != runtime.GOMAXPROCS(0) {
+		t.Fatalf("expect GOMAXPROCS by default, not %d", n)
+	}
+	for _, n := range []int{1, 2, 5} {
+		SetConcurrency(n)
+		var active, peak atomic.Int32
+		err := forEach(20, func(int) error {
+			a := active.Add(1)
+			for p := peak.Load(); a > p && !peak.CompareAndSwap(p, a); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if p := peak.Load(); p > int32(n) || p == 0 {
+			t.Fatalf("concurrency %d: unexpected peak %d", n, p)
+		}
+	}
+
+	SetConcurrency(3)
+	err := forEach(10, func(i int) error {
+		if i == 4 || i == 7 {
+			return errors.New(string(rune('0' + i)))
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "4" {
+		t.Fatalf("expect the error of the lowest index, got %v", err)
+	}
+}
+
+func TestConcurrencyRecipients(t *testing.T) {
+	t.Cleanup(func() { SetConcurrency(0) })
+	SetConcurrency(1)
+	pub, pri := testRsaPem(t)
+	f := NewFortifierWithRsa(false, nil, pub)
+	f.AddRecipient(testPublicPem(t, testOtherRsaPem(t)))
+	path := testEncryptFile(t, f, CipherModeAes256CTR, t.TempDir(), []byte("in order"))
+	in, layout := testReadLayout(t, path)
+	_ = in.Close()
+	if n := len(layout.Metadata().Recipients); n != 2 {
+		t.Fatalf("expect 2 recipients, not %d", n)
+	}
+	if actual, err := testDecryptRsaFile(t, path, pri); err != nil || string(actual) != "in order" {
+		t.Fatalf("bad: %q, err: %v", actual, err)
+	}
+}
@@ -3,14 +3,25 @@ module github.com/i3ash/fortify
 go 1.23.0
 
 require (
+	filippo.io/age v1.2.1
+	github.com/99designs/keyring v1.2.2
+	github.com/ProtonMail/go-crypto v1.5.1
 	github.com/deatil/go-cryptobin v1.0.5028
 	github.com/spf13/cobra v1.9.1
-	golang.org/x/crypto v0.36.0
-	golang.org/x/sys v0.31.0
-	golang.org/x/term v0.30.0
+	golang.org/x/crypto v0.41.0
+	golang.org/x/sys v0.35.0
+	golang.org/x/term v0.34.0
 )
 
 require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/99designs/go-keychain v0.0.0-20191008050251-8e49817e8af4 // indirect
+	github.com/cloudflare/circl v1.6.3 // indirect
+	github.com/danieljoos/wincred v1.1.2 // indirect
+	github.com/dvsekhvalnov/jose2go v1.5.0 // indirect
+	github.com/godbus/dbus v0.0.0-20190726142602-4481cbc300e2 // indirect
+	github.com/gsterjov/go-libsecret v0.0.0-20161001094733-a6f4afe4910c // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/mtibben/percent v0.2.1 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 )
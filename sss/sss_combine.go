@@ -75,7 +75,19 @@ func CombineKeyFiles(args []string) (parts []Part, err error) {
 	return kParts[:count], nil
 }
 
+// CombinePartFiles combines the part files block by block into out.
 func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
+	return combinePartFiles(in, out, truncate, verbose, files.OpenOutputFile)
+}
+
+// CombinePartFilesSecure is CombinePartFiles refusing to write into a directory
+// writable by group or others.
+func CombinePartFilesSecure(in []string, out string, truncate, verbose bool) error {
+	return combinePartFiles(in, out, truncate, verbose, files.OpenSecretOutputFile)
+}
+
+func combinePartFiles(in []string, out string, truncate, verbose bool,
+	openOutput func(string, bool, ...int) (*os.File, func(), error)) error {
 	size := len(in)
 	if size == 0 {
 		return errors.New("no input files")
@@ -84,7 +96,7 @@ func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
 	var oCloseFn func()
 	if len(out) > 0 {
 		var err error
-		if output, oCloseFn, err = files.OpenOutputFile(out, truncate); err != nil {
+		if output, oCloseFn, err = openOutput(out, truncate); err != nil {
 			return err
 		}
 	}
@@ -3,6 +3,7 @@ package utils
 import (
 	"crypto/sha512"
 	"encoding/base64"
+	"io"
 )
 
 // ComputeDigest computes the digest of a byte slice.
@@ -12,3 +13,13 @@ func ComputeDigest(slice []byte) string {
 	digest := base64.URLEncoding.EncodeToString(sha.Sum(nil))
 	return digest
 }
+
+// ComputeReaderDigest computes the digest of everything read from r, in the
+// same form as ComputeDigest.
+func ComputeReaderDigest(r io.Reader) (string, error) {
+	sha := sha512.New()
+	if _, err := io.Copy(sha, r); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(sha.Sum(nil)), nil
+}